@@ -1,26 +1,320 @@
 package main
 
 import (
+	"encoding/binary"
+	"flag"
+	"fmt"
 	"image"
 	"image/draw"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/kevinbrolly/GopherBoy/apu"
+	"github.com/kevinbrolly/GopherBoy/control"
+	"github.com/kevinbrolly/GopherBoy/cpu"
 	"github.com/kevinbrolly/GopherBoy/gameboy"
+	"github.com/kevinbrolly/GopherBoy/gbs"
+	"github.com/kevinbrolly/GopherBoy/record"
 
 	"github.com/veandco/go-sdl2/sdl"
 )
 
 func main() {
-	window := NewSDL2Window("Gameboy", 640, 576)
+	palette := flag.String("palette", "", "load a custom palette from a JASC-PAL or hex color list `file`")
+	bindings := flag.String("bindings", "", "load key and gamepad bindings from a `file`, in the format of control.DefaultBindings")
+	screenshotDir := flag.String("screenshot-dir", ".", "`directory` to save screenshots in")
+	screenshotScale := flag.Int("screenshot-scale", 1, "integer `scale` of screenshots")
+	screenshotIndexed := flag.Bool("screenshot-indexed", false, "save screenshots as indexed PNGs of the color ID of each pixel")
+	recordFile := flag.String("record", "", "record video and audio from power on to `file`, the format is chosen by the extension (.y4m, .png or .gif)")
+	recordDir := flag.String("record-dir", ".", "`directory` to save recordings started with the record hotkey in")
+	recordFormat := flag.String("record-format", "y4m", "`format` of recordings started with the record hotkey: y4m, png or gif")
+	recordAudio := flag.String("record-audio", "", "record audio from power on to the WAV `file`")
+	vgmFile := flag.String("vgm", "", "log every write to the sound registers from power on to the VGM `file`")
+	midiFile := flag.String("midi", "", "transcribe the music from power on to the MIDI `file`")
+	movieFile := flag.String("movie", "", "play back the input movie `file`")
+	recordMovie := flag.String("record-movie", "", "record the buttons pressed from power on to the input movie `file`")
+	readWrite := flag.Bool("read-write", false, "play back -movie read-write, so it can be taken over and recorded again with the movie-readonly hotkey")
+	stems := flag.Bool("stems", false, "also record each channel to its own WAV file when recording audio")
+	headless := flag.Bool("headless", false, "run without a window or sound as fast as possible, for -frames frames or to render a GBS track to the -record-audio file")
+	frames := flag.Int("frames", 0, "number of `frames` to run for in headless mode")
+	sampleRate := flag.Int("sample-rate", apu.Frequency, "audio sample `rate` in Hz, such as 44100, 48000 or 96000")
+	var cheatCodes stringList
+	flag.Var(&cheatCodes, "cheat", "apply a Game Genie or GameShark `code`, can be given more than once")
+	cheatFile := flag.String("cheats", "", "load a cheat list `file` as well as the ROM's own")
+	console := flag.Bool("console", false, "read commands, such as RAM searches, from standard input while running")
+	speed := flag.Float64("speed", 1, "how many `times` faster than normal to run, from 0.25 to 8")
+	turbo := flag.Bool("turbo", false, "run as fast as possible")
+	audioMode := flag.String("audio-mode", "skip", "what happens to the sound when not running at normal speed: skip or stretch")
+	track := flag.Int("track", 0, "`number` of the first track to play from a GBS file, 0 for the file's first track")
+	length := flag.Duration("length", 150*time.Second, "how long to play each track of a GBS file for, 0 to play forever")
+	fade := flag.Duration("fade", 10*time.Second, "how long to fade out each track of a GBS file over")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] rom|gbs\n", os.Args[0])
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	if *sampleRate <= 0 {
+		fmt.Fprintln(os.Stderr, "-sample-rate must be positive")
+		os.Exit(2)
+	}
+
+	if strings.EqualFold(filepath.Ext(flag.Arg(0)), ".gbs") {
+		playGBS(flag.Arg(0), *track, *length, *fade, *sampleRate, *headless, *recordAudio)
+		return
+	}
+
+	if *headless && *frames <= 0 {
+		fmt.Fprintln(os.Stderr, "-headless needs the number of -frames to run for")
+		os.Exit(2)
+	}
+
+	format, err := record.FormatFromFilename("." + *recordFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -record-format: %v\n", err)
+		os.Exit(2)
+	}
+
+	var mode gameboy.AudioMode
+	switch *audioMode {
+	case "skip":
+		mode = gameboy.AudioSkip
+	case "stretch":
+		mode = gameboy.AudioStretch
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid -audio-mode: %v\n", *audioMode)
+		os.Exit(2)
+	}
+
+	var window gameboy.Window
+	if !*headless {
+		window = NewSDL2Window("Gameboy", 640, 576)
+	}
 
 	gameboy := gameboy.NewGameboy(window)
+	gameboy.APU.SetSampleRate(*sampleRate)
 
-	rom := os.Args[1]
+	if !*headless {
+		gameboy.SetAudio(NewSDL2Audio(*sampleRate))
+	}
+
+	gameboy.SetSpeed(*speed)
+	gameboy.SetTurbo(*turbo)
+	gameboy.AudioMode = mode
+
+	if *palette != "" {
+		if err := gameboy.LoadPaletteFile(*palette); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading palette: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *bindings != "" {
+		b, err := control.LoadBindings(*bindings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading bindings: %v\n", err)
+			os.Exit(1)
+		}
+		gameboy.Controller.Bindings = b
+	}
+
+	gameboy.ScreenshotDir = *screenshotDir
+	gameboy.ScreenshotFormat.Scale = *screenshotScale
+	gameboy.ScreenshotFormat.Indexed = *screenshotIndexed
+	gameboy.RecordDir = *recordDir
+	gameboy.RecordFormat = format
+	gameboy.AudioStems = *stems
+
+	rom := flag.Arg(0)
 	gameboy.LoadCartridge(rom)
 
+	if *cheatFile != "" {
+		if err := gameboy.Cheats.Load(*cheatFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading cheats: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, code := range cheatCodes {
+		if _, err := gameboy.Cheats.Add(code, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -cheat: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if *movieFile != "" {
+		if err := gameboy.PlayMovie(*movieFile, !*readWrite); err != nil {
+			fmt.Fprintf(os.Stderr, "Error playing movie: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *recordMovie != "" {
+		if err := gameboy.StartMovieRecording(*recordMovie); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting movie recording: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *recordFile != "" {
+		if err := gameboy.StartRecording(*recordFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting recording: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *recordAudio != "" {
+		if err := gameboy.StartAudioRecording(*recordAudio, *stems); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting audio recording: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *vgmFile != "" {
+		if err := gameboy.StartVGM(*vgmFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting VGM log: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *midiFile != "" {
+		if err := gameboy.StartMIDI(*midiFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting MIDI recording: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *headless {
+		for i := 0; i < *frames; i++ {
+			gameboy.RunFrame()
+		}
+		if err := gameboy.StopRecording(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving recording: %v\n", err)
+			os.Exit(1)
+		}
+		if err := gameboy.StopAudioRecording(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving audio recording: %v\n", err)
+			os.Exit(1)
+		}
+		if err := gameboy.StopVGM(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving VGM: %v\n", err)
+			os.Exit(1)
+		}
+		if err := gameboy.StopMIDI(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving MIDI: %v\n", err)
+			os.Exit(1)
+		}
+		if err := gameboy.StopMovie(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving movie: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *console {
+		gameboy.StartConsole(os.Stdin, os.Stdout)
+	}
+
 	gameboy.Run()
 }
 
+// playGBS plays the tracks of a GBS file in order from track, or renders track to a WAV file in headless mode
+func playGBS(filename string, track int, length, fade time.Duration, sampleRate int, headless bool, wav string) {
+	file, err := gbs.Load(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading GBS file: %v\n", err)
+		os.Exit(1)
+	}
+
+	player := gbs.NewPlayer(file)
+	player.Length = length
+	player.Fade = fade
+	player.SampleRate = sampleRate
+
+	if track == 0 {
+		track = int(file.FirstSong)
+	}
+
+	fmt.Printf("%v - %v (%v)\n", file.Title, file.Author, file.Copyright)
+
+	if headless {
+		if wav == "" {
+			fmt.Fprintln(os.Stderr, "-headless needs a -record-audio file to render the track to")
+			os.Exit(2)
+		}
+		if err := player.RenderWAV(wav, track); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering track %v: %v\n", track, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := sdl.Init(sdl.INIT_AUDIO); err != nil {
+		panic(err)
+	}
+	defer sdl.Quit()
+	player.AddSink(NewSDL2Audio(sampleRate))
+
+	frameTime := time.Second * cpu.CyclesPerFrame / cpu.ClockSpeed
+	ticker := time.NewTicker(frameTime)
+	defer ticker.Stop()
+
+	for ; track <= int(file.SongCount); track++ {
+		if err := player.Start(track); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting track %v: %v\n", track, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Track %v/%v\n", track, file.SongCount)
+
+		for range ticker.C {
+			player.RunFrame()
+			if player.Ended() {
+				break
+			}
+		}
+	}
+}
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// SDL2Audio plays the APU's samples through the default SDL audio device
+type SDL2Audio struct{}
+
+func NewSDL2Audio(sampleRate int) *SDL2Audio {
+	spec := &sdl.AudioSpec{
+		Freq:     int32(sampleRate),
+		Format:   sdl.AUDIO_S16,
+		Channels: 2,
+		Samples:  apu.Samples,
+	}
+
+	if err := sdl.OpenAudio(spec, nil); err != nil {
+		panic(err)
+	}
+
+	sdl.PauseAudio(false)
+	return &SDL2Audio{}
+}
+
+func (a *SDL2Audio) WriteSamples(samples []int16) {
+	buffer := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(buffer[i*2:], uint16(sample))
+	}
+	sdl.QueueAudio(1, buffer)
+}
+
 type SDL2Window struct {
 	Name   string
 	Width  int
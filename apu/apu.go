@@ -1,12 +1,12 @@
 package apu
 
 import (
-	"bytes"
-	"encoding/binary"
+	"fmt"
+	"math"
 
+	"github.com/kevinbrolly/GopherBoy/cpu"
 	"github.com/kevinbrolly/GopherBoy/mmu"
 	"github.com/kevinbrolly/GopherBoy/utils"
-	"github.com/veandco/go-sdl2/sdl"
 )
 
 const (
@@ -46,12 +46,103 @@ const (
 	wavePatternRamStart = 0xFF30
 	wavePatternRamEnd   = 0xFF3F
 
-	// The frame sequencer runs at 512 Hz, which is 4194304/512=8192 clock cycles
-	FrameSequencerTimerRate = 8192
-	Frequency               = 44100
-	Samples                 = 2048
+	// Divider Register, the frame sequencer is clocked from DIV
+	DIV = 0xFF04
+
+	// The frame sequencer runs at 512 Hz, clocked by the falling edge of DIV bit 4
+	// (bit 5 in CGB double speed mode)
+	frameSequencerDIVBit = 4
+
+	// Frequency is the default sample rate
+	Frequency = 44100
+	Samples   = 2048
+
+	// channelStep is the number of cycles the channels are stepped at a time,
+	// the wave channel timer period is a multiple of 2 cycles and the others 4 or 8
+	channelStep = 2
+
+	// outputs is the number of outputs synthesized: left, right and the four channels
+	outputs = 6
+
+	// mixScale scales the left and right outputs, where four channels
+	// from -1 to 1 are mixed at full volume, to 16 bits
+	mixScale = math.MaxInt16 / 4
+	// channelScale scales the output of a channel to 16 bits, leaving
+	// headroom for the overshoot of the high-pass filter
+	channelScale = math.MaxInt16 / 2
 )
 
+// Model is the Game Boy model whose analog output stage is emulated
+type Model int
+
+const (
+	DMG Model = iota
+	CGB
+)
+
+func (m Model) String() string {
+	switch m {
+	case DMG:
+		return "DMG"
+	case CGB:
+		return "CGB"
+	}
+	return fmt.Sprintf("Model(%d)", int(m))
+}
+
+// Sink receives the stereo samples produced by the APU, such as an audio device or a recorder
+type Sink interface {
+	// WriteSamples receives interleaved left and right 16 bit samples at the APU's sample rate
+	WriteSamples(samples []int16)
+}
+
+// ChannelSink receives the output of each of the four channels
+// before it is mixed into the stereo output by NR50 and NR51
+type ChannelSink interface {
+	// WriteChannelSamples receives 16 bit samples at the APU's sample rate for the
+	// square 1, square 2, wave and noise channels in that order
+	WriteChannelSamples(channels [4][]int16)
+}
+
+// WriteListener is notified of every write to the sound registers and wave RAM
+type WriteListener interface {
+	// RegisterWritten receives the address and value written and the number of
+	// cycles the APU had run for when it was written, after the write has taken effect
+	RegisterWritten(cycle uint64, addr uint16, value byte)
+}
+
+// ChannelState is the pitch and volume a channel is playing at
+type ChannelState struct {
+	Enabled bool
+	// Frequency is the frequency of the waveform in Hz, for the
+	// noise channel it is the frequency the LFSR is clocked at
+	Frequency float64
+	// Volume is from 0 to 15, the wave channel's output level is converted to the same range
+	Volume byte
+	// ShortNoise is true when the noise channel uses a 7 bit LFSR, which sounds metallic
+	ShortNoise bool
+}
+
+// VinSource is a sound source on the cartridge, which outputs to the Vin pin
+// of the cartridge slot to be mixed with the channels by NR50
+type VinSource interface {
+	// Vin returns the level the cartridge outputs on Vin, from -1 to 1
+	Vin() float64
+}
+
+// AccessClock tells the APU when a register is accessed during the CPU instruction
+// being run, as the APU is run for the instruction's cycles after it is run
+type AccessClock interface {
+	// AccessCycles returns the number of cycles into the current instruction its memory access is made
+	AccessCycles() int
+}
+
+// RegisterWrite is a write to a sound register or wave RAM
+type RegisterWrite struct {
+	Addr  uint16
+	Value byte
+}
+
 // When an NRxx register is read back, the last written value ORed with the following is returned:
 var apuReadMask = map[uint16]byte{
 	NR10: 0x80,
@@ -91,18 +182,47 @@ var apuReadMask = map[uint16]byte{
 }
 
 type APU struct {
+	// Model selects the charge factor of the high-pass filter, the CGB's
+	// filter removes the DC offset faster than the DMG's
+	Model Model
+
+	// Vin is the cartridge's sound source, no supported cartridge has one so
+	// it is nil, which is silent
+	Vin VinSource
+
+	// Clock, if set, places accesses to wave RAM on the exact cycle the CPU makes them,
+	// which the DMG is sensitive to while the wave channel is playing
+	Clock AccessClock
+
 	mmu      *mmu.MMU
 	channel1 *Square1Channel
 	channel2 *Square2Channel
 	channel3 *WaveChannel
 	channel4 *NoiseChannel
 
-	sampleTimer  int
-	sampleBuffer *bytes.Buffer
-	sampleCount  int
+	// Changes in the output are band-limited by blip buffers
+	// for the left and right outputs and each channel
+	sampleRate int
+	blips      [outputs]*blipBuffer
+	levels     [outputs]float64
+	scratch    [outputs][]float64
+	capacitors [outputs]float64
+
+	sampleBuffer []int16
+	sinks        []Sink
+
+	channelBuffers [4][]int16
+	channelSinks   []ChannelSink
 
-	frameSequencerTimer int
-	frameSequencerStep  int
+	frameSequencerStep int
+	lastDIV            byte
+
+	// cycles is the number of cycles the APU has run for
+	cycles uint64
+
+	// registers holds the last value written to each of NR10-NR51
+	registers      [NR51 - NR10 + 1]byte
+	writeListeners []WriteListener
 
 	// NR50
 	outputVinSO1 bool
@@ -131,9 +251,11 @@ func NewAPU(mmu *mmu.MMU) *APU {
 		channel2:     &Square2Channel{},
 		channel3:     &WaveChannel{},
 		channel4:     &NoiseChannel{},
-		sampleBuffer: new(bytes.Buffer),
+		sampleBuffer: make([]int16, 0, Samples*2),
 	}
 
+	apu.SetSampleRate(Frequency)
+
 	// 0xFF10 - 0xFF26
 	mmu.MapMemoryRange(apu, NR10, NR52)
 
@@ -144,95 +266,333 @@ func NewAPU(mmu *mmu.MMU) *APU {
 	// 0xFF30 - 0xFF3F Wave Pattern Ram for WaveChannel
 	mmu.MapMemoryRange(apu, wavePatternRamStart, wavePatternRamEnd)
 
-	spec := &sdl.AudioSpec{
-		Freq:     Frequency,
-		Format:   sdl.AUDIO_S16,
-		Channels: 2,
-		Samples:  Samples,
+	return apu
+}
+
+// SampleRate returns the number of samples per second sent to the sinks
+func (s *APU) SampleRate() int {
+	return s.sampleRate
+}
+
+// SetSampleRate sets the number of samples per second sent to the sinks, any
+// samples that have not been flushed yet are dropped
+func (s *APU) SetSampleRate(sampleRate int) {
+	s.sampleRate = sampleRate
+	for i := range s.blips {
+		s.blips[i] = newBlipBuffer(cpu.ClockSpeed, sampleRate)
+		s.levels[i] = 0
+		s.capacitors[i] = 0
 	}
 
-	if err := sdl.OpenAudio(spec, nil); err != nil {
-		panic(err)
+	s.sampleBuffer = s.sampleBuffer[:0]
+	for i := range s.channelBuffers {
+		s.channelBuffers[i] = s.channelBuffers[i][:0]
 	}
+}
 
-	sdl.PauseAudio(false)
-	return apu
+// AddSink adds a sink that receives every sample produced from now on
+func (s *APU) AddSink(sink Sink) {
+	s.sinks = append(s.sinks, sink)
+}
+
+// RemoveSink stops sending samples to sink
+func (s *APU) RemoveSink(sink Sink) {
+	for i, existing := range s.sinks {
+		if existing == sink {
+			s.sinks = append(s.sinks[:i], s.sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddChannelSink adds a sink that receives every channel sample produced from now on
+func (s *APU) AddChannelSink(sink ChannelSink) {
+	s.channelSinks = append(s.channelSinks, sink)
+}
+
+// RemoveChannelSink stops sending channel samples to sink
+func (s *APU) RemoveChannelSink(sink ChannelSink) {
+	for i, existing := range s.channelSinks {
+		if existing == sink {
+			s.channelSinks = append(s.channelSinks[:i], s.channelSinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddWriteListener adds a listener that is notified of every write to the sound registers and wave RAM
+func (s *APU) AddWriteListener(listener WriteListener) {
+	s.writeListeners = append(s.writeListeners, listener)
+}
+
+// RemoveWriteListener stops notifying listener of writes
+func (s *APU) RemoveWriteListener(listener WriteListener) {
+	for i, existing := range s.writeListeners {
+		if existing == listener {
+			s.writeListeners = append(s.writeListeners[:i], s.writeListeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// Cycles returns the number of cycles the APU has run for
+func (s *APU) Cycles() uint64 {
+	return s.cycles
+}
+
+// StateWrites returns writes that put a newly powered on APU into the current state, so
+// that a log of writes started part way through can be played back. Channels that are
+// playing are triggered again by the writes, so notes are restarted rather than resumed.
+func (s *APU) StateWrites() []RegisterWrite {
+	if !s.enable {
+		return []RegisterWrite{{NR52, 0x00}}
+	}
+
+	writes := []RegisterWrite{{NR52, 0x80}}
+
+	// Wave RAM can only be written freely while the wave channel's DAC is off
+	writes = append(writes, RegisterWrite{NR30, 0x00})
+	for i, value := range s.channel3.wavePatternRAM {
+		writes = append(writes, RegisterWrite{wavePatternRamStart + uint16(i), value})
+	}
+
+	enabled := map[uint16]bool{
+		NR14: s.channel1.enable,
+		NR24: s.channel2.enable,
+		NR34: s.channel3.enable,
+		NR44: s.channel4.enable,
+	}
+
+	for addr := uint16(NR10); addr <= NR51; addr++ {
+		// NR20 and NR40 don't exist
+		if addr == NR20 || addr == NR40 {
+			continue
+		}
+
+		value := s.registers[addr-NR10]
+		if on, ok := enabled[addr]; ok {
+			value &^= 0x80
+			if on {
+				value |= 0x80
+			}
+		}
+		writes = append(writes, RegisterWrite{addr, value})
+	}
+
+	return writes
+}
+
+// Channels returns the state of the square 1, square 2, wave and noise channels in that order
+func (s *APU) Channels() [4]ChannelState {
+	// Square:  131072/(2048-x) Hz
+	// Wave:     65536/(2048-x) Hz
+	// Noise:   524288/r/2^(s+1) Hz, where a divisor code of 0 is treated as 0.5
+	divisor := float64(s.channel4.dividingRatio)
+	if divisor == 0 {
+		divisor = 0.5
+	}
+
+	// Output level 1 is full volume, 2 is 50% and 3 is 25%
+	waveVolume := [4]byte{0, 15, 7, 3}
+
+	return [4]ChannelState{
+		{
+			Enabled:   s.enable && s.channel1.enable,
+			Frequency: 131072 / float64(2048-int(s.channel1.frequency)),
+			Volume:    s.channel1.volume,
+		},
+		{
+			Enabled:   s.enable && s.channel2.enable,
+			Frequency: 131072 / float64(2048-int(s.channel2.frequency)),
+			Volume:    s.channel2.volume,
+		},
+		{
+			Enabled:   s.enable && s.channel3.enable,
+			Frequency: 65536 / float64(2048-int(s.channel3.frequency)),
+			Volume:    waveVolume[s.channel3.volume],
+		},
+		{
+			Enabled:    s.enable && s.channel4.enable,
+			Frequency:  524288 / divisor / float64(uint(2)<<s.channel4.shiftClockFrequency),
+			Volume:     s.channel4.volume,
+			ShortNoise: s.channel4.counterWidth,
+		},
+	}
+}
+
+// Flush sends any buffered samples to the sinks. Samples are sent every
+// Samples samples, flushing at the end of each frame keeps sinks in sync
+// with the video.
+func (s *APU) Flush() {
+	if len(s.sampleBuffer) == 0 {
+		return
+	}
+	for _, sink := range s.sinks {
+		sink.WriteSamples(s.sampleBuffer)
+	}
+	s.sampleBuffer = s.sampleBuffer[:0]
+
+	for _, sink := range s.channelSinks {
+		sink.WriteChannelSamples(s.channelBuffers)
+	}
+	for i := range s.channelBuffers {
+		s.channelBuffers[i] = s.channelBuffers[i][:0]
+	}
 }
 
 func (s *APU) Tick(cycles int) {
-	s.channel1.Tick(cycles)
-	s.channel2.Tick(cycles)
-	s.channel3.Tick(cycles)
-	s.channel4.Tick(cycles)
+	s.cycles += uint64(cycles)
+
+	// The channels are stepped a few cycles at a time, the shortest period of
+	// any channel timer, so every change in their output is seen when it happens
+	for cycles > 0 {
+		step := channelStep
+		if cycles < step {
+			step = cycles
+		}
+		cycles -= step
 
-	if s.frameSequencerTimer > 0 {
-		s.frameSequencerTimer = s.frameSequencerTimer - cycles
+		s.channel1.Tick(step)
+		s.channel2.Tick(step)
+		s.channel3.Tick(step)
+		s.channel4.Tick(step)
+
+		s.updateOutput()
+		for _, blip := range s.blips {
+			blip.advance(step)
+		}
 	}
 
-	if s.frameSequencerTimer <= 0 {
+	// Clocking the frame sequencer from DIV means writes to DIV
+	// that clear the bit also clock it, the same as hardware
+	div := s.mmu.ReadByte(DIV)
+	if utils.IsBitSet(s.lastDIV, frameSequencerDIVBit) && !utils.IsBitSet(div, frameSequencerDIVBit) {
 		s.tickFrameSequencer()
 	}
+	s.lastDIV = div
 
-	if s.sampleTimer > 0 {
-		s.sampleTimer = s.sampleTimer - cycles
+	s.readSamples()
+}
+
+// updateOutput adds any change in the mixed output or the output of each channel to the blip buffers
+func (s *APU) updateOutput() {
+	// Each channel's DAC converts its digital output to analog, even while the
+	// channel is disabled, so only the DAC being off silences a channel
+	var analog [4]float64
+	if s.enable {
+		analog = [4]float64{
+			dac(s.channel1.DACEnable, s.channel1.sample()),
+			dac(s.channel2.DACEnable, s.channel2.sample()),
+			dac(s.channel3.DACEnable, s.channel3.sample()),
+			dac(s.channel4.DACEnable, s.channel4.sample()),
+		}
 	}
 
-	if s.sampleTimer <= 0 {
-		SO2 := int(0)
-		SO1 := int(0)
+	// NR51 selects the channels mixed into each output
+	// SO2 is the left output and SO1 the right output
+	left := [4]bool{s.output1SO2, s.output2SO2, s.output3SO2, s.output4SO2}
+	right := [4]bool{s.output1SO1, s.output2SO1, s.output3SO1, s.output4SO1}
 
-		if s.enable {
-			channel1Sample := int(s.channel1.sample())
-			channel2Sample := int(s.channel2.sample())
-			channel3Sample := int(s.channel3.sample())
-			channel4Sample := int(s.channel4.sample())
+	var L, R float64
+	for i, level := range analog {
+		if left[i] {
+			L += level
+		}
+		if right[i] {
+			R += level
+		}
+	}
 
-			if s.output4SO2 {
-				SO2 += channel4Sample
-			}
-			if s.output3SO2 {
-				SO2 += channel3Sample
-			}
-			if s.output2SO2 {
-				SO2 += channel2Sample
-			}
-			if s.output1SO2 {
-				SO2 += channel1Sample
-			}
+	// NR50 selects whether Vin is mixed into each output
+	if s.enable && s.Vin != nil {
+		vin := s.Vin.Vin()
+		if s.outputVinSO2 {
+			L += vin
+		}
+		if s.outputVinSO1 {
+			R += vin
+		}
+	}
 
-			if s.output4SO1 {
-				SO1 += channel4Sample
-			}
-			if s.output3SO1 {
-				SO1 += channel3Sample
-			}
-			if s.output2SO1 {
-				SO1 += channel2Sample
-			}
-			if s.output1SO1 {
-				SO1 += channel1Sample
-			}
+	// NR50 scales each output by (volume+1)/8
+	L *= float64(s.volumeSO2+1) / 8
+	R *= float64(s.volumeSO1+1) / 8
+
+	levels := [outputs]float64{L, R, analog[0], analog[1], analog[2], analog[3]}
+	for i, level := range levels {
+		if level != s.levels[i] {
+			s.blips[i].addDelta(level - s.levels[i])
+			s.levels[i] = level
+		}
+	}
+}
 
-			SO2 = ((0xf - SO2*2) * int(s.volumeSO2+1))
-			SO1 = ((0xf - SO1*2) * int(s.volumeSO1+1))
+// dac converts a channel's digital output from 0 to 15 to an analog level
+// from 1 to -1, a DAC that is off outputs 0
+func dac(enabled bool, digital byte) float64 {
+	if !enabled {
+		return 0
+	}
+	return 1 - float64(digital)/7.5
+}
+
+// highPassCharge returns how much of the capacitor's charge remains after each
+// sample, the factor applies per cycle so it is raised to the cycles per sample
+func (s *APU) highPassCharge() float64 {
+	factor := 0.999958
+	if s.Model == CGB {
+		factor = 0.998943
+	}
+	return math.Pow(factor, float64(cpu.ClockSpeed)/float64(s.sampleRate))
+}
 
-			var L = int16(SO1)
-			var R = int16(SO2)
+// highPass removes the DC offset from a sample of an output in the same way as the
+// capacitor on each output, so DACs switching on and off don't leave the output offset
+func (s *APU) highPass(output int, sample float64, charge float64) float64 {
+	out := sample - s.capacitors[output]
+	s.capacitors[output] = sample - out*charge
+	return out
+}
 
-			binary.Write(s.sampleBuffer, binary.LittleEndian, L)
-			binary.Write(s.sampleBuffer, binary.LittleEndian, R)
-			s.sampleCount++
+// readSamples moves the finished samples from the blip buffers to the sample buffers
+func (s *APU) readSamples() {
+	n := s.blips[0].available()
+	if n == 0 {
+		return
+	}
 
-			if s.sampleCount == Samples {
-				s.sampleCount = 0
-				sdl.QueueAudio(1, s.sampleBuffer.Bytes())
-				s.sampleBuffer.Reset()
-			}
+	for i, blip := range s.blips {
+		s.scratch[i] = blip.read(s.scratch[i][:0], n)
+	}
 
-			// Reload sample timer
-			s.sampleTimer = 4194304 / Frequency
+	charge := s.highPassCharge()
+
+	// Silence is still sent while the APU is off so sinks stay in sync
+	for i := 0; i < n; i++ {
+		L := s.highPass(0, s.scratch[0][i], charge)
+		R := s.highPass(1, s.scratch[1][i], charge)
+		s.sampleBuffer = append(s.sampleBuffer, clampSample(L*mixScale), clampSample(R*mixScale))
+	}
+
+	for channel := range s.channelBuffers {
+		for _, sample := range s.scratch[2+channel] {
+			sample = s.highPass(2+channel, sample, charge)
+
+			// Only keep the channel outputs if something is listening for them
+			if len(s.channelSinks) > 0 {
+				s.channelBuffers[channel] = append(s.channelBuffers[channel], clampSample(sample*channelScale))
+			}
 		}
 	}
+
+	if len(s.sampleBuffer) >= Samples*2 {
+		s.Flush()
+	}
+}
+
+// clampSample rounds a sample to 16 bits, clipping it if it is out of range
+func clampSample(sample float64) int16 {
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(sample))))
 }
 
 func (s *APU) tickFrameSequencer() {
@@ -262,9 +622,6 @@ func (s *APU) tickFrameSequencer() {
 	if s.frameSequencerStep == 8 {
 		s.frameSequencerStep = 0
 	}
-
-	// Reload sequencer timer
-	s.frameSequencerTimer = FrameSequencerTimerRate
 }
 
 func (s *APU) ReadByte(addr uint16) byte {
@@ -300,7 +657,7 @@ func (s *APU) ReadByte(addr uint16) byte {
 		}
 
 		value |= s.volumeSO1
-		value |= s.volumeSO2
+		value |= s.volumeSO2 << 4
 	case NR51:
 		if s.output4SO2 {
 			value = utils.SetBit(value, 7)
@@ -355,31 +712,47 @@ func (s *APU) ReadByte(addr uint16) byte {
 		}
 	}
 
-	if addr >= 0xFF30 && addr <= 0xFF3F {
-		value = s.channel3.ReadByte(addr)
+	if addr >= wavePatternRamStart && addr <= wavePatternRamEnd {
+		value = s.channel3.ReadWaveRAM(addr, s.Model == DMG, s.accessCycles())
 	}
 	return value | apuReadMask[addr]
 }
 
 func (s *APU) WriteByte(addr uint16, value byte) {
+	if s.enable && addr >= NR10 && addr <= NR51 {
+		s.registers[addr-NR10] = value
+	}
+
+	s.writeRegister(addr, value)
+
+	for _, listener := range s.writeListeners {
+		listener.RegisterWritten(s.cycles, addr, value)
+	}
+}
+
+// accessCycles returns the number of cycles from now that the register being accessed is accessed at
+func (s *APU) accessCycles() int {
+	if s.Clock == nil {
+		return 0
+	}
+	return s.Clock.AccessCycles()
+}
+
+func (s *APU) writeRegister(addr uint16, value byte) {
+	// Wave pattern RAM can be accessed whether or not the APU is powered
+	if addr >= wavePatternRamStart && addr <= wavePatternRamEnd {
+		s.channel3.WriteWaveRAM(addr, value, s.Model == DMG, s.accessCycles())
+		return
+	}
+
 	if addr == NR52 {
 		// NR52 controls power to the sound hardware
 		if utils.IsBitSet(value, 7) {
-			s.enable = true
-		} else {
-			// When powered off, all registers (NR10-NR51) are instantly written with zero
-			// and any writes to those registers are ignored while power remains off
-			for addr := NR10; addr <= NR51; addr++ {
-				// TODO: have "If using DMG" or similar here when CGB support is added.
-				// (except on the DMG, where length counters are unaffected by power and can still be written while off)
-				switch addr {
-				case NR11, NR21, NR31, NR41:
-					break
-				default:
-					s.mmu.WriteByte(uint16(addr), 0x00)
-				}
+			if !s.enable {
+				s.powerOn()
 			}
-			s.enable = false
+		} else if s.enable {
+			s.powerOff()
 		}
 	} else {
 		if s.enable {
@@ -402,6 +775,10 @@ func (s *APU) WriteByte(addr uint16, value byte) {
 			case NR30, NR31, NR32, NR33:
 				s.channel3.WriteByte(addr, value)
 			case NR34:
+				// On the DMG, triggering the wave channel as it reads a sample corrupts wave RAM
+				if s.Model == DMG && utils.IsBitSet(value, 7) {
+					s.channel3.corruptWaveRAM(s.accessCycles())
+				}
 				s.channel3.WriteTriggerByte(value, s.frameSequencerStep)
 
 			// Noise Channel Registers
@@ -414,7 +791,7 @@ func (s *APU) WriteByte(addr uint16, value byte) {
 				s.outputVinSO1 = utils.IsBitSet(value, 3)
 				s.outputVinSO2 = utils.IsBitSet(value, 7)
 				s.volumeSO1 = value & 0x7
-				s.volumeSO2 = value & 0x70
+				s.volumeSO2 = (value >> 4) & 0x7
 			case NR51:
 				s.output4SO2 = utils.IsBitSet(value, 7)
 				s.output3SO2 = utils.IsBitSet(value, 6)
@@ -425,24 +802,54 @@ func (s *APU) WriteByte(addr uint16, value byte) {
 				s.output2SO1 = utils.IsBitSet(value, 1)
 				s.output1SO1 = utils.IsBitSet(value, 0)
 			}
-			if addr >= 0xFF30 && addr <= 0xFF3F {
-				s.channel3.WriteByte(addr, value)
-			}
-		} else {
-			// TODO: have "If using DMG" or similar here when CGB support is added.
-
+		} else if s.Model == DMG {
 			// When powered off, any writes to all registers (NR10-NR51) are ignored while power remains off
 			// except on the DMG, where length counters are unaffected by power and can still be written while off
 			switch addr {
 			case NR11:
-				s.channel1.WriteByte(addr, value)
+				s.channel1.writeLength(value, 64)
 			case NR21:
-				s.channel2.WriteByte(addr, value)
+				s.channel2.writeLength(value, 64)
 			case NR31:
-				s.channel3.WriteByte(addr, value)
+				s.channel3.writeLength(value, 256)
 			case NR41:
-				s.channel4.WriteByte(addr, value)
+				s.channel4.writeLength(value, 64)
 			}
 		}
 	}
 }
+
+// powerOff turns off the sound hardware
+func (s *APU) powerOff() {
+	lengths := [4]int{s.channel1.length, s.channel2.length, s.channel3.length, s.channel4.length}
+
+	// When powered off, all registers (NR10-NR51) are instantly written with zero
+	for addr := NR10; addr <= NR51; addr++ {
+		s.writeRegister(uint16(addr), 0x00)
+	}
+	s.registers = [len(s.registers)]byte{}
+
+	// except on the DMG, where length counters are unaffected by power
+	if s.Model != DMG {
+		lengths = [4]int{}
+	}
+	s.channel1.length = lengths[0]
+	s.channel2.length = lengths[1]
+	s.channel3.length = lengths[2]
+	s.channel4.length = lengths[3]
+
+	s.enable = false
+}
+
+// powerOn turns on the sound hardware
+func (s *APU) powerOn() {
+	// When powered on, the frame sequencer is reset so that the next step will be 0,
+	// the square duty units are reset to the first step of the waveform,
+	// and the wave channel's sample buffer is reset to 0.
+	s.frameSequencerStep = 0
+	s.channel1.wavePatternDutyPosition = 0
+	s.channel2.wavePatternDutyPosition = 0
+	s.channel3.buffer = 0
+
+	s.enable = true
+}
@@ -0,0 +1,585 @@
+package apu
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/cpu"
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+type testSink struct {
+	samples  []int16
+	channels [4][]int16
+}
+
+func (s *testSink) WriteSamples(samples []int16) {
+	s.samples = append(s.samples, samples...)
+}
+
+func (s *testSink) WriteChannelSamples(channels [4][]int16) {
+	for i := range channels {
+		s.channels[i] = append(s.channels[i], channels[i]...)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	apu := NewAPU(mmu.NewMMU())
+	sink := &testSink{}
+	apu.AddSink(sink)
+	apu.AddChannelSink(sink)
+
+	// 1 second, in steps the size of a typical instruction
+	for i := 0; i < cpu.ClockSpeed; i += 4 {
+		apu.Tick(4)
+	}
+	apu.Flush()
+
+	if len(sink.samples) != Frequency*2 {
+		t.Errorf("sink should have received %v samples but received %v", Frequency*2, len(sink.samples))
+	}
+	for i, channel := range sink.channels {
+		if len(channel) != Frequency {
+			t.Errorf("channel %v should have received %v samples but received %v", i+1, Frequency, len(channel))
+		}
+	}
+
+	apu.RemoveSink(sink)
+	apu.RemoveChannelSink(sink)
+	apu.Tick(cpu.ClockSpeed / 10)
+	apu.Flush()
+	if len(sink.samples) != Frequency*2 {
+		t.Errorf("removed sink should not have received any more samples")
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	cases := []struct {
+		sampleRate int
+	}{
+		{44100},
+		{48000},
+		{96000},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			apu.SetSampleRate(c.sampleRate)
+			sink := &testSink{}
+			apu.AddSink(sink)
+
+			for i := 0; i < cpu.ClockSpeed/4; i++ {
+				apu.Tick(4)
+			}
+			apu.Flush()
+
+			if len(sink.samples) != c.sampleRate*2 {
+				t.Errorf("sink should have received %v samples but received %v", c.sampleRate*2, len(sink.samples))
+			}
+		})
+	}
+}
+
+func TestDAC(t *testing.T) {
+	cases := []struct {
+		enabled  bool
+		digital  byte
+		expected float64
+	}{
+		{true, 0, 1},
+		{true, 15, -1},
+		{true, 0x7, 1 - 7/7.5},
+		{false, 0, 0},
+		{false, 15, 0},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			if result := dac(c.enabled, c.digital); math.Abs(result-c.expected) > 1e-9 {
+				t.Errorf("analog level should have been %v but was %v", c.expected, result)
+			}
+		})
+	}
+}
+
+func TestNR50(t *testing.T) {
+	cases := []struct {
+		value     byte
+		volumeSO1 byte
+		volumeSO2 byte
+	}{
+		{0x77, 7, 7},
+		{0x53, 3, 5},
+		{0x88, 0, 0},
+		{0x07, 7, 0},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			apu.WriteByte(NR52, 0x80)
+			apu.WriteByte(NR50, c.value)
+
+			if apu.volumeSO1 != c.volumeSO1 {
+				t.Errorf("SO1 volume should have been %v but was %v", c.volumeSO1, apu.volumeSO1)
+			}
+			if apu.volumeSO2 != c.volumeSO2 {
+				t.Errorf("SO2 volume should have been %v but was %v", c.volumeSO2, apu.volumeSO2)
+			}
+			if value := apu.ReadByte(NR50); value != c.value {
+				t.Errorf("NR50 should have been %#x but was %#x", c.value, value)
+			}
+		})
+	}
+}
+
+// constantVin is a cartridge sound source that outputs a constant level
+type constantVin float64
+
+func (v constantVin) Vin() float64 { return float64(v) }
+
+func TestVin(t *testing.T) {
+	cases := []struct {
+		NR50  byte
+		left  float64
+		right float64
+	}{
+		{0x00, 0, 0},
+		{0x08, 0, 1.0 / 8},
+		{0x80, 1.0 / 8, 0},
+		{0xFF, 1, 1},
+		{0xBA, 1.0 / 2, 3.0 / 8},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			apu.Vin = constantVin(1)
+			apu.WriteByte(NR52, 0x80)
+			apu.WriteByte(NR50, c.NR50)
+			apu.updateOutput()
+
+			if left := apu.levels[0]; math.Abs(left-c.left) > 1e-9 {
+				t.Errorf("left output should have been %v but was %v", c.left, left)
+			}
+			if right := apu.levels[1]; math.Abs(right-c.right) > 1e-9 {
+				t.Errorf("right output should have been %v but was %v", c.right, right)
+			}
+		})
+	}
+}
+
+func TestHighPass(t *testing.T) {
+	cases := []struct {
+		model Model
+		NR51  byte
+	}{
+		{DMG, 0x22},
+		{CGB, 0x22},
+		{DMG, 0x20},
+		{DMG, 0x02},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			apu.Model = c.model
+			sink := &testSink{}
+			apu.AddSink(sink)
+
+			// Turning on square 2's DAC without triggering it outputs a constant
+			// digital 0, which the DAC converts to a DC offset
+			apu.WriteByte(NR52, 0x80)
+			apu.WriteByte(NR50, 0x77)
+			apu.WriteByte(NR51, c.NR51)
+			apu.WriteByte(NR22, 0xF0)
+
+			for i := 0; i < cpu.ClockSpeed/4; i++ {
+				apu.Tick(4)
+			}
+			apu.Flush()
+
+			for i, enabled := range []bool{c.NR51&0x20 != 0, c.NR51&0x02 != 0} {
+				peak := int16(0)
+				for j := i; j < Frequency; j += 2 {
+					if sink.samples[j] > peak {
+						peak = sink.samples[j]
+					}
+				}
+				last := sink.samples[len(sink.samples)-2+i]
+
+				if enabled && peak < math.MaxInt16/8 {
+					t.Errorf("output %v should have jumped when the DAC was turned on but only reached %v", i, peak)
+				}
+				if !enabled && peak != 0 {
+					t.Errorf("output %v should have been silent but reached %v", i, peak)
+				}
+				if last != 0 {
+					t.Errorf("output %v should have settled to 0 but was %v", i, last)
+				}
+			}
+		})
+	}
+}
+
+func TestPowerOff(t *testing.T) {
+	cases := []struct {
+		model   Model
+		length  int
+		written int
+	}{
+		// On the DMG length counters are kept and can be written while off, but not the duty
+		{DMG, 40, 1},
+		{CGB, 0, 0},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			apu.Model = c.model
+			apu.WriteByte(NR52, 0x80)
+
+			for addr := uint16(NR10); addr <= NR51; addr++ {
+				apu.WriteByte(addr, 0xFF)
+			}
+			apu.WriteByte(NR11, 0xC0|(64-40))
+			apu.WriteByte(NR52, 0x00)
+
+			for addr := uint16(NR10); addr <= NR51; addr++ {
+				if value := apu.ReadByte(addr); value != apuReadMask[addr] {
+					t.Errorf("%#x should have been %#x after power off but was %#x", addr, apuReadMask[addr], value)
+				}
+			}
+			if apu.channel1.length != c.length {
+				t.Errorf("length should have been %v but was %v", c.length, apu.channel1.length)
+			}
+
+			// Writes while off
+			apu.WriteByte(NR11, 0xFF)
+			apu.WriteByte(NR12, 0xFF)
+			if value := apu.ReadByte(NR11); value != 0x3F {
+				t.Errorf("NR11 should have been 0x3f but was %#x", value)
+			}
+			if value := apu.ReadByte(NR12); value != 0x00 {
+				t.Errorf("NR12 should have been 0 but was %#x", value)
+			}
+			if apu.channel1.length != c.written {
+				t.Errorf("length should have been %v after writing while off but was %v", c.written, apu.channel1.length)
+			}
+
+			// Wave RAM is unaffected by power
+			apu.WriteByte(wavePatternRamStart, 0x12)
+			if value := apu.ReadByte(wavePatternRamStart); value != 0x12 {
+				t.Errorf("wave RAM should have been 0x12 but was %#x", value)
+			}
+		})
+	}
+}
+
+func TestLengthEnableClocking(t *testing.T) {
+	cases := []struct {
+		frameSequencerStep int
+		length             int
+		trigger            bool
+		expectedLength     int
+		expectedEnable     bool
+	}{
+		// Only clocked when the next step doesn't clock the length counter
+		{1, 10, false, 9, true},
+		{0, 10, false, 10, true},
+		// Reaching zero disables the channel unless it is triggered
+		{1, 1, false, 0, false},
+		{1, 1, true, 63, true},
+		{0, 1, true, 1, true},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			apu.WriteByte(NR52, 0x80)
+			apu.WriteByte(NR22, 0xF0)
+			apu.WriteByte(NR21, byte(64-c.length))
+			apu.WriteByte(NR24, 0x80)
+
+			apu.frameSequencerStep = c.frameSequencerStep
+			value := byte(0x40)
+			if c.trigger {
+				value |= 0x80
+			}
+			apu.WriteByte(NR24, value)
+
+			if apu.channel2.length != c.expectedLength {
+				t.Errorf("length should have been %v but was %v", c.expectedLength, apu.channel2.length)
+			}
+			if apu.channel2.enable != c.expectedEnable {
+				t.Errorf("enable should have been %v but was %v", c.expectedEnable, apu.channel2.enable)
+			}
+		})
+	}
+}
+
+func TestSweepNegateLockout(t *testing.T) {
+	cases := []struct {
+		name     string
+		before   byte
+		after    byte
+		expected bool
+	}{
+		{"Negate used then cleared", 0x19, 0x11, false},
+		{"Negate used and kept", 0x19, 0x19, true},
+		{"Negate not used then cleared", 0x18, 0x10, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			apu.WriteByte(NR52, 0x80)
+			apu.WriteByte(NR12, 0xF0)
+			apu.WriteByte(NR10, c.before)
+			apu.WriteByte(NR13, 0x00)
+			apu.WriteByte(NR14, 0x84)
+
+			apu.WriteByte(NR10, c.after)
+
+			if apu.channel1.enable != c.expected {
+				t.Errorf("enable should have been %v but was %v", c.expected, apu.channel1.enable)
+			}
+		})
+	}
+}
+
+func TestZombieMode(t *testing.T) {
+	cases := []struct {
+		before   byte
+		after    byte
+		expected byte
+	}{
+		// Period 0 with the envelope still running adds 1
+		{0x80, 0x80, 9},
+		{0xF0, 0xF0, 0},
+		// Subtract mode adds 2
+		{0x81, 0x81, 10},
+		// Changing mode sets the volume to 16-volume
+		{0x88, 0x80, 7},
+		{0x89, 0x89, 8},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			apu.WriteByte(NR52, 0x80)
+			apu.WriteByte(NR22, c.before)
+			apu.WriteByte(NR24, 0x80)
+
+			apu.WriteByte(NR22, c.after)
+
+			if apu.channel2.volume != c.expected {
+				t.Errorf("volume should have been %v but was %v", c.expected, apu.channel2.volume)
+			}
+		})
+	}
+}
+
+func TestWaveRAMAccess(t *testing.T) {
+	cases := []struct {
+		name      string
+		model     Model
+		enable    bool
+		sinceRead int
+		expected  byte
+		written   uint16
+	}{
+		{"Disabled", DMG, false, 100, 0x00, 0},
+		{"DMG reading", DMG, true, 0, 0x22, 2},
+		{"DMG reading the cycle before", DMG, true, 1, 0x22, 2},
+		{"DMG not reading", DMG, true, 2, 0xFF, 16},
+		{"CGB not reading", CGB, true, 100, 0x22, 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			apu.Model = c.model
+			for i := uint16(0); i < 16; i++ {
+				apu.WriteByte(wavePatternRamStart+i, byte(i*0x11))
+			}
+
+			apu.channel3.enable = c.enable
+			apu.channel3.position = 5
+			apu.channel3.sinceRead = c.sinceRead
+			apu.channel3.timer = 100
+
+			if value := apu.ReadByte(wavePatternRamStart); value != c.expected {
+				t.Errorf("wave RAM should have read %#x but read %#x", c.expected, value)
+			}
+
+			apu.WriteByte(wavePatternRamStart, 0xAB)
+			for i, value := range apu.channel3.wavePatternRAM {
+				if written := value == 0xAB; written != (uint16(i) == c.written) {
+					t.Errorf("byte %v should have been written: %v", i, !written)
+				}
+			}
+		})
+	}
+}
+
+func TestWavePosition(t *testing.T) {
+	apu := NewAPU(mmu.NewMMU())
+	apu.WriteByte(wavePatternRamStart, 0x12)
+	apu.WriteByte(wavePatternRamStart+1, 0x34)
+	apu.WriteByte(NR52, 0x80)
+	apu.WriteByte(NR30, 0x80)
+	apu.WriteByte(NR33, 0xFF)
+	apu.WriteByte(NR34, 0x87)
+
+	// The first sample played after a trigger is the low nibble of the first byte,
+	// followed by the high nibble of the next
+	for i, expected := range []byte{0x2, 0x3, 0x4} {
+		for apu.channel3.sinceRead >= waveReadWindow {
+			apu.Tick(2)
+		}
+		if apu.channel3.buffer != expected {
+			t.Errorf("sample %v should have been %#x but was %#x", i+1, expected, apu.channel3.buffer)
+		}
+		apu.Tick(2)
+	}
+}
+
+// accessClock places accesses a fixed number of cycles into the current instruction
+type accessClock int
+
+func (c accessClock) AccessCycles() int { return int(c) }
+
+func TestWaveRAMAccessCycle(t *testing.T) {
+	// With a timer period of 4 cycles, the channel reads wave RAM 10 cycles after
+	// it is triggered and every 4 cycles after that, the DMG only allows access on
+	// the cycle of a read and the one after
+	cases := []struct {
+		ahead    int
+		expected byte
+	}{
+		{0, 0xFF},
+		{9, 0xFF},
+		{10, 0x00},
+		{11, 0x00},
+		{12, 0xFF},
+		{14, 0x11},
+		{15, 0x11},
+		{16, 0xFF},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprint(c.ahead), func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			for i := uint16(0); i < 16; i++ {
+				apu.WriteByte(wavePatternRamStart+i, byte(i*0x11))
+			}
+			apu.WriteByte(NR52, 0x80)
+			apu.WriteByte(NR30, 0x80)
+			apu.WriteByte(NR33, 0xFE)
+			apu.WriteByte(NR34, 0x87)
+
+			apu.Clock = accessClock(c.ahead)
+			if value := apu.ReadByte(wavePatternRamStart); value != c.expected {
+				t.Errorf("wave RAM should have read %#x but read %#x", c.expected, value)
+			}
+		})
+	}
+}
+
+func TestWaveTickChunks(t *testing.T) {
+	// The wave channel reads wave RAM on the same cycles however many cycles it is run for at a time
+	var states [][3]int
+	for _, chunk := range []int{1, 2, 3, 4, 24} {
+		apu := NewAPU(mmu.NewMMU())
+		apu.WriteByte(NR52, 0x80)
+		apu.WriteByte(NR30, 0x80)
+		apu.WriteByte(NR33, 0xF3)
+		apu.WriteByte(NR34, 0x87)
+
+		for cycles := 0; cycles < 9984; cycles += chunk {
+			apu.Tick(chunk)
+		}
+		c := apu.channel3
+		states = append(states, [3]int{int(c.position), c.sinceRead, c.timer})
+	}
+
+	for i, state := range states {
+		if i > 0 && state != states[0] {
+			t.Errorf("position, cycles since read and timer should have been %v but were %v", states[0], state)
+		}
+	}
+}
+
+func TestWaveTriggerCorruption(t *testing.T) {
+	cases := []struct {
+		name     string
+		model    Model
+		position byte
+		timer    int
+		expected [4]byte
+	}{
+		{"First four bytes", DMG, 1, 2, [4]byte{0x11, 0x11, 0x22, 0x33}},
+		{"Later bytes", DMG, 9, 2, [4]byte{0x44, 0x55, 0x66, 0x77}},
+		{"Not reading", DMG, 9, 100, [4]byte{0x00, 0x11, 0x22, 0x33}},
+		{"CGB", CGB, 9, 2, [4]byte{0x00, 0x11, 0x22, 0x33}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			apu := NewAPU(mmu.NewMMU())
+			apu.Model = c.model
+			for i := uint16(0); i < 16; i++ {
+				apu.WriteByte(wavePatternRamStart+i, byte(i*0x11))
+			}
+			apu.WriteByte(NR52, 0x80)
+			apu.WriteByte(NR30, 0x80)
+			apu.WriteByte(NR34, 0x80)
+
+			apu.channel3.position = c.position
+			apu.channel3.timer = c.timer
+			apu.WriteByte(NR34, 0x80)
+
+			var ram [4]byte
+			copy(ram[:], apu.channel3.wavePatternRAM[:4])
+			if ram != c.expected {
+				t.Errorf("wave RAM should have been %#v but was %#v", c.expected, ram)
+			}
+		})
+	}
+}
+
+func TestStateWrites(t *testing.T) {
+	apu := NewAPU(mmu.NewMMU())
+	apu.WriteByte(NR52, 0x80)
+	apu.WriteByte(NR12, 0xF0)
+	apu.WriteByte(NR14, 0xC7)
+	apu.WriteByte(NR22, 0x00)
+	apu.WriteByte(NR24, 0x87)
+
+	writes := map[uint16]byte{}
+	for _, write := range apu.StateWrites() {
+		writes[write.Addr] = write.Value
+	}
+
+	cases := []struct {
+		addr     uint16
+		expected byte
+	}{
+		{NR52, 0x80},
+		{NR12, 0xF0},
+		// Playing channels are triggered again, stopped channels aren't
+		{NR14, 0xC7},
+		{NR24, 0x07},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			if value := writes[c.addr]; value != c.expected {
+				t.Errorf("%#x should have been written with %#x but was written with %#x", c.addr, c.expected, value)
+			}
+		})
+	}
+}
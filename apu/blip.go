@@ -0,0 +1,133 @@
+package apu
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	// blipTaps is the width of a band-limited step in output samples,
+	// steps are delayed by half this to keep the filter causal
+	blipTaps = 16
+	// blipPhases is the number of sub-sample positions the step is precomputed for
+	blipPhases = 64
+	// blipCutoff is the highest frequency kept, limited to the audible range
+	// and to just below the Nyquist frequency of the output sample rate
+	blipCutoff = 20000
+)
+
+// blipBuffer synthesizes band-limited audio from changes in amplitude at clock
+// resolution. Each change is added as a windowed-sinc impulse at its exact
+// sub-sample position, and the output is the running sum of the impulses, so
+// each change becomes a step with no energy above the cutoff to alias.
+type blipBuffer struct {
+	clockRate  int64
+	sampleRate int64
+
+	// now is the current time, measured in units of 1/(clockRate*sampleRate)
+	// seconds from the start of buffer, so clocks and samples are both whole units
+	now int64
+
+	buffer     []float64
+	integrator float64
+
+	kernel *[blipPhases + 1][blipTaps]float64
+}
+
+// blipKernels are the impulses for each sample rate, shared by every blipBuffer.
+// They are only read once made, but APUs can be created on several goroutines.
+var (
+	blipKernels     = map[int]*[blipPhases + 1][blipTaps]float64{}
+	blipKernelsLock sync.Mutex
+)
+
+func newBlipBuffer(clockRate, sampleRate int) *blipBuffer {
+	blipKernelsLock.Lock()
+	kernel, ok := blipKernels[sampleRate]
+	if !ok {
+		kernel = newBlipKernel(sampleRate)
+		blipKernels[sampleRate] = kernel
+	}
+	blipKernelsLock.Unlock()
+
+	return &blipBuffer{
+		clockRate:  int64(clockRate),
+		sampleRate: int64(sampleRate),
+		buffer:     make([]float64, blipTaps),
+		kernel:     kernel,
+	}
+}
+
+// newBlipKernel returns a Blackman windowed-sinc low-pass impulse
+// for each sub-sample phase, normalized so each step has a height of 1
+func newBlipKernel(sampleRate int) *[blipPhases + 1][blipTaps]float64 {
+	cutoff := math.Min(blipCutoff, 0.45*float64(sampleRate)) / float64(sampleRate)
+
+	var kernel [blipPhases + 1][blipTaps]float64
+	for phase := range kernel {
+		var sum float64
+		for tap := range kernel[phase] {
+			x := float64(tap) - (blipTaps/2 - 1) - float64(phase)/blipPhases
+
+			sinc := 2 * cutoff
+			if x != 0 {
+				sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+			}
+			w := 2 * math.Pi * (x + blipTaps/2) / blipTaps
+			window := 0.42 - 0.5*math.Cos(w) + 0.08*math.Cos(2*w)
+
+			kernel[phase][tap] = sinc * window
+			sum += kernel[phase][tap]
+		}
+		for tap := range kernel[phase] {
+			kernel[phase][tap] /= sum
+		}
+	}
+	return &kernel
+}
+
+// addDelta adds a change in amplitude at the current time
+func (b *blipBuffer) addDelta(delta float64) {
+	sample := b.now / b.clockRate
+	phase := (b.now % b.clockRate) * blipPhases / b.clockRate
+
+	for int(sample)+blipTaps > len(b.buffer) {
+		b.buffer = append(b.buffer, 0)
+	}
+
+	taps := b.buffer[sample : sample+blipTaps]
+	for i, k := range b.kernel[phase] {
+		taps[i] += delta * k
+	}
+}
+
+// advance moves the current time forward by clocks
+func (b *blipBuffer) advance(clocks int) {
+	b.now += int64(clocks) * b.sampleRate
+}
+
+// available returns the number of samples that can no longer be changed by new deltas
+func (b *blipBuffer) available() int {
+	return int(b.now / b.clockRate)
+}
+
+// read removes n samples from the buffer and appends them to samples
+func (b *blipBuffer) read(samples []float64, n int) []float64 {
+	for len(b.buffer) < n+blipTaps {
+		b.buffer = append(b.buffer, 0)
+	}
+
+	for _, delta := range b.buffer[:n] {
+		b.integrator += delta
+		samples = append(samples, b.integrator)
+	}
+
+	remaining := copy(b.buffer, b.buffer[n:])
+	for i := remaining; i < len(b.buffer); i++ {
+		b.buffer[i] = 0
+	}
+	b.buffer = b.buffer[:remaining]
+
+	b.now -= int64(n) * b.clockRate
+	return samples
+}
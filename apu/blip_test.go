@@ -0,0 +1,90 @@
+package apu
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/cpu"
+)
+
+func TestBlipStep(t *testing.T) {
+	cases := []struct {
+		sampleRate int
+	}{
+		{44100},
+		{48000},
+		{96000},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			blip := newBlipBuffer(cpu.ClockSpeed, c.sampleRate)
+
+			blip.addDelta(1000)
+			blip.advance(cpu.ClockSpeed / 100)
+			samples := blip.read(nil, blip.available())
+
+			// The step is centred on the kernel, so it starts after a few samples of latency
+			// with only a little ringing before it
+			if math.Abs(samples[0]) > 10 {
+				t.Errorf("first sample should have been close to 0 but was %v", samples[0])
+			}
+			if samples[blipTaps/2] < 500 {
+				t.Errorf("sample %v should have been past half of the step but was %v", blipTaps/2, samples[blipTaps/2])
+			}
+
+			last := samples[len(samples)-1]
+			if math.Abs(last-1000) > 1e-6 {
+				t.Errorf("step should have settled at 1000 but was %v", last)
+			}
+		})
+	}
+}
+
+func TestBlipSampleCount(t *testing.T) {
+	cases := []struct {
+		sampleRate int
+	}{
+		{44100},
+		{48000},
+		{96000},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			blip := newBlipBuffer(cpu.ClockSpeed, c.sampleRate)
+
+			// 1 second in uneven steps
+			var samples []float64
+			for clocks := 0; clocks < cpu.ClockSpeed; clocks += 12 {
+				blip.addDelta(1)
+				blip.advance(12)
+				samples = blip.read(samples, blip.available())
+			}
+			blip.advance(cpu.ClockSpeed % 12)
+			samples = blip.read(samples, blip.available())
+
+			if len(samples) != c.sampleRate {
+				t.Errorf("%v samples should have been read but were %v", c.sampleRate, len(samples))
+			}
+		})
+	}
+}
+
+func TestBlipKernelsShared(t *testing.T) {
+	// Buffers made on several goroutines at once share one kernel per sample rate
+	kernels := make(chan *[blipPhases + 1][blipTaps]float64, 16)
+	for i := 0; i < cap(kernels); i++ {
+		go func(sampleRate int) {
+			kernels <- newBlipBuffer(cpu.ClockSpeed, sampleRate).kernel
+		}(22050 + i%2)
+	}
+
+	seen := map[*[blipPhases + 1][blipTaps]float64]bool{}
+	for i := 0; i < cap(kernels); i++ {
+		seen[<-kernels] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("there should have been 2 kernels but there were %v", len(seen))
+	}
+}
@@ -16,6 +16,43 @@ type Channel struct {
 	frequencyShadow uint16
 }
 
+// writeLength loads the length counter with max - data, where
+// data is as many of the low bits of value as max needs
+func (c *Channel) writeLength(value byte, max int) {
+	c.length = max - int(value)&(max-1)
+}
+
+// writeLengthEnable sets whether the length counter is enabled from NRx4
+func (c *Channel) writeLengthEnable(enable bool, trigger bool, frameSequencerStep int) {
+	// If the length counter is enabled when the frame sequencer's next
+	// step is one that doesn't clock it, it is clocked an extra time.
+	// If that makes it zero and the channel isn't being triggered, the channel is disabled.
+	if !c.lengthEnable && enable && frameSequencerStep%2 != 0 && c.length > 0 {
+		c.length--
+
+		if c.length == 0 && !trigger {
+			c.enable = false
+		}
+	}
+
+	c.lengthEnable = enable
+}
+
+// triggerLength reloads the length counter with max if it is zero when the channel is triggered
+func (c *Channel) triggerLength(max int, frameSequencerStep int) {
+	if c.length == 0 {
+		c.length = max
+		// If a channel is triggered when the frame sequencer's
+		// next step is one that doesn't clock the length counter
+		// and the length counter is now enabled and length is
+		// being set to 64 (256 for wave channel) because it was
+		// previously zero, it is set to 63 instead (255 for wave channel).
+		if frameSequencerStep%2 != 0 && c.lengthEnable {
+			c.length--
+		}
+	}
+}
+
 func (c *Channel) TickLength() {
 	if c.lengthEnable && c.length > 0 {
 		c.length--
@@ -43,10 +80,26 @@ type VolumeEnvelope struct {
 	volumeEnvelopeDirection bool
 	volumeEnvelopePeriod    byte
 	volumeEnvelopeTimer     byte
+
+	// The envelope stops once the volume can't change any further
+	volumeEnvelopeActive bool
+}
+
+func (v *VolumeEnvelope) triggerVolumeEnvelope() {
+	// Volume envelope timer is reloaded with period
+	// The volume envelope and sweep timers treat a period of 0 as 8
+	v.volumeEnvelopeTimer = v.volumeEnvelopePeriod
+	if v.volumeEnvelopePeriod == 0 {
+		v.volumeEnvelopeTimer = 8
+	}
+
+	// Channel volume is reloaded from NRx2.
+	v.volume = v.volumeEnvelopeInitial
+	v.volumeEnvelopeActive = true
 }
 
 func (v *VolumeEnvelope) TickVolumeEnvelope() {
-	if v.volumeEnvelopePeriod > 0 {
+	if v.volumeEnvelopePeriod > 0 && v.volumeEnvelopeActive {
 		if v.volumeEnvelopeTimer > 0 {
 			v.volumeEnvelopeTimer--
 		}
@@ -54,10 +107,14 @@ func (v *VolumeEnvelope) TickVolumeEnvelope() {
 			if v.volumeEnvelopeDirection {
 				if v.volume < 0xF {
 					v.volume++
+				} else {
+					v.volumeEnvelopeActive = false
 				}
 			} else {
 				if v.volume > 0 {
 					v.volume--
+				} else {
+					v.volumeEnvelopeActive = false
 				}
 			}
 
@@ -83,6 +140,27 @@ func (v *VolumeEnvelope) volumeEnvelopeReadByte() byte {
 	return value
 }
 
+// zombieWrite changes the volume of a playing channel when NRx2 is written, which
+// some games use to change the volume without retriggering ("zombie" mode):
+//   - If the old envelope period was zero and the envelope is still doing automatic
+//     updates, volume is incremented by 1, otherwise if the envelope was in subtract
+//     mode, volume is incremented by 2.
+//   - If the mode was changed (add to subtract or subtract to add), volume is set to 16-volume.
+//   - Only the low 4 bits of volume are kept after the above operations.
+func (v *VolumeEnvelope) zombieWrite(value byte) {
+	if v.volumeEnvelopePeriod == 0 && v.volumeEnvelopeActive {
+		v.volume++
+	} else if !v.volumeEnvelopeDirection {
+		v.volume += 2
+	}
+
+	if v.volumeEnvelopeDirection != utils.IsBitSet(value, 3) {
+		v.volume = 16 - v.volume
+	}
+
+	v.volume &= 0xF
+}
+
 func (v *VolumeEnvelope) volumeEnvelopeWriteByte(value byte) {
 	// Bit 7-4 - Initial Volume of envelope (0-0Fh) (0=No Sound)
 	// Bit 3   - Envelope Direction (0=Decrease, 1=Increase)
@@ -126,7 +204,7 @@ func (c *Square) Tick(tCycles int) {
 }
 
 func (c *Square) sample() byte {
-	if !c.enable && !c.DACEnable {
+	if !c.enable {
 		return 0
 	}
 
@@ -175,8 +253,11 @@ func (c *Square) WriteByte(addr uint16, value byte) {
 		c.wavePatternDuty = (value >> 6) & 0x3
 
 		// Writing a byte to NRx1 loads the length counter with 64 - data
-		c.length = 64 - int(value&0x3F)
+		c.writeLength(value, 64)
 	case NR12, NR22:
+		if c.enable {
+			c.zombieWrite(value)
+		}
 		c.volumeEnvelopeWriteByte(value)
 		c.DACEnable = (value & 0xf8) > 0
 
@@ -21,30 +21,13 @@ func (c *NoiseChannel) trigger(frameSequencerStep int) {
 	c.enable = true
 
 	// If length counter is zero, it is set to 64.
-	if c.length == 0 {
-		c.length = 64
-		// If a channel is triggered when the frame sequencer's
-		// next step is one that doesn't clock the length counter
-		// and the length counter is now enabled and length is
-		// being set to 64 (256 for wave channel) because it was
-		// previously zero, it is set to 63 instead (255 for wave channel).
-		if frameSequencerStep%2 != 0 && c.lengthEnable {
-			c.length--
-		}
-	}
+	c.triggerLength(64, frameSequencerStep)
 
 	// Frequency timer is reloaded with period.
 	c.timer = c.getDividingRatio() << c.shiftClockFrequency
 
-	// Volume envelope timer is reloaded with period
-	// The volume envelope and sweep timers treat a period of 0 as 8
-	c.volumeEnvelopeTimer = c.volumeEnvelopePeriod
-	if c.volumeEnvelopePeriod == 0 {
-		c.volumeEnvelopeTimer = 8
-	}
-
-	// Channel volume is reloaded from NRx2.
-	c.volume = c.volumeEnvelopeInitial
+	// Volume envelope timer is reloaded with period and channel volume is reloaded from NRx2.
+	c.triggerVolumeEnvelope()
 
 	// Noise channel's LFSR bits are all set to 1.
 	c.LFSR = 0x7FFF
@@ -145,8 +128,11 @@ func (c *NoiseChannel) WriteByte(addr uint16, value byte) {
 		// Bit 5-0 - Sound length data
 
 		// Writing a byte to NRx1 loads the length counter with 64 - data
-		c.length = 64 - int(value&0x3f)
+		c.writeLength(value, 64)
 	case NR42:
+		if c.enable {
+			c.zombieWrite(value)
+		}
 		c.volumeEnvelopeWriteByte(value)
 		c.DACEnable = (value & 0xf8) > 0
 
@@ -165,10 +151,11 @@ func (c *NoiseChannel) WriteTriggerByte(value byte, frameSequencerStep int) {
 	// Bit 7   - Initial (1=Restart Sound)
 	// Bit 6   - Counter/consecutive selection
 	// 		  (1=Stop output when length in NR11 expires)
-	c.lengthEnable = utils.IsBitSet(value, 6)
+	trigger := utils.IsBitSet(value, 7)
+	c.writeLengthEnable(utils.IsBitSet(value, 6), trigger, frameSequencerStep)
 
 	// Make sure we trigger after lengthEnable is set
-	if utils.IsBitSet(value, 7) {
+	if trigger {
 		c.trigger(frameSequencerStep)
 	}
 }
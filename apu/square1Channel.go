@@ -12,37 +12,23 @@ type Square1Channel struct {
 	sweepPeriod byte
 	sweepNegate bool
 	sweepShift  byte
+
+	// Set once a sweep calculation has been made in negate mode since the channel was triggered
+	sweepNegateUsed bool
 }
 
 func (c *Square1Channel) trigger(frameSequencerStep int) {
 	c.enable = true
 
-	if c.length == 0 {
-		c.length = 64
-		// If a channel is triggered when the frame sequencer's
-		// next step is one that doesn't clock the length counter
-		// and the length counter is now enabled and length is
-		// being set to 64 (256 for wave channel) because it was
-		// previously zero, it is set to 63 instead (255 for wave channel).
-		if frameSequencerStep%2 == 0 && c.lengthEnable {
-			c.length--
-		}
-	}
+	c.triggerLength(64, frameSequencerStep)
 
 	c.timer = (2048 - int(c.frequency)) * 4
 
-	// Volume envelope timer is reloaded with period
-	// The volume envelope and sweep timers treat a period of 0 as 8
-	c.volumeEnvelopeTimer = c.volumeEnvelopePeriod
-	if c.volumeEnvelopePeriod == 0 {
-		c.volumeEnvelopeTimer = 8
-	}
-
-	c.volume = c.volumeEnvelopeInitial
+	c.triggerVolumeEnvelope()
 
 	c.frequencyShadow = c.frequency
 
-	c.sweepNegate = false
+	c.sweepNegateUsed = false
 
 	// The volume envelope and sweep timers treat a period of 0 as 8
 	c.sweepTimer = c.sweepPeriod
@@ -71,11 +57,12 @@ func (c *Square1Channel) WriteTriggerByte(value byte, frameSequencerStep int) {
 	// Bit 6   - Counter/consecutive selection
 	// 		  (1=Stop output when length in NR11 expires)
 	// Bit 2-0 - Frequency's higher 3 bits (x)
-	c.lengthEnable = utils.IsBitSet(value, 6)
+	trigger := utils.IsBitSet(value, 7)
+	c.writeLengthEnable(utils.IsBitSet(value, 6), trigger, frameSequencerStep)
 	c.writeFrequencyHigherBits(value)
 
 	// Make sure we trigger after the lengthEnable and Higher frequency bits are set
-	if utils.IsBitSet(value, 7) {
+	if trigger {
 		c.trigger(frameSequencerStep)
 	}
 }
@@ -86,6 +73,7 @@ func (c *Square1Channel) calculateSweep() uint16 {
 
 	if c.sweepNegate {
 		newFrequency = -newFrequency
+		c.sweepNegateUsed = true
 	}
 
 	newFrequency += int(c.frequencyShadow)
@@ -147,7 +135,9 @@ func (c *Square1Channel) WriteSweep(value byte) {
 	// 	0: Addition    (frequency increases)
 	// 	1: Subtraction (frequency decreases)
 	// Bit 2-0 - Number of sweep shift (n: 0-7)
-	if c.sweepEnable && c.sweepNegate && !utils.IsBitSet(value, 3) {
+	// Clearing negate mode after a calculation has been made
+	// in negate mode since the last trigger disables the channel
+	if c.sweepNegateUsed && !utils.IsBitSet(value, 3) {
 		c.enable = false
 	}
 
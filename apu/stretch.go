@@ -0,0 +1,86 @@
+package apu
+
+import "math"
+
+// stretchGrain is the length of each grain in seconds, long enough to hold a few
+// periods of the lowest notes and short enough that the grains aren't heard as echoes
+const stretchGrain = 0.03
+
+// Stretcher is a sink that time-stretches stereo samples by Speed before sending them to
+// another sink, so sound played faster or slower than normal keeps its pitch. It overlaps
+// and adds Hann windowed grains of the input at half a grain apart in the output, taken
+// Speed times further apart in the input, which sums back to the input at a Speed of 1.
+type Stretcher struct {
+	// Speed is how many times faster than normal the input is played
+	Speed float64
+
+	sink   Sink
+	window []float64
+
+	// input holds the interleaved samples not yet used, pos is the
+	// frame the next grain starts at, which can fall between frames
+	input []float64
+	pos   float64
+	// overlap holds the sum of the grains being overlapped, the first hop of which is complete
+	overlap []float64
+	output  []int16
+}
+
+func NewStretcher(sink Sink, sampleRate int) *Stretcher {
+	grain := int(stretchGrain*float64(sampleRate)) &^ 1
+
+	window := make([]float64, grain)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(grain))
+	}
+
+	return &Stretcher{
+		Speed:   1,
+		sink:    sink,
+		window:  window,
+		overlap: make([]float64, grain*2),
+	}
+}
+
+// WriteSamples stretches interleaved left and right samples, sending each
+// half grain of output to the sink once the grains over it have been added
+func (s *Stretcher) WriteSamples(samples []int16) {
+	for _, sample := range samples {
+		s.input = append(s.input, float64(sample))
+	}
+
+	grain := len(s.window)
+	hop := grain / 2
+	speed := math.Max(s.Speed, 0.01)
+
+	s.output = s.output[:0]
+	for int(s.pos)+grain <= len(s.input)/2 {
+		start := int(s.pos) * 2
+		for i, w := range s.window {
+			s.overlap[i*2] += s.input[start+i*2] * w
+			s.overlap[i*2+1] += s.input[start+i*2+1] * w
+		}
+
+		for _, sample := range s.overlap[:hop*2] {
+			s.output = append(s.output, clampSample(sample))
+		}
+		copy(s.overlap, s.overlap[hop*2:])
+		for i := grain; i < len(s.overlap); i++ {
+			s.overlap[i] = 0
+		}
+
+		s.pos += float64(hop) * speed
+	}
+
+	// Drop the input before the next grain
+	used := int(s.pos)
+	if used > len(s.input)/2 {
+		used = len(s.input) / 2
+	}
+	s.input = s.input[:copy(s.input, s.input[used*2:])]
+	s.pos -= float64(used)
+
+	if len(s.output) > 0 {
+		s.sink.WriteSamples(s.output)
+	}
+}
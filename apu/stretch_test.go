@@ -0,0 +1,65 @@
+package apu
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStretchLength(t *testing.T) {
+	cases := []struct {
+		speed float64
+	}{
+		{0.25},
+		{0.5},
+		{1},
+		{2},
+		{8},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			sink := &testSink{}
+			s := NewStretcher(sink, 48000)
+			s.Speed = c.speed
+
+			// 10 seconds, written a frame at a time
+			input := make([]int16, 800*2)
+			for i := 0; i < 600; i++ {
+				s.WriteSamples(input)
+			}
+
+			expected := 480000 / c.speed
+			// Up to a grain is held back
+			if frames := float64(len(sink.samples) / 2); math.Abs(frames-expected) > 48000*stretchGrain*math.Max(1, 1/c.speed) {
+				t.Errorf("10 seconds at %vx should have been stretched to %v frames but was %v", c.speed, expected, frames)
+			}
+		})
+	}
+}
+
+func TestStretchLevel(t *testing.T) {
+	for _, speed := range []float64{0.5, 1, 3} {
+		sink := &testSink{}
+		s := NewStretcher(sink, 48000)
+		s.Speed = speed
+
+		// A constant level stays the same, once the first half grain has faded in
+		input := make([]int16, 4800*2)
+		for i := range input {
+			input[i] = 1000
+			if i%2 == 1 {
+				input[i] = -1000
+			}
+		}
+		for i := 0; i < 10; i++ {
+			s.WriteSamples(input)
+		}
+
+		for i := 48000 * stretchGrain; i < float64(len(sink.samples)/2); i++ {
+			left, right := sink.samples[int(i)*2], sink.samples[int(i)*2+1]
+			if left != 1000 || right != -1000 {
+				t.Fatalf("frame %v at %vx should have been 1000, -1000 but was %v, %v", i, speed, left, right)
+			}
+		}
+	}
+}
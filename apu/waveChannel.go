@@ -4,6 +4,10 @@ import (
 	"github.com/kevinbrolly/GopherBoy/utils"
 )
 
+// waveReadWindow is the number of cycles the DMG lets the CPU access wave RAM for after
+// the wave channel reads it while playing, one cycle of the APU's 2 MiHz clock
+const waveReadWindow = 2
+
 type WaveChannel struct {
 	Channel
 
@@ -11,28 +15,23 @@ type WaveChannel struct {
 	position byte
 	buffer   byte
 
+	// sinceRead is the number of cycles since the channel last read wave RAM
+	sinceRead int
+
 	wavePatternRAM [16]byte
 }
 
 func (c *WaveChannel) trigger(frameSequencerStep int) {
 	c.enable = true
 
-	if c.length == 0 {
-		c.length = 256
-		// If a channel is triggered when the frame sequencer's
-		// next step is one that doesn't clock the length counter
-		// and the length counter is now enabled and length is
-		// being set to 64 (256 for wave channel) because it was
-		// previously zero, it is set to 63 instead (255 for wave channel).
-		if frameSequencerStep%2 != 0 && c.lengthEnable {
-			c.length--
-		}
-	}
+	c.triggerLength(256, frameSequencerStep)
 
-	c.timer = (2048 - int(c.frequency)) * 2
+	// The first sample is read 6 cycles later than the timer period
+	c.timer = (2048-int(c.frequency))*2 + 6
 
 	// Wave channel's position is set to 0 but sample buffer is NOT refilled.
 	c.position = 0
+	c.sinceRead = waveReadWindow
 
 	// Note that if the channel's DAC is off, after the above actions occur the channel will be immediately disabled again.
 	if !c.DACEnable {
@@ -41,30 +40,98 @@ func (c *WaveChannel) trigger(frameSequencerStep int) {
 }
 
 func (c *WaveChannel) Tick(tCycles int) {
-	if c.timer > 0 {
-		c.timer -= tCycles
+	var read bool
+	c.position, c.sinceRead, c.timer, read = c.advance(tCycles)
+	if !read {
+		return
 	}
-	if c.timer <= 0 {
-		// Fill the sample buffer
-		// wavePatternRAM is 16 bytes, position is length 32
-		// position / 2 = wavePatternRAM index
-		wavePatternByte := c.wavePatternRAM[c.position/2]
-		// wavePatternByte holds 2 4-bit samples
-		// if position is even the low nibble is used, if odd the high nibble is used
-		if c.position%2 == 0 {
-			c.buffer = wavePatternByte & 0xF
-		} else {
-			c.buffer = wavePatternByte >> 4
-		}
+
+	// Fill the sample buffer
+	// wavePatternRAM is 16 bytes, position is length 32
+	// position / 2 = wavePatternRAM index
+	wavePatternByte := c.wavePatternRAM[c.position/2]
+	// wavePatternByte holds 2 4-bit samples
+	// if position is even the high nibble is used, if odd the low nibble is used
+	if c.position%2 == 0 {
+		c.buffer = wavePatternByte >> 4
+	} else {
+		c.buffer = wavePatternByte & 0xF
+	}
+}
+
+// advance returns the position, the cycles since wave RAM was read and the timer as they
+// will be after another tCycles cycles, and whether wave RAM is read in that time. Each read
+// is made on the exact cycle the timer runs out, however many cycles are advanced at a time.
+func (c *WaveChannel) advance(tCycles int) (position byte, sinceRead int, timer int, read bool) {
+	position, sinceRead, timer = c.position, c.sinceRead+tCycles, c.timer-tCycles
+	for timer <= 0 {
+		// The position is advanced before the sample is read, so
+		// the first sample played after a trigger is sample 1
+		position = (position + 1) % 32
+		sinceRead = -timer
+		read = true
 
 		// Reload timer
-		c.timer = (2048 - int(c.frequency)) * 2
+		timer += (2048 - int(c.frequency)) * 2
+	}
+	return position, sinceRead, timer, read
+}
 
-		c.position++
-		if c.position == 32 {
-			c.position = 0
-		}
+// readingRAM returns true if the channel reads wave RAM within waveReadWindow cycles
+// before ahead more cycles have run, along with the position it is reading
+func (c *WaveChannel) readingRAM(ahead int) (position byte, reading bool) {
+	position, sinceRead, _, _ := c.advance(ahead)
+	return position, sinceRead < waveReadWindow
+}
+
+// corruptWaveRAM emulates the DMG overwriting the start of wave RAM when the channel is
+// triggered, ahead cycles from now, as it reads a sample. If the channel was reading one of
+// the first four bytes, only the first byte is rewritten with the byte being read. If it was
+// reading one of the later 12 bytes, the first four bytes are rewritten with the four aligned
+// bytes the read was from.
+func (c *WaveChannel) corruptWaveRAM(ahead int) {
+	position, _, timer, _ := c.advance(ahead)
+	if !c.enable || timer > waveReadWindow {
+		return
+	}
+
+	index := ((position + 1) % 32) / 2
+	if index < 4 {
+		c.wavePatternRAM[0] = c.wavePatternRAM[index]
+	} else {
+		aligned := index &^ 3
+		copy(c.wavePatternRAM[:4], c.wavePatternRAM[aligned:aligned+4])
+	}
+}
+
+// ReadWaveRAM reads a byte of wave RAM, ahead cycles from now. If the wave channel is
+// enabled, accessing any byte from $FF30-$FF3F is equivalent to accessing the current byte
+// selected by the waveform position. On the DMG accesses will only work in this manner if
+// made within waveReadWindow cycles of the wave channel reading wave RAM, at any other time
+// reads return $FF.
+func (c *WaveChannel) ReadWaveRAM(addr uint16, dmg bool, ahead int) byte {
+	if !c.enable {
+		return c.wavePatternRAM[addr&0xF]
+	}
+	position, reading := c.readingRAM(ahead)
+	if dmg && !reading {
+		return 0xFF
 	}
+	return c.wavePatternRAM[position/2]
+}
+
+// WriteWaveRAM writes a byte of wave RAM, ahead cycles from now, with the same restrictions as
+// ReadWaveRAM while the wave channel is enabled, where writes at the wrong time on the DMG have no effect
+func (c *WaveChannel) WriteWaveRAM(addr uint16, value byte, dmg bool, ahead int) {
+	if !c.enable {
+		c.wavePatternRAM[addr&0xF] = value
+		return
+	}
+	position, reading := c.readingRAM(ahead)
+	if dmg && !reading {
+		return
+	}
+	c.wavePatternRAM[position/2] = value
 }
 
 func (c *WaveChannel) sample() byte {
@@ -111,14 +178,6 @@ func (c *WaveChannel) ReadByte(addr uint16) byte {
 		if c.lengthEnable {
 			value = utils.SetBit(value, 6)
 		}
-	case addr >= 0xFF30 && addr <= 0xFF3F:
-		// If the wave channel is enabled, accessing any byte from $FF30-$FF3F is equivalent
-		// to accessing the current byte selected by the waveform position.
-		if c.enable {
-			value = c.wavePatternRAM[c.position]
-		} else {
-			value = c.wavePatternRAM[addr&0xF]
-		}
 	}
 
 	return value
@@ -136,22 +195,12 @@ func (c *WaveChannel) WriteByte(addr uint16, value byte) {
 		}
 	case addr == NR31:
 		// Writing a byte to NRx1 loads the wave channel length counter with 256 - data
-		c.length = 256 - int(value)
+		c.writeLength(value, 256)
 	case addr == NR32:
 		// Bit 6-5 - Select output level
 		c.volume = (value >> 5) & 0x3
 	case addr == NR33:
 		c.writeFrequencyLowerBits(value)
-	case addr >= 0xFF30 && addr <= 0xFF3F:
-		// If the wave channel is enabled, accessing any byte from $FF30-$FF3F is equivalent
-		// to accessing the current byte selected by the waveform position.
-		// On the DMG accesses will only work in this manner if made within a couple of clocks
-		// of the wave channel accessing wave RAM; if made at any other time, reads return $FF and writes have no effect.
-		if c.enable {
-			c.wavePatternRAM[c.position] = value
-		} else {
-			c.wavePatternRAM[addr&0xF] = value
-		}
 	}
 }
 
@@ -160,12 +209,13 @@ func (c *WaveChannel) WriteTriggerByte(value byte, frameSequencerStep int) {
 	// Bit 6   - Counter/consecutive selection
 	// 		  (1=Stop output when length in NR34 expires)
 	// Bit 2-0 - Frequency's higher 3 bits (x)
-	c.lengthEnable = utils.IsBitSet(value, 6)
+	trigger := utils.IsBitSet(value, 7)
+	c.writeLengthEnable(utils.IsBitSet(value, 6), trigger, frameSequencerStep)
 
 	c.writeFrequencyHigherBits(value)
 
 	// Make sure we trigger after the lengthEnable and Higher frequency bits are set
-	if utils.IsBitSet(value, 7) {
+	if trigger {
 		c.trigger(frameSequencerStep)
 	}
 }
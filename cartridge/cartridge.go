@@ -1,6 +1,8 @@
 package cartridge
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"io/ioutil"
 	"log"
 
@@ -39,6 +41,12 @@ func NewCartridge(filename string, mmu *mmu.MMU) (cartridge *Cartridge) {
 	return cartridge
 }
 
+// Hash returns the SHA-1 of the ROM in hex, which identifies the game
+func (c *Cartridge) Hash() string {
+	hash := sha1.Sum(c.data)
+	return hex.EncodeToString(hash[:])
+}
+
 func (c *Cartridge) Type() byte {
 	return c.data[0x147]
 }
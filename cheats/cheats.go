@@ -0,0 +1,185 @@
+package cheats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+// Engine applies cheats to a Game Boy. It sits between the CPU and the cartridge's ROM,
+// so Game Genie codes patch the ROM as it is read through the MBC like a real Game Genie,
+// and writes GameShark codes' values to RAM every time Apply is called at VBlank.
+type Engine struct {
+	Cheats []*Cheat
+
+	mmu *mmu.MMU
+	// rom is the memory mapped at each ROM address before the engine, usually the cartridge's MBC
+	rom [0x8000]mmu.Memory
+}
+
+// NewEngine maps the engine in front of the ROM, it must be created after the cartridge is loaded
+func NewEngine(m *mmu.MMU) *Engine {
+	e := &Engine{mmu: m}
+
+	for addr := range e.rom {
+		e.rom[addr] = m.MemoryAt(uint16(addr))
+	}
+	m.MapMemoryRange(e, 0x0000, 0x7FFF)
+
+	return e
+}
+
+// Add decodes and adds an enabled cheat
+func (e *Engine) Add(code, name string) (*Cheat, error) {
+	cheat, err := Parse(code)
+	if err != nil {
+		return nil, err
+	}
+
+	cheat.Name = name
+	e.Cheats = append(e.Cheats, cheat)
+	return cheat, nil
+}
+
+// Remove removes the cheat at index
+func (e *Engine) Remove(index int) error {
+	if index < 0 || index >= len(e.Cheats) {
+		return fmt.Errorf("cheat should have been from 0 to %v but was %v", len(e.Cheats)-1, index)
+	}
+
+	e.Cheats = append(e.Cheats[:index], e.Cheats[index+1:]...)
+	return nil
+}
+
+// SetEnabled turns the cheat at index on or off
+func (e *Engine) SetEnabled(index int, enabled bool) error {
+	if index < 0 || index >= len(e.Cheats) {
+		return fmt.Errorf("cheat should have been from 0 to %v but was %v", len(e.Cheats)-1, index)
+	}
+
+	e.Cheats[index].Enabled = enabled
+	return nil
+}
+
+// Apply writes the values of the enabled GameShark codes to RAM, it should be called every VBlank
+func (e *Engine) Apply() {
+	for _, cheat := range e.Cheats {
+		if cheat.Enabled && cheat.Kind == GameShark {
+			e.mmu.WriteByte(cheat.Address, cheat.Value)
+		}
+	}
+}
+
+// ReadByte reads the ROM through the enabled Game Genie codes. A code with a compare value
+// only replaces the value it is compared with, so it leaves the other banks alone.
+func (e *Engine) ReadByte(addr uint16) byte {
+	var value byte
+	if rom := e.rom[addr&0x7FFF]; rom != nil {
+		value = rom.ReadByte(addr)
+	}
+
+	for _, cheat := range e.Cheats {
+		if cheat.Kind == GameGenie && cheat.Enabled && cheat.Address == addr && (!cheat.HasCompare || cheat.Compare == value) {
+			return cheat.Value
+		}
+	}
+	return value
+}
+
+// WriteByte passes writes through to the MBC, such as bank switches
+func (e *Engine) WriteByte(addr uint16, value byte) {
+	if rom := e.rom[addr&0x7FFF]; rom != nil {
+		rom.WriteByte(addr, value)
+	}
+}
+
+// Filename returns the cheat list file for a ROM, the ROM's filename with a .cht extension
+func Filename(rom string) string {
+	return strings.TrimSuffix(rom, filepath.Ext(rom)) + ".cht"
+}
+
+// Load adds the cheats from a cheat list file
+func (e *Engine) Load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cheats, err := Read(f)
+	if err != nil {
+		return err
+	}
+	e.Cheats = append(e.Cheats, cheats...)
+	return nil
+}
+
+// Save writes the cheats to a cheat list file
+func (e *Engine) Save(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = Write(f, e.Cheats)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Read reads a cheat list, with one cheat per line of "on" or "off", the code and
+// an optional name. Blank lines and lines starting with "#" are ignored.
+//
+//	on  01FF42C3    Infinite lives
+//	off 00A-17B-C49 Moon jump
+func Read(r io.Reader) ([]*Cheat, error) {
+	var cheats []*Cheat
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip blank lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || (fields[0] != "on" && fields[0] != "off") {
+			return nil, fmt.Errorf("line %v should have been on or off and a code but was %q", n, line)
+		}
+
+		cheat, err := Parse(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", n, err)
+		}
+		cheat.Enabled = fields[0] == "on"
+		cheat.Name = strings.Join(fields[2:], " ")
+
+		cheats = append(cheats, cheat)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return cheats, nil
+}
+
+// Write writes a cheat list in the format read by Read
+func Write(w io.Writer, cheats []*Cheat) error {
+	bw := bufio.NewWriter(w)
+	for _, cheat := range cheats {
+		state := "off"
+		if cheat.Enabled {
+			state = "on"
+		}
+		line := fmt.Sprintf("%-3s %-11s %s", state, cheat.Code, cheat.Name)
+		fmt.Fprintln(bw, strings.TrimSpace(line))
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,176 @@
+package cheats
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		code     string
+		expected Cheat
+	}{
+		// AB = 0x01, address F^0xF CDE = 0x0A17 (F=F), GI = 0xC9
+		{"01A-17F-C49", Cheat{Kind: GameGenie, Value: 0x01, Address: 0x0A17, Compare: (0xC9>>2 | 0xC9<<6&0xFF) ^ 0xBA, HasCompare: true}},
+		{"ff0-00e", Cheat{Kind: GameGenie, Value: 0xFF, Address: 0x1000}},
+		{"01FF42C3", Cheat{Kind: GameShark, Bank: 0x01, Value: 0xFF, Address: 0xC342}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.code, func(t *testing.T) {
+			cheat, err := Parse(c.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cheat.Code = ""
+			cheat.Enabled = false
+			if *cheat != c.expected {
+				t.Errorf("%v should have been %+v but was %+v", c.code, c.expected, *cheat)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []string{
+		"01A-17F-C4",
+		"01A17FC49",
+		"01G-17F-C49",
+		// Address 0x8A17
+		"01A-177-C49",
+		"01FF42",
+		"01FF0040",
+	}
+
+	for _, c := range cases {
+		t.Run(c, func(t *testing.T) {
+			if _, err := Parse(c); err == nil {
+				t.Errorf("%v should have been an error", c)
+			}
+		})
+	}
+}
+
+// bankedROM is a ROM with 0x4000 byte banks selected by writing to 0x2000
+type bankedROM struct {
+	banks [][0x4000]byte
+	bank  int
+}
+
+func (r *bankedROM) ReadByte(addr uint16) byte {
+	if addr < 0x4000 {
+		return r.banks[0][addr]
+	}
+	return r.banks[r.bank][addr-0x4000]
+}
+
+func (r *bankedROM) WriteByte(addr uint16, value byte) {
+	if addr >= 0x2000 && addr < 0x4000 {
+		r.bank = int(value)
+	}
+}
+
+// compareDigits returns the G and I digits of a Game Genie code that compares against value
+func compareDigits(value byte) (byte, byte) {
+	v := value ^ 0xBA
+	v = v<<2 | v>>6
+	return v >> 4, v & 0xF
+}
+
+func TestGameGenie(t *testing.T) {
+	m := mmu.NewMMU()
+	rom := &bankedROM{banks: make([][0x4000]byte, 3), bank: 1}
+	rom.banks[1][0x0123] = 0x11
+	rom.banks[2][0x0123] = 0x22
+	m.MapMemoryRange(rom, 0x0000, 0x7FFF)
+
+	e := NewEngine(m)
+
+	// Replace 0x22 with 0x99 at 0x4123, only in bank 2
+	g, i := compareDigits(0x22)
+	code := []byte("991-23B-000")
+	code[8] = "0123456789ABCDEF"[g]
+	code[10] = "0123456789ABCDEF"[i]
+	if _, err := e.Add(string(code), "Test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if value := m.ReadByte(0x4123); value != 0x11 {
+		t.Errorf("bank 1 should have read 0x11 but read %#x", value)
+	}
+
+	m.WriteByte(0x2000, 2)
+	if value := m.ReadByte(0x4123); value != 0x99 {
+		t.Errorf("bank 2 should have been patched to 0x99 but read %#x", value)
+	}
+
+	e.SetEnabled(0, false)
+	if value := m.ReadByte(0x4123); value != 0x22 {
+		t.Errorf("the disabled cheat should have left 0x22 but read %#x", value)
+	}
+}
+
+type ram struct {
+	data [0x2000]byte
+}
+
+func (r *ram) ReadByte(addr uint16) byte {
+	return r.data[addr-0xC000]
+}
+
+func (r *ram) WriteByte(addr uint16, value byte) {
+	r.data[addr-0xC000] = value
+}
+
+func TestGameShark(t *testing.T) {
+	m := mmu.NewMMU()
+	wram := &ram{}
+	m.MapMemoryRange(wram, 0xC000, 0xDFFF)
+
+	e := NewEngine(m)
+	e.Add("0163A0C1", "Lives")
+	e.Add("0142A1C1", "Disabled")
+	e.SetEnabled(1, false)
+
+	e.Apply()
+	if wram.data[0x1A0] != 0x63 {
+		t.Errorf("0xC1A0 should have been 0x63 but was %#x", wram.data[0x1A0])
+	}
+	if wram.data[0x1A1] != 0 {
+		t.Errorf("the disabled cheat shouldn't have written 0xC1A1 but it was %#x", wram.data[0x1A1])
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	input := "# Comment\n\non  01FF42C3    Infinite lives\noff ff0-00e\n"
+
+	cheats, err := Read(bytes.NewBufferString(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cheats) != 2 || !cheats[0].Enabled || cheats[0].Name != "Infinite lives" || cheats[1].Enabled || cheats[1].Code != "FF0-00E" {
+		t.Fatalf("cheats were read wrong: %+v %+v", cheats[0], cheats[1])
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, cheats); err != nil {
+		t.Fatal(err)
+	}
+	expected := "on  01FF42C3    Infinite lives\noff FF0-00E\n"
+	if buf.String() != expected {
+		t.Errorf("cheats should have been written as %q but were %q", expected, buf.String())
+	}
+
+	again, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, cheats) {
+		t.Errorf("cheats should have been the same after writing and reading")
+	}
+}
@@ -0,0 +1,136 @@
+package cheats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Kind is the kind of cheat device a code is for
+type Kind int
+
+const (
+	// GameGenie codes patch the ROM as it is read
+	GameGenie Kind = iota
+	// GameShark codes write to RAM every frame
+	GameShark
+)
+
+func (k Kind) String() string {
+	switch k {
+	case GameGenie:
+		return "Game Genie"
+	case GameShark:
+		return "GameShark"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Cheat is a decoded cheat code
+type Cheat struct {
+	Code    string
+	Name    string
+	Enabled bool
+
+	Kind    Kind
+	Address uint16
+	Value   byte
+	// Compare is the value a Game Genie code only patches, so it only patches the
+	// right bank when the address is in the switchable bank, if HasCompare is set
+	Compare    byte
+	HasCompare bool
+	// Bank is the GameShark code's RAM bank, which isn't used as only one bank of work RAM is emulated
+	Bank byte
+}
+
+// Parse decodes a Game Genie code, in the form "ABC-DEF" or "ABC-DEF-GHI",
+// or a GameShark code, in the form "01VVAAAA". Cheats start enabled.
+func Parse(code string) (*Cheat, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	var cheat *Cheat
+	var err error
+	if strings.Contains(code, "-") {
+		cheat, err = parseGameGenie(code)
+	} else {
+		cheat, err = parseGameShark(code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cheat.Code = code
+	cheat.Enabled = true
+	return cheat, nil
+}
+
+// parseGameGenie decodes a code of the form ABC-DEF-GHI, where AB is the new value and
+// the address is FCDE with F inverted. GI is the value compared against, rotated right
+// by 2 and XORed with 0xBA, H is not used. Six character codes have no compare value.
+func parseGameGenie(code string) (*Cheat, error) {
+	if (len(code) != 7 && len(code) != 11) || code[3] != '-' || (len(code) == 11 && code[7] != '-') {
+		return nil, fmt.Errorf("game genie code should have been ABC-DEF or ABC-DEF-GHI but was %q", code)
+	}
+
+	digits, err := hexDigits(strings.ReplaceAll(code, "-", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid game genie code %q: %v", code, err)
+	}
+
+	address := uint16(digits[5]^0xF)<<12 | uint16(digits[2])<<8 | uint16(digits[3])<<4 | uint16(digits[4])
+	if address > 0x7FFF {
+		return nil, fmt.Errorf("game genie code %q patches %#04x, which is outside the ROM", code, address)
+	}
+
+	cheat := &Cheat{
+		Kind:    GameGenie,
+		Value:   digits[0]<<4 | digits[1],
+		Address: address,
+	}
+
+	if len(digits) == 9 {
+		compare := digits[6]<<4 | digits[8]
+		cheat.Compare = (compare>>2 | compare<<6) ^ 0xBA
+		cheat.HasCompare = true
+	}
+
+	return cheat, nil
+}
+
+// parseGameShark decodes a code of the form TTVVAAAA, where TT is the RAM bank, usually 01,
+// VV is the value and AAAA is the address with its low byte first
+func parseGameShark(code string) (*Cheat, error) {
+	if len(code) != 8 {
+		return nil, fmt.Errorf("gameshark code should have been 8 hex digits but was %q", code)
+	}
+
+	value, err := strconv.ParseUint(code, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gameshark code %q", code)
+	}
+
+	cheat := &Cheat{
+		Kind:    GameShark,
+		Bank:    byte(value >> 24),
+		Value:   byte(value >> 16),
+		Address: uint16(value>>8&0xFF) | uint16(value&0xFF)<<8,
+	}
+
+	if cheat.Address < 0x8000 {
+		return nil, fmt.Errorf("gameshark code %q writes to %#04x, which is ROM", code, cheat.Address)
+	}
+
+	return cheat, nil
+}
+
+func hexDigits(s string) ([]byte, error) {
+	digits := make([]byte, len(s))
+	for i := range s {
+		digit, err := strconv.ParseUint(s[i:i+1], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a hex digit", s[i])
+		}
+		digits[i] = byte(digit)
+	}
+	return digits, nil
+}
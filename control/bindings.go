@@ -0,0 +1,190 @@
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Emulator hotkeys, which are handled by the emulator instead of the Game Boy
+const (
+	HotkeyDebug         = "debug"
+	HotkeyPalette       = "palette"
+	HotkeyMIDI          = "midi"
+	HotkeyVGM           = "vgm"
+	HotkeyAudio         = "audio"
+	HotkeyRecord        = "record"
+	HotkeyScreenshot    = "screenshot"
+	HotkeyMovieReadOnly = "movie-readonly"
+	HotkeyTurbo         = "turbo"
+	HotkeySpeedUp       = "speed-up"
+	HotkeySlowDown      = "slow-down"
+	HotkeyPause         = "pause"
+	HotkeyFrameAdvance  = "frame-advance"
+	HotkeyViewTiles     = "view-tiles"
+	HotkeyViewMap9800   = "view-map9800"
+	HotkeyViewMap9C00   = "view-map9c00"
+	HotkeyViewOAM       = "view-oam"
+	HotkeyExportViews   = "export-views"
+)
+
+// Hotkeys are the hotkeys that can be bound
+var Hotkeys = []string{
+	HotkeyDebug, HotkeyPalette, HotkeyMIDI, HotkeyVGM, HotkeyAudio, HotkeyRecord, HotkeyScreenshot, HotkeyMovieReadOnly,
+	HotkeyTurbo, HotkeySpeedUp, HotkeySlowDown, HotkeyPause, HotkeyFrameAdvance,
+	HotkeyViewTiles, HotkeyViewMap9800, HotkeyViewMap9C00, HotkeyViewOAM, HotkeyExportViews,
+}
+
+// buttonNames are the names of the Game Boy's buttons in a bindings file
+var buttonNames = map[string]byte{
+	"right":  RIGHT,
+	"left":   LEFT,
+	"up":     UP,
+	"down":   DOWN,
+	"a":      A,
+	"b":      B,
+	"select": SELECT,
+	"start":  START,
+}
+
+// DefaultStickThreshold is how far an analogue stick has to be
+// pushed, from 0 to 1, to press a direction on the D-pad
+const DefaultStickThreshold = 0.5
+
+// DefaultBindings are the bindings used without a bindings file. Gamepad buttons are
+// named by their position on an Xbox controller, so A and B are bound to the buttons
+// in the same place as on a Game Boy, which are labelled B and A.
+const DefaultBindings = `
+right          = Right, pad:dpright, pad:leftx+
+left           = Left, pad:dpleft, pad:leftx-
+up             = Up, pad:dpup, pad:lefty-
+down           = Down, pad:dpdown, pad:lefty+
+a              = S, pad:b
+b              = A, pad:a
+select         = Space, pad:back
+start          = Return, pad:start
+
+debug          = Z
+palette        = P
+midi           = F7
+vgm            = F8
+audio          = F9
+record         = F10
+screenshot     = F12
+movie-readonly = F6
+turbo          = Tab
+speed-up       = =
+slow-down      = -
+pause          = Backspace
+frame-advance  = \
+view-tiles     = F1
+view-map9800   = F2
+view-map9c00   = F3
+view-oam       = F4
+export-views   = F5
+`
+
+// Bindings map inputs to the Game Boy's buttons and the emulator's hotkeys. Inputs are
+// named by the key's name, such as "Right", "S" or "F12", or by "pad:" followed by the
+// gamepad button's name, such as "pad:a" or "pad:dpup". An analogue stick or trigger is
+// an input in each direction, such as "pad:leftx-" and "pad:leftx+". Names are not case
+// sensitive and any number of inputs can be bound to a button or hotkey. A gamepad binding
+// applies to every gamepad plugged in.
+type Bindings struct {
+	Buttons map[string]byte
+	Hotkeys map[string]string
+
+	// StickThreshold is how far a stick has to be pushed, from 0 to 1, to press its input
+	StickThreshold float64
+}
+
+// NewBindings returns the default bindings
+func NewBindings() *Bindings {
+	bindings, err := ReadBindings(strings.NewReader(DefaultBindings))
+	if err != nil {
+		panic(err)
+	}
+	return bindings
+}
+
+// LoadBindings reads bindings from a file
+func LoadBindings(filename string) (*Bindings, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadBindings(f)
+}
+
+// ReadBindings reads bindings with one button or hotkey per line, followed by "="
+// and the inputs bound to it separated by commas, in the format of DefaultBindings.
+// The stick threshold is set with "stick-threshold = 0.5". Blank lines and lines
+// starting with "#", ";" or "//" are ignored.
+func ReadBindings(r io.Reader) (*Bindings, error) {
+	bindings := &Bindings{
+		Buttons:        map[string]byte{},
+		Hotkeys:        map[string]string{},
+		StickThreshold: DefaultStickThreshold,
+	}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip blank lines and comments
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("line %v should have been a binding but was %q", n, line)
+		}
+		name := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		if name == "stick-threshold" {
+			threshold, err := strconv.ParseFloat(value, 64)
+			if err != nil || threshold <= 0 || threshold > 1 {
+				return nil, fmt.Errorf("line %v: stick threshold should have been from 0 to 1 but was %q", n, value)
+			}
+			bindings.StickThreshold = threshold
+			continue
+		}
+
+		button, isButton := buttonNames[name]
+		if !isButton && !isHotkey(name) {
+			return nil, fmt.Errorf("line %v: %q is not a button or hotkey", n, name)
+		}
+
+		for _, input := range strings.Split(value, ",") {
+			input = strings.ToLower(strings.TrimSpace(input))
+			if input == "" {
+				continue
+			}
+			if isButton {
+				bindings.Buttons[input] = button
+			} else {
+				bindings.Hotkeys[input] = name
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return bindings, nil
+}
+
+func isHotkey(name string) bool {
+	for _, hotkey := range Hotkeys {
+		if name == hotkey {
+			return true
+		}
+	}
+	return false
+}
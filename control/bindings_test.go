@@ -0,0 +1,178 @@
+package control
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+func TestReadBindings(t *testing.T) {
+	bindings, err := ReadBindings(strings.NewReader(`
+# Comments and blank lines are skipped
+
+a = X, pad:A
+B=z
+screenshot = F1
+stick-threshold = 0.25
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buttons := []struct {
+		input    string
+		expected byte
+	}{
+		{"x", A},
+		{"pad:a", A},
+		{"z", B},
+	}
+	for _, c := range buttons {
+		t.Run(c.input, func(t *testing.T) {
+			if button, ok := bindings.Buttons[c.input]; !ok || button != c.expected {
+				t.Errorf("%v should have been bound to %v but was %v", c.input, c.expected, button)
+			}
+		})
+	}
+
+	if hotkey := bindings.Hotkeys["f1"]; hotkey != HotkeyScreenshot {
+		t.Errorf("f1 should have been bound to %v but was %v", HotkeyScreenshot, hotkey)
+	}
+	if bindings.StickThreshold != 0.25 {
+		t.Errorf("StickThreshold should have been 0.25 but was %v", bindings.StickThreshold)
+	}
+}
+
+func TestReadBindingsErrors(t *testing.T) {
+	cases := []string{
+		"a X",
+		"rewind = X",
+		"stick-threshold = 2",
+	}
+
+	for _, c := range cases {
+		t.Run(c, func(t *testing.T) {
+			if _, err := ReadBindings(strings.NewReader(c)); err == nil {
+				t.Errorf("%q should have been an error", c)
+			}
+		})
+	}
+}
+
+func TestDefaultBindings(t *testing.T) {
+	bindings := NewBindings()
+
+	if button := bindings.Buttons["return"]; button != START {
+		t.Errorf("Return should have been bound to START but was %v", button)
+	}
+	if hotkey := bindings.Hotkeys["f12"]; hotkey != HotkeyScreenshot {
+		t.Errorf("F12 should have been bound to %v but was %v", HotkeyScreenshot, hotkey)
+	}
+}
+
+func TestInputs(t *testing.T) {
+	c := NewController(mmu.NewMMU())
+	// Select the buttons
+	c.WriteByte(P1, 0x10)
+
+	pressed := func() bool {
+		return c.ReadByte(P1)&0x01 == 0
+	}
+
+	// Both inputs bound to A hold it until they are both released
+	c.InputPressed("S")
+	c.InputPressed("pad:b")
+	c.InputReleased("S")
+	if !pressed() {
+		t.Errorf("A should have been held by pad:b")
+	}
+	c.InputReleased("pad:b")
+	if pressed() {
+		t.Errorf("A should have been released")
+	}
+
+	// A repeated key doesn't run its hotkey again
+	if hotkey := c.InputPressed("F12"); hotkey != HotkeyScreenshot {
+		t.Errorf("F12 should have returned %v but returned %v", HotkeyScreenshot, hotkey)
+	}
+	if hotkey := c.InputPressed("F12"); hotkey != "" {
+		t.Errorf("a repeated F12 should have returned nothing but returned %v", hotkey)
+	}
+
+	// Releasing the debug key doesn't toggle debugging again
+	c.InputPressed("Z")
+	c.InputReleased("Z")
+	if hotkey := c.InputPressed("Z"); hotkey != HotkeyDebug {
+		t.Errorf("Z should have returned %v but returned %v", HotkeyDebug, hotkey)
+	}
+	if c.Debug {
+		t.Errorf("Debug should only be toggled by running the debug hotkey")
+	}
+
+	c.ReleaseAll()
+	if c.ReadByte(P1)&0x0F != 0x0F {
+		t.Errorf("all buttons should have been released but P1 was %#x", c.ReadByte(P1))
+	}
+}
+
+func TestAxisMoved(t *testing.T) {
+	c := NewController(mmu.NewMMU())
+	// Select the directions
+	c.WriteByte(P1, 0x20)
+
+	cases := []struct {
+		value    float64
+		expected byte
+	}{
+		{0.2, 0x0F},
+		{-0.6, 0x0D}, // left
+		{-0.4, 0x0F},
+		{0.9, 0x0E}, // right
+		{-1, 0x0D},  // left
+		{0, 0x0F},
+	}
+
+	for _, tc := range cases {
+		c.AxisMoved("pad:leftx", tc.value)
+		if state := c.ReadByte(P1) & 0x0F; state != tc.expected {
+			t.Errorf("directions should have been %#x at %v but were %#x", tc.expected, tc.value, state)
+		}
+	}
+}
+
+func TestPadInputs(t *testing.T) {
+	c := NewController(mmu.NewMMU())
+	// Select the buttons
+	c.WriteByte(P1, 0x10)
+
+	held := func(button byte) bool {
+		return c.ReadByte(P1)&(1<<(button-4)) == 0
+	}
+
+	// The same button held on two pads is held until it is released on both
+	c.InputPressed(PadInput(0, "b"))
+	c.InputPressed(PadInput(1, "b"))
+	c.InputReleased(PadInput(0, "b"))
+	if !held(A) {
+		t.Errorf("A should have been held by the second pad")
+	}
+
+	// Unplugging a pad only releases what was held on it
+	c.InputPressed("Return")
+	c.InputPressed(PadInput(0, "a"))
+	c.ReleasePad(1)
+	if held(A) {
+		t.Errorf("A should have been released with the second pad")
+	}
+	if !held(START) || !held(B) {
+		t.Errorf("the keyboard's START and the first pad's B should still have been held")
+	}
+
+	// Pad 12's inputs are not pad 1's
+	c.InputPressed(PadInput(12, "b"))
+	c.ReleasePad(1)
+	if !held(A) {
+		t.Errorf("A should have been held by pad 12")
+	}
+}
@@ -1,6 +1,10 @@
 package control
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/kevinbrolly/GopherBoy/mmu"
 	"github.com/kevinbrolly/GopherBoy/utils"
 )
@@ -25,11 +29,31 @@ const (
 	DEBUG = 9
 )
 
+// Source decides the buttons the game sees each time it reads the joypad
+type Source interface {
+	// Poll is called on every read of P1 with the buttons held by the player and returns
+	// the buttons the game sees, with a bit set for each button pressed
+	Poll(held byte) byte
+}
+
 type Controller struct {
 	mmu             *mmu.MMU
 	controllerState byte
 	P1              byte
 	Debug           bool
+
+	// Source, if set, decides which buttons the game sees, such as a movie being
+	// recorded or played back. The joypad interrupt is then requested when the
+	// buttons it returns are pressed, instead of when the player presses them, so
+	// it fires at the same times when a movie is played back as when it was recorded.
+	Source Source
+	// polled are the buttons the Source last returned
+	polled byte
+
+	// Bindings map keyboard and gamepad inputs to buttons and hotkeys
+	Bindings *Bindings
+	// held are the inputs being held down, gamepad inputs are named with the pad's ID as by PadInput
+	held map[string]bool
 }
 
 func NewController(mmu *mmu.MMU) *Controller {
@@ -37,6 +61,8 @@ func NewController(mmu *mmu.MMU) *Controller {
 		mmu:             mmu,
 		controllerState: 0xFF,
 		P1:              0xFF,
+		Bindings:        NewBindings(),
+		held:            map[string]bool{},
 	}
 
 	// P1 = 0xFF00
@@ -48,41 +74,185 @@ func (c *Controller) KeyPressed(key byte) {
 	// Clear the bit for the pressed key
 	c.controllerState = utils.ClearBit(c.controllerState, key)
 
+	// A Source requests the interrupt itself, when it is polled
+	if c.Source != nil && key != DEBUG {
+		return
+	}
+
 	switch key {
-	case RIGHT, LEFT, UP, DOWN:
-		// If the game is interested in direction keys and one was pressed trigger interrupt
-		if !utils.IsBitSet(c.P1, SELECT_DIRECTION_KEYS) {
-			c.mmu.RequestInterrupt(JOYPAD_INTERRUPT)
-		}
-	case A, B, SELECT, START:
-		// If the game is interested in button keys and one was pressed trigger interrupt
-		if !utils.IsBitSet(c.P1, SELECT_BUTTON_KEYS) {
-			c.mmu.RequestInterrupt(JOYPAD_INTERRUPT)
-		}
+	case RIGHT, LEFT, UP, DOWN, A, B, SELECT, START:
+		c.requestInterrupt(1 << key)
 	case DEBUG:
 		c.Debug = !c.Debug
 	}
 }
 
+// requestInterrupt requests the joypad interrupt if any of the newly pressed
+// buttons, with a bit set for each, are in a group the game has selected
+func (c *Controller) requestInterrupt(pressed byte) {
+	// If the game is interested in direction keys and one was pressed trigger interrupt
+	if pressed&0x0F != 0 && !utils.IsBitSet(c.P1, SELECT_DIRECTION_KEYS) {
+		c.mmu.RequestInterrupt(JOYPAD_INTERRUPT)
+		return
+	}
+	// If the game is interested in button keys and one was pressed trigger interrupt
+	if pressed&0xF0 != 0 && !utils.IsBitSet(c.P1, SELECT_BUTTON_KEYS) {
+		c.mmu.RequestInterrupt(JOYPAD_INTERRUPT)
+	}
+}
+
+// Update polls the Source once a frame, before the frame is run, so the joypad interrupt
+// is requested for buttons pressed while the game isn't reading the joypad, such as while
+// it is halted waiting for the interrupt. It does nothing without a Source.
+func (c *Controller) Update() {
+	if c.Source != nil {
+		c.poll()
+	}
+}
+
+// poll returns the buttons the Source decides the game sees, requesting
+// the joypad interrupt for the buttons that weren't pressed at the last poll
+func (c *Controller) poll() byte {
+	buttons := c.Source.Poll(c.Buttons())
+	c.requestInterrupt(buttons &^ c.polled)
+	c.polled = buttons
+	return buttons
+}
+
 func (c *Controller) KeyReleased(key byte) {
 	// Set the bit for the released key
 	c.controllerState = utils.SetBit(c.controllerState, key)
 }
 
-func (c *Controller) getControllerState() byte {
-	// And the bits in P1 so that only P14 or P15 is not set
-	p1 := c.P1 & 0xFF
+// InputPressed presses the button bound to input and returns the hotkey bound to it, if any.
+// An input that is already held, such as a repeated key, does nothing.
+func (c *Controller) InputPressed(input string) (hotkey string) {
+	input = strings.ToLower(input)
+	if c.held[input] {
+		return ""
+	}
+	c.held[input] = true
+
+	if button, ok := c.Bindings.Buttons[bindingName(input)]; ok && !c.buttonHeld(button, input) {
+		c.KeyPressed(button)
+	}
+	return c.Bindings.Hotkeys[bindingName(input)]
+}
+
+// InputReleased releases the button bound to input, unless another input bound to it is held
+func (c *Controller) InputReleased(input string) {
+	input = strings.ToLower(input)
+	if !c.held[input] {
+		return
+	}
+	delete(c.held, input)
+
+	if button, ok := c.Bindings.Buttons[bindingName(input)]; ok && !c.buttonHeld(button, input) {
+		c.KeyReleased(button)
+	}
+}
+
+// AxisMoved presses the input for the direction axis is pushed in, such as "pad:leftx-"
+// or "pad:leftx+", once it is pushed past the stick threshold and releases it when it
+// isn't. value is from -1 to 1. It returns the hotkey bound to a newly pressed input.
+func (c *Controller) AxisMoved(axis string, value float64) (hotkey string) {
+	negative, positive := axis+"-", axis+"+"
+	threshold := c.Bindings.StickThreshold
+
+	if value > -threshold {
+		c.InputReleased(negative)
+	}
+	if value < threshold {
+		c.InputReleased(positive)
+	}
 
 	switch {
-	case !utils.IsBitSet(p1, SELECT_DIRECTION_KEYS):
-		return c.P1 ^ (c.controllerState & 0xf)
+	case value <= -threshold:
+		return c.InputPressed(negative)
+	case value >= threshold:
+		return c.InputPressed(positive)
+	}
+	return ""
+}
+
+// ReleaseAll releases every input, such as when the window
+// loses focus and the inputs' releases would be missed
+func (c *Controller) ReleaseAll() {
+	for input := range c.held {
+		c.InputReleased(input)
+	}
+}
+
+// ReleasePad releases the inputs held on a gamepad, such as when it is unplugged
+func (c *Controller) ReleasePad(id int) {
+	prefix := PadInput(id, "")
+	for input := range c.held {
+		if strings.HasPrefix(input, prefix) {
+			c.InputReleased(input)
+		}
+	}
+}
+
+// PadInput returns the name of a button or axis on the gamepad with the instance ID id, such
+// as "pad3:a". It is bound as "pad:a", but each pad's inputs are held separately, so the same
+// button held on two pads is held until it is released on both.
+func PadInput(id int, name string) string {
+	return fmt.Sprintf("pad%d:%s", id, name)
+}
+
+// bindingName returns the name input is bound by, which for a gamepad input leaves out the pad's ID
+func bindingName(input string) string {
+	if !strings.HasPrefix(input, "pad") {
+		return input
+	}
+	i := strings.IndexByte(input, ':')
+	if i < 0 {
+		return input
+	}
+	if _, err := strconv.Atoi(input[len("pad"):i]); err != nil {
+		return input
+	}
+	return "pad" + input[i:]
+}
+
+// buttonHeld returns true if an input other than except that is bound to button is held
+func (c *Controller) buttonHeld(button byte, except string) bool {
+	for input := range c.held {
+		if held, ok := c.Bindings.Buttons[bindingName(input)]; ok && held == button && input != except {
+			return true
+		}
+	}
+	return false
+}
 
-	case !utils.IsBitSet(p1, SELECT_BUTTON_KEYS):
-		return c.P1 ^ ((c.controllerState >> 4) & 0xF)
+// Buttons returns the buttons held by the player, with a bit set for each button pressed
+func (c *Controller) Buttons() byte {
+	return ^c.controllerState
+}
 
-	default:
-		return c.P1
+func (c *Controller) getControllerState() byte {
+	// Only the select bits (P14 and P15) of P1 are writable, bits 6 and 7
+	// are unused and always read as 1
+	p1 := (c.P1 & 0x30) | 0xC0
+
+	// Input lines read high unless a key in a selected group is pressed,
+	// if both groups are selected their lines are ANDed together
+	state := byte(0x0F)
+
+	buttons := c.controllerState
+	if c.Source != nil {
+		buttons = ^c.poll()
+	}
+
+	if !utils.IsBitSet(p1, SELECT_DIRECTION_KEYS) {
+		state &= buttons & 0xF
 	}
+
+	if !utils.IsBitSet(p1, SELECT_BUTTON_KEYS) {
+		state &= (buttons >> 4) & 0xF
+	}
+
+	return p1 | state
 }
 
 func (c *Controller) ReadByte(addr uint16) byte {
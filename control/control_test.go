@@ -0,0 +1,75 @@
+package control
+
+import (
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+// interruptFlag is the IF register, for seeing which interrupts were requested
+type interruptFlag byte
+
+func (f *interruptFlag) ReadByte(addr uint16) byte         { return byte(*f) }
+func (f *interruptFlag) WriteByte(addr uint16, value byte) { *f = interruptFlag(value) }
+
+// sequence is a source that returns each of its buttons in turn
+type sequence []byte
+
+func (s *sequence) Poll(held byte) byte {
+	buttons := (*s)[0]
+	if len(*s) > 1 {
+		*s = (*s)[1:]
+	}
+	return buttons
+}
+
+func TestSourceInterrupt(t *testing.T) {
+	cases := []struct {
+		Name     string
+		P1       byte
+		Polls    []byte
+		Expected bool
+	}{
+		{"Button pressed", 0x10, []byte{0x00, 0x10}, true},
+		{"Direction pressed", 0x20, []byte{0x00, 0x04}, true},
+		{"Group not selected", 0x20, []byte{0x00, 0x10}, false},
+		{"Still held", 0x10, []byte{0x10, 0x10}, false},
+		{"Released", 0x10, []byte{0x10, 0x00}, false},
+		{"Another button pressed", 0x10, []byte{0x10, 0x30}, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			m := mmu.NewMMU()
+			var IF interruptFlag
+			m.MapMemory(&IF, 0xFF0F)
+
+			c := NewController(m)
+			c.P1 = tt.P1
+			polls := sequence(tt.Polls)
+			c.Source = &polls
+
+			c.Update()
+			IF = 0
+			// The second poll is on a read of the joypad instead of at the start of a frame
+			c.ReadByte(P1)
+
+			if requested := IF&(1<<JOYPAD_INTERRUPT) != 0; requested != tt.Expected {
+				t.Errorf("Joypad interrupt requested should have been %v but was %v", tt.Expected, requested)
+			}
+		})
+	}
+
+	// The player's presses don't request the interrupt while there is a source
+	m := mmu.NewMMU()
+	var IF interruptFlag
+	m.MapMemory(&IF, 0xFF0F)
+	c := NewController(m)
+	c.P1 = 0x10
+	polls := sequence{0x00}
+	c.Source = &polls
+	c.KeyPressed(A)
+	if IF != 0 {
+		t.Errorf("Joypad interrupt should not have been requested by the player while there is a source")
+	}
+}
@@ -0,0 +1,8 @@
+package cpu
+
+const (
+	// ClockSpeed is the number of cycles per second, the rest of the Game Boy is clocked from it
+	ClockSpeed = 4194304
+	// CyclesPerFrame is the number of cycles in a frame, 154 lines of 456 dots
+	CyclesPerFrame = 70224
+)
@@ -25,6 +25,11 @@ const (
 	JOYPAD_INTERRUPT_ADDR         = 0x60
 )
 
+// Joypad
+const (
+	P1 = 0xFF00
+)
+
 type Registers struct {
 	A byte
 	B byte
@@ -49,7 +54,15 @@ type CPU struct {
 	IE  byte // Interrupt Enabled
 	IME bool // Interrupt Master Enable
 
+	// EI only sets IME after the instruction following it has executed
+	enableIMEPending bool
+
 	Halt bool
+	Stop bool
+
+	// When HALT is executed with IME=0 and an interrupt pending the CPU
+	// does not halt and fails to increment PC after the next opcode fetch
+	haltBug bool
 }
 
 func NewCPU(mmu *mmu.MMU) *CPU {
@@ -120,63 +133,138 @@ func UnimplementedInstruction(cpu *CPU) {
 }
 
 func (cpu *CPU) Step() (cycles int) {
-	if !cpu.Halt {
-		initialPC := cpu.PC
-		var opcode byte = cpu.GetOpcode()
-		instruction := cpu.getInstruction(opcode)
-		cpu.CurrentInstruction = instruction
-
-		cycles = int(instruction.Execute(cpu))
-
-		// The && opcode != 0x18 is a quick hack to stop a bug whereby in opcode 0x18 it is possible
-		// to end up with initalPC and cpu.PC being equal even when the instruction has completed
-		// successfully, and therefore adding the CurrentInstruction.Length leaves us with an incorrect
-		// cpu.PC value.
-		// TODO: Refactor CPU instructions to increment PC themselves.
-		if initialPC == cpu.PC && opcode != 0x18 && opcode != 0x20 {
-			cpu.PC += cpu.CurrentInstruction.Length
+	switch {
+	case cpu.Stop:
+		// The CPU and timer are stopped until a selected joypad line goes low
+		if cpu.joypadPressed() {
+			cpu.Stop = false
+		}
+		return 4
+
+	case cpu.Halt:
+		// HALT ends as soon as an interrupt is both requested and enabled,
+		// even if IME=0, waking up takes 1 cycle
+		if cpu.interruptPending() {
+			cpu.Halt = false
 		}
-	} else {
-		// Halt takes 1 cycle
 		cycles = 1
+
+	default:
+		// Interrupts are checked before fetching each instruction
+		cycles = cpu.handleInterrupts()
+		if cycles == 0 {
+			cycles = cpu.execute()
+		}
 	}
 
 	cpu.timer.Tick(cycles)
-	cpu.handleInterrupts()
 
 	return cycles * 4
 }
 
-func (cpu *CPU) handleInterrupts() {
-	if cpu.IME {
-		// if and interrupt is requested (IF) & enabled (IE) then set its bit to 1 in interrupts
-		interrupts := cpu.IE & cpu.IF
-
-		// If an interrupt is actionable interrupts will be > 0
-		if interrupts != 0 {
-			switch {
-			case utils.IsBitSet(interrupts, VBLANK_INTERRUPT):
-				cpu.handleInterrupt(VBLANK_INTERRUPT, VBLANK_INTERRUPT_ADDR)
-			case utils.IsBitSet(interrupts, LCDC_INTERRUPT):
-				cpu.handleInterrupt(LCDC_INTERRUPT, LCDC_INTERRUPT_ADDR)
-			case utils.IsBitSet(interrupts, TIMER_OVERFLOW_INTERRUPT):
-				cpu.handleInterrupt(TIMER_OVERFLOW_INTERRUPT, TIMER_OVERFLOW_INTERRUPT_ADDR)
-			case utils.IsBitSet(interrupts, SERIAL_IO_INTERRUPT):
-				cpu.handleInterrupt(SERIAL_IO_INTERRUPT, SERIAL_IO_INTERRUPT_ADDR)
-			case utils.IsBitSet(interrupts, JOYPAD_INTERRUPT):
-				cpu.handleInterrupt(JOYPAD_INTERRUPT, JOYPAD_INTERRUPT_ADDR)
-			}
-		}
+func (cpu *CPU) execute() (cycles int) {
+	// Apply the IME change from an EI executed on the previous Step,
+	// the instruction after EI always runs before interrupts are serviced
+	if cpu.enableIMEPending {
+		cpu.enableIMEPending = false
+		cpu.IME = true
+	}
+
+	var opcode byte = cpu.GetOpcode()
+
+	// The HALT bug reads the byte after HALT twice, moving PC back one
+	// means that byte is also read as the first operand
+	if cpu.haltBug {
+		cpu.haltBug = false
+		cpu.PC--
+	}
+
+	initialPC := cpu.PC
+	instruction := cpu.getInstruction(opcode)
+	cpu.CurrentInstruction = instruction
+
+	cycles = int(instruction.Execute(cpu))
+
+	// The && opcode != 0x18 is a quick hack to stop a bug whereby in opcode 0x18 it is possible
+	// to end up with initalPC and cpu.PC being equal even when the instruction has completed
+	// successfully, and therefore adding the CurrentInstruction.Length leaves us with an incorrect
+	// cpu.PC value.
+	// TODO: Refactor CPU instructions to increment PC themselves.
+	if initialPC == cpu.PC && opcode != 0x18 && opcode != 0x20 {
+		cpu.PC += cpu.CurrentInstruction.Length
 	}
+
+	return cycles
 }
 
-func (cpu *CPU) handleInterrupt(interrupt byte, interrupt_addr uint16) {
+// AccessCycles returns the number of cycles into the instruction being run that it accesses
+// memory at. Loads access memory in the machine cycle after the instruction's bytes are
+// fetched, each byte taking one machine cycle of 4 cycles.
+func (cpu *CPU) AccessCycles() int {
+	if cpu.CurrentInstruction == nil {
+		return 0
+	}
+	return int(cpu.CurrentInstruction.Length) * 4
+}
+
+// handleInterrupts dispatches the highest priority pending interrupt
+// and returns the number of cycles taken, 0 if nothing was dispatched
+func (cpu *CPU) handleInterrupts() (cycles int) {
+	if !cpu.IME || !cpu.interruptPending() {
+		return 0
+	}
+
+	// Dispatch takes 5 cycles:
+	// 2 wait states, push PC high byte, push PC low byte, jump to the vector
 	cpu.IME = false
-	cpu.mmu.WriteByte(IF, utils.ClearBit(cpu.IF, interrupt))
-	cpu.pushWordToStack(cpu.PC)
+
+	hb, lb := utils.SplitBytes(cpu.PC)
+
+	cpu.SP--
+	cpu.mmu.WriteByte(cpu.SP, hb)
+
+	// The interrupt to service is only chosen after the high byte of PC has been
+	// pushed. If that push overwrote IE (SP was 0x0000) and the pending interrupt
+	// is no longer enabled, dispatch is cancelled and execution continues at 0x0000
+	interrupts := cpu.IE & cpu.IF
+
+	cpu.SP--
+	cpu.mmu.WriteByte(cpu.SP, lb)
+
+	switch {
+	case utils.IsBitSet(interrupts, VBLANK_INTERRUPT):
+		cpu.handleInterrupt(VBLANK_INTERRUPT, VBLANK_INTERRUPT_ADDR)
+	case utils.IsBitSet(interrupts, LCDC_INTERRUPT):
+		cpu.handleInterrupt(LCDC_INTERRUPT, LCDC_INTERRUPT_ADDR)
+	case utils.IsBitSet(interrupts, TIMER_OVERFLOW_INTERRUPT):
+		cpu.handleInterrupt(TIMER_OVERFLOW_INTERRUPT, TIMER_OVERFLOW_INTERRUPT_ADDR)
+	case utils.IsBitSet(interrupts, SERIAL_IO_INTERRUPT):
+		cpu.handleInterrupt(SERIAL_IO_INTERRUPT, SERIAL_IO_INTERRUPT_ADDR)
+	case utils.IsBitSet(interrupts, JOYPAD_INTERRUPT):
+		cpu.handleInterrupt(JOYPAD_INTERRUPT, JOYPAD_INTERRUPT_ADDR)
+	default:
+		cpu.PC = 0x0000
+	}
+
+	return 5
+}
+
+func (cpu *CPU) handleInterrupt(interrupt byte, interrupt_addr uint16) {
+	cpu.IF = utils.ClearBit(cpu.IF, interrupt)
 	cpu.PC = interrupt_addr
 }
 
+// joypadPressed returns true if any of the P10-P13 input lines are low,
+// which happens when a button in a selected group is held
+func (cpu *CPU) joypadPressed() bool {
+	return cpu.mmu.ReadByte(P1)&0x0F != 0x0F
+}
+
+// interruptPending returns true if any interrupt is both requested and enabled
+func (cpu *CPU) interruptPending() bool {
+	return cpu.IE&cpu.IF&0x1F != 0
+}
+
 // FLAGS
 func (cpu *CPU) SetFlag(flag byte) {
 	cpu.Registers.F = utils.SetBit(cpu.Registers.F, flag)
@@ -194,7 +282,8 @@ func (cpu *CPU) ReadByte(addr uint16) byte {
 	switch {
 	// I/O control handling
 	case addr == IF:
-		return cpu.IF
+		// The upper 3 bits of IF are unused and always read as 1
+		return cpu.IF | 0xE0
 	case addr == IE:
 		return cpu.IE
 	}
@@ -205,11 +294,6 @@ func (cpu *CPU) WriteByte(addr uint16, value byte) {
 	switch {
 	// I/O control handling
 	case addr == IF:
-		if cpu.Halt {
-			if value != cpu.IF {
-				cpu.Halt = false
-			}
-		}
 		cpu.IF = value
 	case addr == IE:
 		cpu.IE = value
@@ -0,0 +1,308 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+type testRAM struct {
+	data [0x10000]byte
+}
+
+func (r *testRAM) ReadByte(addr uint16) byte {
+	return r.data[addr]
+}
+
+func (r *testRAM) WriteByte(addr uint16, value byte) {
+	r.data[addr] = value
+}
+
+type testJoypad struct {
+	P1 byte
+}
+
+func (j *testJoypad) ReadByte(addr uint16) byte {
+	return j.P1
+}
+
+func (j *testJoypad) WriteByte(addr uint16, value byte) {}
+
+// newTestCPU returns a CPU with program loaded at 0x100 and
+// RAM mapped everywhere except the I/O registers
+func newTestCPU(program ...byte) (*CPU, *testRAM, *testJoypad) {
+	m := mmu.NewMMU()
+	ram := &testRAM{}
+	joypad := &testJoypad{P1: 0xFF}
+
+	m.MapMemoryRange(ram, 0x0000, 0xFEFF)
+	m.MapMemoryRange(ram, 0xFF80, 0xFFFE)
+	m.MapMemory(joypad, P1)
+
+	cpu := NewCPU(m)
+	copy(ram.data[0x100:], program)
+
+	// Stop the timer so it doesn't request interrupts during tests
+	cpu.timer.WriteByte(TAC, 0)
+
+	return cpu, ram, joypad
+}
+
+func TestEIDelay(t *testing.T) {
+	// EI, NOP, NOP
+	cpu, _, _ := newTestCPU(0xFB, 0x00, 0x00)
+	cpu.IE = 1 << VBLANK_INTERRUPT
+	cpu.IF = 1 << VBLANK_INTERRUPT
+
+	cpu.Step()
+	if cpu.IME {
+		t.Errorf("IME should not be set until the instruction after EI has executed")
+	}
+	if cpu.PC != 0x101 {
+		t.Errorf("interrupt should not be serviced straight after EI, PC is %#x", cpu.PC)
+	}
+
+	cpu.Step()
+	if !cpu.IME || cpu.PC != 0x102 {
+		t.Errorf("the instruction following EI should execute before the interrupt is serviced, PC is %#x", cpu.PC)
+	}
+
+	cpu.Step()
+	if cpu.PC != VBLANK_INTERRUPT_ADDR {
+		t.Errorf("interrupt should be serviced after the instruction following EI, PC is %#x", cpu.PC)
+	}
+}
+
+func TestEIFollowedByDI(t *testing.T) {
+	// EI, DI, NOP
+	cpu, _, _ := newTestCPU(0xFB, 0xF3, 0x00)
+	cpu.IE = 1 << VBLANK_INTERRUPT
+	cpu.IF = 1 << VBLANK_INTERRUPT
+
+	for i := 0; i < 3; i++ {
+		cpu.Step()
+	}
+
+	if cpu.IME {
+		t.Errorf("DI straight after EI should leave IME unset")
+	}
+	if cpu.PC != 0x103 {
+		t.Errorf("no interrupt should have been serviced, PC is %#x", cpu.PC)
+	}
+}
+
+func TestHaltBug(t *testing.T) {
+	cases := []struct {
+		Name       string
+		Program    []byte
+		Steps      int
+		ExpectedA  byte
+		ExpectedD  byte
+		ExpectedPC uint16
+	}{
+		// HALT, INC A - INC A is executed twice
+		{"1 byte instruction", []byte{0x76, 0x3C, 0x00}, 3, 0x03, 0x00, 0x102},
+		// HALT, LD A,0x14 - executed as LD A,0x3E then INC D
+		{"2 byte instruction", []byte{0x76, 0x3E, 0x14, 0x00}, 3, 0x3E, 0x01, 0x103},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			cpu, _, _ := newTestCPU(tt.Program...)
+			cpu.IME = false
+			cpu.IE = 1 << TIMER_OVERFLOW_INTERRUPT
+			cpu.IF = 1 << TIMER_OVERFLOW_INTERRUPT
+
+			for i := 0; i < tt.Steps; i++ {
+				cpu.Step()
+			}
+
+			if cpu.Halt {
+				t.Errorf("HALT with IME=0 and an interrupt pending should not halt the CPU")
+			}
+			if cpu.Registers.A != tt.ExpectedA {
+				t.Errorf("A should have been %#x but was %#x", tt.ExpectedA, cpu.Registers.A)
+			}
+			if cpu.Registers.D != tt.ExpectedD {
+				t.Errorf("D should have been %#x but was %#x", tt.ExpectedD, cpu.Registers.D)
+			}
+			if cpu.PC != tt.ExpectedPC {
+				t.Errorf("PC should have been %#x but was %#x", tt.ExpectedPC, cpu.PC)
+			}
+		})
+	}
+}
+
+func TestHaltWithoutPendingInterrupt(t *testing.T) {
+	// HALT, INC A
+	cpu, _, _ := newTestCPU(0x76, 0x3C)
+	cpu.IE = 1 << TIMER_OVERFLOW_INTERRUPT
+	cpu.IF = 0
+
+	cpu.Step()
+	cpu.Step()
+
+	if !cpu.Halt {
+		t.Errorf("HALT with no interrupt pending should halt the CPU")
+	}
+	if cpu.Registers.A != 0x01 {
+		t.Errorf("no instructions should execute while halted")
+	}
+}
+
+func TestStop(t *testing.T) {
+	// STOP 0, INC A
+	cpu, _, joypad := newTestCPU(0x10, 0x00, 0x3C)
+	cpu.timer.Tick(1000)
+
+	cpu.Step()
+	if !cpu.Stop {
+		t.Errorf("STOP should enter STOP mode when no button is held")
+	}
+	if cpu.PC != 0x102 {
+		t.Errorf("STOP should be a 2 byte opcode when no interrupt is pending, PC is %#x", cpu.PC)
+	}
+	if cpu.timer.ReadByte(DIV) != 0 {
+		t.Errorf("STOP should reset DIV")
+	}
+
+	cpu.Step()
+	if !cpu.Stop || cpu.Registers.A != 0x01 {
+		t.Errorf("the CPU should stay stopped until a button is pressed")
+	}
+
+	// Press a button in the selected group
+	joypad.P1 = 0xEE
+	cpu.Step()
+	cpu.Step()
+	if cpu.Stop {
+		t.Errorf("a button press should leave STOP mode")
+	}
+	if cpu.Registers.A != 0x02 {
+		t.Errorf("execution should continue after STOP mode ends")
+	}
+}
+
+func TestStopWithButtonHeld(t *testing.T) {
+	cases := []struct {
+		Name         string
+		IF           byte
+		ExpectedHalt bool
+		ExpectedPC   uint16
+	}{
+		{"Interrupt pending", 1 << JOYPAD_INTERRUPT, false, 0x101},
+		{"No interrupt pending", 0, true, 0x102},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			cpu, _, joypad := newTestCPU(0x10, 0x00)
+			joypad.P1 = 0xEE
+			cpu.IE = 1 << JOYPAD_INTERRUPT
+			cpu.IF = tt.IF
+
+			cpu.Step()
+
+			if cpu.Stop {
+				t.Errorf("STOP should not enter STOP mode while a button is held")
+			}
+			if cpu.Halt != tt.ExpectedHalt {
+				t.Errorf("Halt should have been %v", tt.ExpectedHalt)
+			}
+			if cpu.PC != tt.ExpectedPC {
+				t.Errorf("PC should have been %#x but was %#x", tt.ExpectedPC, cpu.PC)
+			}
+		})
+	}
+}
+
+func TestInterruptDispatch(t *testing.T) {
+	cpu, ram, _ := newTestCPU(0x00)
+	cpu.IME = true
+	cpu.IE = 1<<VBLANK_INTERRUPT | 1<<TIMER_OVERFLOW_INTERRUPT
+	cpu.IF = 1<<VBLANK_INTERRUPT | 1<<TIMER_OVERFLOW_INTERRUPT
+
+	cycles := cpu.Step()
+
+	if cycles != 20 {
+		t.Errorf("interrupt dispatch should take 5 M-cycles (20 T-cycles) but took %v", cycles)
+	}
+	if cpu.PC != VBLANK_INTERRUPT_ADDR {
+		t.Errorf("the highest priority interrupt should be serviced, PC is %#x", cpu.PC)
+	}
+	if cpu.IME {
+		t.Errorf("IME should be cleared when an interrupt is dispatched")
+	}
+	if cpu.IF&0x1F != 1<<TIMER_OVERFLOW_INTERRUPT {
+		t.Errorf("only the serviced interrupt should be cleared from IF, IF is %#x", cpu.IF)
+	}
+	if ram.data[0xFFFD] != 0x01 || ram.data[0xFFFC] != 0x00 {
+		t.Errorf("PC should have been pushed to the stack")
+	}
+}
+
+func TestHaltWakesWithIMEDisabled(t *testing.T) {
+	// HALT, INC A
+	cpu, _, _ := newTestCPU(0x76, 0x3C)
+	cpu.IME = false
+	cpu.IE = 1 << SERIAL_IO_INTERRUPT
+
+	cpu.Step()
+	cpu.Step()
+	if !cpu.Halt {
+		t.Errorf("CPU should be halted")
+	}
+
+	// An interrupt that is requested but not enabled doesn't wake the CPU
+	cpu.IF = 1 << VBLANK_INTERRUPT
+	cpu.Step()
+	if !cpu.Halt {
+		t.Errorf("CPU should only wake for enabled interrupts")
+	}
+
+	cpu.IF |= 1 << SERIAL_IO_INTERRUPT
+	cpu.Step()
+	cpu.Step()
+
+	if cpu.Halt {
+		t.Errorf("CPU should wake from HALT when IE & IF is non zero")
+	}
+	if cpu.Registers.A != 0x02 || cpu.PC != 0x102 {
+		t.Errorf("execution should continue after HALT without servicing the interrupt")
+	}
+	if cpu.IF&0x1F != 1<<VBLANK_INTERRUPT|1<<SERIAL_IO_INTERRUPT {
+		t.Errorf("IF should not be cleared when IME=0")
+	}
+}
+
+func TestIEPushCancelsDispatch(t *testing.T) {
+	cases := []struct {
+		Name       string
+		Interrupt  byte
+		ExpectedPC uint16
+	}{
+		// PC high byte 0x01 is written to IE, which still enables VBlank
+		{"Interrupt still enabled", VBLANK_INTERRUPT, VBLANK_INTERRUPT_ADDR},
+		// IE no longer enables the timer interrupt so the dispatch is cancelled
+		{"Interrupt disabled by push", TIMER_OVERFLOW_INTERRUPT, 0x0000},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			cpu, _, _ := newTestCPU(0x00)
+			cpu.IME = true
+			cpu.SP = 0x0000
+			cpu.IE = 1 << tt.Interrupt
+			cpu.IF = 1 << tt.Interrupt
+
+			cpu.Step()
+
+			if cpu.PC != tt.ExpectedPC {
+				t.Errorf("PC should have been %#x but was %#x", tt.ExpectedPC, cpu.PC)
+			}
+			if cpu.IE != 0x01 {
+				t.Errorf("the high byte of PC should have been pushed to IE, IE is %#x", cpu.IE)
+			}
+			if tt.ExpectedPC == 0x0000 && cpu.IF&(1<<tt.Interrupt) == 0 {
+				t.Errorf("a cancelled interrupt should stay requested in IF")
+			}
+		})
+	}
+}
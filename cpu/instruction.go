@@ -81,7 +81,7 @@ var Instructions map[byte]*Instruction = map[byte]*Instruction{
 		return cpu.RRCA()
 	}},
 	0x10: &Instruction{0x10, "STOP 0", 2, func(cpu *CPU) byte {
-		return 4
+		return cpu.STOP()
 	}},
 	0x11: &Instruction{0x11, "LD DE,d16", 3, func(cpu *CPU) byte {
 		return cpu.LD_rr_nn(&cpu.Registers.D, &cpu.Registers.E)
@@ -2311,19 +2311,55 @@ func (cpu *CPU) NOP() (cycles byte) {
 
 // Halt | 1 | ---- | Halt until interrupt occurs
 func (cpu *CPU) HALT() (cycles byte) {
-	cpu.Halt = true
+	// If IME=0 and an interrupt is already pending HALT exits immediately,
+	// triggering the HALT bug
+	if !cpu.IME && cpu.interruptPending() {
+		cpu.haltBug = true
+	} else {
+		cpu.Halt = true
+	}
+	return 1
+}
+
+// STOP | 1 | ---- | Enter low power mode until a joypad press
+func (cpu *CPU) STOP() (cycles byte) {
+	// What STOP does depends on whether a button is held and an interrupt is pending
+	// Button held | Interrupt pending | Result
+	// ---------------------------------------------------------------
+	// yes         | yes               | 1 byte opcode, mode unchanged
+	// yes         | no                | 2 byte opcode, HALT mode entered
+	// no          | yes               | 1 byte opcode, STOP mode entered, DIV reset
+	// no          | no                | 2 byte opcode, STOP mode entered, DIV reset
+	if cpu.joypadPressed() {
+		if cpu.interruptPending() {
+			cpu.PC++
+		} else {
+			cpu.Halt = true
+		}
+		return 1
+	}
+
+	if cpu.interruptPending() {
+		cpu.PC++
+	}
+
+	cpu.Stop = true
+	cpu.timer.WriteByte(DIV, 0)
 	return 1
 }
 
 // DI | 1 | ---- | Disable interrupts, IME=0
 func (cpu *CPU) DI() (cycles byte) {
 	cpu.IME = false
+	cpu.enableIMEPending = false
 	return 1
 }
 
-// EI | 1 | ---- | Enable interrupts, IME=1
+// EI | 1 | ---- | Enable interrupts, IME=1 after the next instruction
 func (cpu *CPU) EI() (cycles byte) {
-	cpu.IME = true
+	if !cpu.IME {
+		cpu.enableIMEPending = true
+	}
 	return 1
 }
 
@@ -5,7 +5,7 @@ import (
 	"github.com/kevinbrolly/GopherBoy/utils"
 )
 
-//  Timer and Divider Registers
+// Timer and Divider Registers
 const (
 	DIV  = 0xFF04
 	TIMA = 0xFF05
@@ -15,16 +15,34 @@ const (
 	TIMER_STOP = 2
 )
 
+// DIV is the upper 8 bits of a 16 bit system counter that is incremented
+// every T-cycle. TIMA is incremented on the falling edge of one of the
+// counter's bits, selected by the TAC clock frequency:
+// Frequency   Bit    Rate
+// -------------------------
+// 0           9      4096 Hz
+// 1           3      262144 Hz
+// 2           5      65536 Hz
+// 3           7      16384 Hz
+var timerBits = [4]uint16{9, 3, 5, 7}
+
 type Timer struct {
 	mmu *mmu.MMU
 
-	DIV  byte // Divider
 	TIMA byte // Timer Counter
 	TMA  byte // Timer Modulo
 	TAC  byte // Timer Controller
 
-	dividerCounter int
-	timerCounter   int
+	// Internal 16 bit system counter, DIV is the upper 8 bits
+	systemCounter uint16
+
+	// When TIMA overflows it reads 0x00 for 1 M-cycle before it is
+	// reloaded from TMA and the interrupt is requested
+	overflow bool
+
+	// reloading is true during the M-cycle TIMA is reloaded from TMA,
+	// writes to TIMA are ignored and writes to TMA also update TIMA
+	reloading bool
 }
 
 func NewTimer(mmu *mmu.MMU) *Timer {
@@ -43,65 +61,67 @@ func NewTimer(mmu *mmu.MMU) *Timer {
 func (timer *Timer) Reset() {
 	timer.TIMA = 0x00
 	timer.TMA = 0x00
-	timer.TAC = 0x05
+	timer.TAC = 0xF8
 
-	timer.timerCounter = 1024
-	timer.dividerCounter = 0
+	// System counter value when the DMG boot ROM hands over to the cartridge
+	timer.systemCounter = 0xABCC
+	timer.overflow = false
+	timer.reloading = false
 }
 
+// Tick advances the timer by the given number of M-cycles
 func (timer *Timer) Tick(cycles int) {
-	timer.updateDividerRegister(cycles)
+	for i := 0; i < cycles; i++ {
+		timer.tick()
+	}
+}
 
-	if utils.IsBitSet(timer.TAC, TIMER_STOP) {
+func (timer *Timer) tick() {
+	timer.reloading = false
 
-		timer.timerCounter += cycles
+	if timer.overflow {
+		timer.overflow = false
+		timer.reloading = true
+		timer.TIMA = timer.TMA
+		timer.mmu.RequestInterrupt(TIMER_OVERFLOW_INTERRUPT)
+	}
 
-		var threshold int
-		frequency := timer.getClockFrequency()
+	// 1 M-cycle = 4 T-cycles
+	timer.setSystemCounter(timer.systemCounter + 4)
+}
 
-		switch frequency {
-		case 0:
-			threshold = 256 // frequency 4096
-		case 1:
-			threshold = 4 // frequency 262144
-		case 2:
-			threshold = 16 // frequency 65536
-		case 3:
-			threshold = 64 // frequency 16382
-		}
+// setSystemCounter updates the system counter and increments TIMA if the
+// selected bit ANDed with the timer enable bit has gone from 1 to 0
+func (timer *Timer) setSystemCounter(value uint16) {
+	before := timer.timerSignal()
+	timer.systemCounter = value
 
-		// https://github.com/fishberg/feo-boy/blob/master/src/bus/timer.rs#L56
-		// This is the source of a common timer bug and the reason blargg's instr_timing
-		// test was failing with "Failure #255".
-		//
-		// Some instructions will go over the threshold in one instruction so rather than
-		// reset the timerCounter to the current threshold we just subtract the threshold
-		// to "reset" it, this way any cycles left over will still be counted/available
-		// in timerCounter
-		for timer.timerCounter >= threshold {
-			timer.timerCounter -= threshold
-
-			// If timer is about to overflow
-			if timer.TIMA == 255 {
-				timer.TIMA = timer.TMA
-				timer.mmu.RequestInterrupt(TIMER_OVERFLOW_INTERRUPT)
-			} else {
-				timer.TIMA = timer.TIMA + 1
-			}
-		}
+	if before && !timer.timerSignal() {
+		timer.incrementTIMA()
 	}
 }
 
-func (timer *Timer) updateDividerRegister(cycles int) {
-	// Divider uses MCycles
-	timer.dividerCounter += cycles
+func (timer *Timer) timerSignal() bool {
+	if !utils.IsBitSet(timer.TAC, TIMER_STOP) {
+		return false
+	}
+
+	bit := timerBits[timer.getClockFrequency()]
+	return timer.systemCounter&(1<<bit) != 0
+}
 
-	if timer.dividerCounter >= 255 {
-		timer.dividerCounter = 0
-		timer.DIV++
+func (timer *Timer) incrementTIMA() {
+	timer.TIMA++
+	if timer.TIMA == 0 {
+		timer.overflow = true
 	}
 }
 
+// DIV returns the divider register, the upper 8 bits of the system counter
+func (timer *Timer) DIV() byte {
+	return byte(timer.systemCounter >> 8)
+}
+
 func (timer *Timer) getClockFrequency() byte {
 	return timer.TAC & 0x03
 }
@@ -110,13 +130,14 @@ func (timer *Timer) ReadByte(addr uint16) byte {
 	switch {
 	// Timer
 	case addr == DIV:
-		return timer.DIV
+		return timer.DIV()
 	case addr == TIMA:
 		return timer.TIMA
 	case addr == TMA:
 		return timer.TMA
 	case addr == TAC:
-		return timer.TAC
+		// The upper 5 bits of TAC are unused and always read as 1
+		return timer.TAC | 0xF8
 	}
 
 	return 0
@@ -126,29 +147,30 @@ func (timer *Timer) WriteByte(addr uint16, value byte) {
 	switch {
 	// Timer
 	case addr == DIV: // Divider
-		// Writing any value to DIV resets it to 0
-		timer.DIV = 0
+		// Writing any value to DIV resets the whole system counter, which
+		// can cause a falling edge on the selected bit and increment TIMA
+		timer.setSystemCounter(0)
 	case addr == TIMA: // Timer Counter
+		// Writing TIMA in the cycle after an overflow cancels the reload,
+		// writes in the cycle TIMA is reloaded are ignored
+		if timer.reloading {
+			return
+		}
+		timer.overflow = false
 		timer.TIMA = value
 	case addr == TMA: // Timer Modulo
 		timer.TMA = value
+		if timer.reloading {
+			timer.TIMA = value
+		}
 	case addr == TAC:
-		currentfreq := timer.getClockFrequency()
-		timer.TAC = value
-
-		newfreq := timer.getClockFrequency()
-
-		if currentfreq != newfreq {
-			switch newfreq {
-			case 0:
-				timer.timerCounter = 1024 // frequency 4096
-			case 1:
-				timer.timerCounter = 16 // frequency 262144
-			case 2:
-				timer.timerCounter = 64 // frequency 65536
-			case 3:
-				timer.timerCounter = 256 // frequency 16382
-			}
+		// Changing the frequency or disabling the timer can cause a
+		// falling edge on the timer signal and increment TIMA
+		before := timer.timerSignal()
+		timer.TAC = value | 0xF8
+
+		if before && !timer.timerSignal() {
+			timer.incrementTIMA()
 		}
 	}
 }
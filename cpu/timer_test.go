@@ -0,0 +1,161 @@
+package cpu
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+func newTestTimer() (*Timer, *CPU) {
+	m := mmu.NewMMU()
+	cpu := NewCPU(m)
+	timer := cpu.timer
+	timer.systemCounter = 0
+	cpu.IF = 0
+	return timer, cpu
+}
+
+func TestDIV(t *testing.T) {
+	timer, _ := newTestTimer()
+
+	// DIV is incremented every 64 M-cycles
+	timer.Tick(63)
+	if timer.ReadByte(DIV) != 0 {
+		t.Errorf("DIV should still be 0 after 63 M-cycles")
+	}
+	timer.Tick(1)
+	if timer.ReadByte(DIV) != 1 {
+		t.Errorf("DIV should be 1 after 64 M-cycles but was %v", timer.ReadByte(DIV))
+	}
+
+	timer.WriteByte(DIV, 0x55)
+	if timer.ReadByte(DIV) != 0 || timer.systemCounter != 0 {
+		t.Errorf("writing to DIV should reset the system counter")
+	}
+}
+
+func TestTIMAFrequency(t *testing.T) {
+	cases := []struct {
+		TAC     byte
+		MCycles int
+	}{
+		{0x04, 256},
+		{0x05, 4},
+		{0x06, 16},
+		{0x07, 64},
+	}
+	for _, tt := range cases {
+		t.Run(fmt.Sprintf("TAC %#x", tt.TAC), func(t *testing.T) {
+			timer, _ := newTestTimer()
+			timer.WriteByte(TAC, tt.TAC)
+
+			timer.Tick(tt.MCycles - 1)
+			if timer.TIMA != 0 {
+				t.Errorf("TIMA should not have incremented after %v M-cycles", tt.MCycles-1)
+			}
+			timer.Tick(1)
+			if timer.TIMA != 1 {
+				t.Errorf("TIMA should have incremented after %v M-cycles", tt.MCycles)
+			}
+		})
+	}
+}
+
+func TestDIVWriteIncrementsTIMA(t *testing.T) {
+	timer, _ := newTestTimer()
+	timer.WriteByte(TAC, 0x05)
+
+	// Bit 3 of the system counter is set after 2 M-cycles,
+	// resetting DIV causes a falling edge
+	timer.Tick(2)
+	timer.WriteByte(DIV, 0)
+
+	if timer.TIMA != 1 {
+		t.Errorf("resetting DIV while the selected bit is set should increment TIMA, TIMA is %v", timer.TIMA)
+	}
+}
+
+func TestTACWriteIncrementsTIMA(t *testing.T) {
+	timer, _ := newTestTimer()
+	timer.WriteByte(TAC, 0x05)
+	timer.Tick(2)
+
+	// Disabling the timer while the selected bit is set causes a falling edge
+	timer.WriteByte(TAC, 0x01)
+
+	if timer.TIMA != 1 {
+		t.Errorf("disabling the timer while the selected bit is set should increment TIMA, TIMA is %v", timer.TIMA)
+	}
+	if timer.ReadByte(TAC) != 0xF9 {
+		t.Errorf("unused TAC bits should read as 1")
+	}
+}
+
+func TestTIMAOverflowDelay(t *testing.T) {
+	timer, cpu := newTestTimer()
+	timer.WriteByte(TAC, 0x05)
+	timer.WriteByte(TMA, 0x42)
+	timer.TIMA = 0xFF
+
+	timer.Tick(4)
+	if timer.TIMA != 0x00 {
+		t.Errorf("TIMA should read 0x00 for 1 M-cycle after overflowing but was %#x", timer.TIMA)
+	}
+	if cpu.IF&(1<<TIMER_OVERFLOW_INTERRUPT) != 0 {
+		t.Errorf("the timer interrupt should not be requested until TIMA is reloaded")
+	}
+
+	timer.Tick(1)
+	if timer.TIMA != 0x42 {
+		t.Errorf("TIMA should have been reloaded from TMA but was %#x", timer.TIMA)
+	}
+	if cpu.IF&(1<<TIMER_OVERFLOW_INTERRUPT) == 0 {
+		t.Errorf("the timer interrupt should have been requested")
+	}
+}
+
+func TestTIMAWriteDuringOverflow(t *testing.T) {
+	timer, cpu := newTestTimer()
+	timer.WriteByte(TAC, 0x05)
+	timer.WriteByte(TMA, 0x42)
+	timer.TIMA = 0xFF
+	timer.Tick(4)
+
+	// Writing TIMA before the reload cancels it
+	timer.WriteByte(TIMA, 0x10)
+	timer.Tick(1)
+
+	if timer.TIMA != 0x10 {
+		t.Errorf("writing TIMA after an overflow should cancel the reload, TIMA is %#x", timer.TIMA)
+	}
+	if cpu.IF&(1<<TIMER_OVERFLOW_INTERRUPT) != 0 {
+		t.Errorf("a cancelled reload should not request the timer interrupt")
+	}
+}
+
+func TestWritesDuringReload(t *testing.T) {
+	timer, _ := newTestTimer()
+	timer.WriteByte(TAC, 0x05)
+	timer.WriteByte(TMA, 0x42)
+	timer.TIMA = 0xFF
+	timer.Tick(5)
+
+	// Writes to TIMA in the reload cycle are ignored
+	timer.WriteByte(TIMA, 0x10)
+	if timer.TIMA != 0x42 {
+		t.Errorf("writing TIMA during the reload should be ignored, TIMA is %#x", timer.TIMA)
+	}
+
+	// Writes to TMA in the reload cycle are also copied to TIMA
+	timer.WriteByte(TMA, 0x24)
+	if timer.TIMA != 0x24 {
+		t.Errorf("writing TMA during the reload should also update TIMA, TIMA is %#x", timer.TIMA)
+	}
+
+	timer.Tick(1)
+	timer.WriteByte(TMA, 0x33)
+	if timer.TIMA != 0x24 {
+		t.Errorf("writing TMA after the reload should not update TIMA")
+	}
+}
@@ -0,0 +1,139 @@
+package gameboy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kevinbrolly/GopherBoy/ramsearch"
+)
+
+const consoleUsage = `help                             show this help
+pause, resume                    pause or resume the emulator
+cheat list                       list the cheats
+cheat add CODE [NAME]            add a Game Genie or GameShark code
+cheat on|off|remove N            turn on, turn off or remove cheat N
+cheat save                       save the cheats to the ROM's cheat list
+view tiles|map9800|map9c00|oam   open or close a window showing VRAM or OAM
+export VIEW [FILE]               save a view as a PNG, in the screenshot directory without a file
+oam                              list the sprites in OAM with their attributes
+` + ramsearch.Usage
+
+// StartConsole reads commands from r, one per line, which Run runs between frames,
+// writing their output to w. It is usually a terminal for finding cheats with the
+// RAM search while playing.
+func (gameboy *Gameboy) StartConsole(r io.Reader, w io.Writer) {
+	gameboy.console = w
+	gameboy.commands = make(chan string, 16)
+
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			gameboy.commands <- scanner.Text()
+		}
+	}()
+
+	fmt.Fprint(w, "> ")
+}
+
+// runCommands runs the commands that have been entered since the last frame
+func (gameboy *Gameboy) runCommands() {
+	for {
+		select {
+		case line := <-gameboy.commands:
+			if err := gameboy.Exec(line, gameboy.console); err != nil {
+				fmt.Fprintf(gameboy.console, "Error: %v\n", err)
+			}
+			fmt.Fprint(gameboy.console, "> ")
+		default:
+			return
+		}
+	}
+}
+
+// Exec runs a console command, writing its output to w
+func (gameboy *Gameboy) Exec(line string, w io.Writer) error {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return nil
+	}
+
+	switch args[0] {
+	case "help":
+		fmt.Fprintln(w, consoleUsage)
+	case "pause":
+		gameboy.SetPaused(true)
+	case "resume":
+		gameboy.SetPaused(false)
+	case "search":
+		return gameboy.RAMSearch.Exec(args[1:], w)
+	case "cheat":
+		return gameboy.execCheat(args[1:], w)
+	case "view":
+		if len(args) < 2 {
+			return fmt.Errorf("view needs one of %v", Views)
+		}
+		return gameboy.ToggleViewer(View(args[1]))
+	case "export":
+		if len(args) < 2 {
+			return fmt.Errorf("export needs one of %v", Views)
+		}
+		if len(args) > 2 {
+			return gameboy.saveView(View(args[1]), args[2])
+		}
+		filename, err := gameboy.SaveView(View(args[1]))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Saved %v\n", filename)
+	case "oam":
+		gameboy.PPU.DescribeOAM(w)
+	default:
+		return fmt.Errorf("unknown command %q, try help", args[0])
+	}
+	return nil
+}
+
+func (gameboy *Gameboy) execCheat(args []string, w io.Writer) error {
+	if gameboy.Cheats == nil {
+		return fmt.Errorf("no cartridge is loaded")
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		for i, cheat := range gameboy.Cheats.Cheats {
+			state := "off"
+			if cheat.Enabled {
+				state = "on"
+			}
+			fmt.Fprintf(w, "%v: %-3s %-11s %v\n", i, state, cheat.Code, cheat.Name)
+		}
+		return nil
+	case "add":
+		if len(args) < 2 {
+			return fmt.Errorf("cheat add needs a code")
+		}
+		_, err := gameboy.Cheats.Add(args[1], strings.Join(args[2:], " "))
+		return err
+	case "save":
+		return gameboy.SaveCheats()
+	case "on", "off", "remove":
+		if len(args) < 2 {
+			return fmt.Errorf("cheat %v needs the cheat's number", args[0])
+		}
+		index, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid cheat number %q", args[1])
+		}
+		if args[0] == "remove" {
+			return gameboy.Cheats.Remove(index)
+		}
+		return gameboy.Cheats.SetEnabled(index, args[0] == "on")
+	}
+	return fmt.Errorf("unknown cheat command %q", args[0])
+}
@@ -3,15 +3,23 @@ package gameboy
 import (
 	"fmt"
 	"image"
+	"io"
+	"os"
 	"time"
 
 	"github.com/kevinbrolly/GopherBoy/apu"
 	"github.com/kevinbrolly/GopherBoy/cartridge"
+	"github.com/kevinbrolly/GopherBoy/cheats"
 	"github.com/kevinbrolly/GopherBoy/control"
 	"github.com/kevinbrolly/GopherBoy/cpu"
+	"github.com/kevinbrolly/GopherBoy/midi"
 	"github.com/kevinbrolly/GopherBoy/mmu"
+	"github.com/kevinbrolly/GopherBoy/movie"
 	"github.com/kevinbrolly/GopherBoy/ppu"
+	"github.com/kevinbrolly/GopherBoy/ramsearch"
+	"github.com/kevinbrolly/GopherBoy/record"
 	"github.com/kevinbrolly/GopherBoy/utils"
+	"github.com/kevinbrolly/GopherBoy/vgm"
 
 	"github.com/veandco/go-sdl2/sdl"
 )
@@ -33,6 +41,12 @@ type Gameboy struct {
 	APU        *apu.APU
 	Controller *control.Controller
 	Cartridge  *cartridge.Cartridge
+	// Cheats are the Game Genie and GameShark codes applied to the cartridge, the ROM's cheat list is loaded with it
+	Cheats *cheats.Engine
+	// RAMSearch finds the addresses of values in RAM, which can be promoted to cheats
+	RAMSearch *ramsearch.Search
+
+	romFilename string
 
 	inBootMode        bool
 	dmgStatusRegister byte
@@ -41,6 +55,50 @@ type Gameboy struct {
 
 	debug   byte
 	running bool
+
+	// console is where the output of commands from StartConsole is written
+	console  io.Writer
+	commands chan string
+
+	// AudioMode is what happens to the sound set with SetAudio when not running at normal speed
+	AudioMode AudioMode
+	audio     *audioOutput
+
+	speed  float64
+	turbo  bool
+	paused bool
+	// advance runs a frame while paused
+	advance bool
+	// measuredSpeed is how many times faster than normal the last few frames ran
+	measuredSpeed float64
+
+	// ScreenshotDir is the directory screenshots taken with the screenshot hotkey are saved in
+	ScreenshotDir    string
+	ScreenshotFormat ScreenshotFormat
+
+	// RecordDir is the directory recordings started with the record hotkey are saved in
+	RecordDir    string
+	RecordFormat record.Format
+	recorder     *record.Recorder
+
+	// AudioStems records each channel to its own file when recording audio with the hotkey
+	AudioStems    bool
+	audioRecorder *record.AudioRecorder
+
+	vgmRecorder  *vgm.Recorder
+	midiRecorder *midi.Recorder
+
+	movie     *movie.Session
+	movieFile string
+
+	// gamepads are the connected gamepads, by joystick instance ID
+	gamepads map[sdl.JoystickID]*sdl.GameController
+	// viewers are the open windows showing views of the PPU's memory
+	viewers map[View]*viewer
+
+	// Palettes that can be cycled through with the palette hotkey
+	palettes     []ppu.Palettes
+	paletteIndex int
 }
 
 func NewGameboy(window Window) (gameboy *Gameboy) {
@@ -49,6 +107,7 @@ func NewGameboy(window Window) (gameboy *Gameboy) {
 	ppu := ppu.NewPPU(mmu)
 	apu := apu.NewAPU(mmu)
 	controller := control.NewController(mmu)
+	apu.Clock = cpu
 
 	gameboy = &Gameboy{
 		Window:     window,
@@ -57,8 +116,22 @@ func NewGameboy(window Window) (gameboy *Gameboy) {
 		PPU:        ppu,
 		APU:        apu,
 		Controller: controller,
+
+		ScreenshotFormat: ScreenshotFormat{Scale: 1},
+
+		palettes: presetPalettes(),
+		gamepads: map[sdl.JoystickID]*sdl.GameController{},
+		viewers:  map[View]*viewer{},
+
+		speed:         1,
+		measuredSpeed: 1,
 	}
 
+	gameboy.RAMSearch = ramsearch.NewSearch(mmu)
+
+	gameboy.audio = &audioOutput{gameboy: gameboy}
+	apu.AddSink(gameboy.audio)
+
 	// Map memory for outputting result of blargg tests
 	mmu.MapMemory(gameboy, 0xFF01)
 	mmu.MapMemory(gameboy, 0xFF02)
@@ -73,105 +146,194 @@ func NewGameboy(window Window) (gameboy *Gameboy) {
 
 func (gameboy *Gameboy) LoadCartridge(filename string) {
 	gameboy.Cartridge = cartridge.NewCartridge(filename, gameboy.MMU)
+	gameboy.romFilename = filename
+
+	gameboy.Cheats = cheats.NewEngine(gameboy.MMU)
+	gameboy.RAMSearch.Cheats = gameboy.Cheats
+	if err := gameboy.Cheats.Load(cheats.Filename(filename)); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Error loading cheats: %v\n", err)
+	}
+}
+
+// SaveCheats saves the cheats to the ROM's cheat list, which is loaded with the ROM next time
+func (gameboy *Gameboy) SaveCheats() error {
+	return gameboy.Cheats.Save(cheats.Filename(gameboy.romFilename))
+}
+
+// presetPalettes returns a copy of the built in palettes
+func presetPalettes() []ppu.Palettes {
+	return append([]ppu.Palettes{}, ppu.Presets...)
+}
+
+// SetPalettes changes the colors the screen is drawn in, palettes
+// that are not presets are added to the palettes that can be cycled through
+func (gameboy *Gameboy) SetPalettes(palettes ppu.Palettes) {
+	index := -1
+	for i, p := range gameboy.palettes {
+		if p == palettes {
+			index = i
+		}
+	}
+	if index == -1 {
+		gameboy.palettes = append(gameboy.palettes, palettes)
+		index = len(gameboy.palettes) - 1
+	}
+
+	gameboy.paletteIndex = index
+	gameboy.PPU.Palettes = palettes
+}
+
+// LoadPaletteFile loads custom palettes from a file and switches to them
+func (gameboy *Gameboy) LoadPaletteFile(filename string) error {
+	palettes, err := ppu.LoadPaletteFile(filename)
+	if err != nil {
+		return err
+	}
+	gameboy.SetPalettes(palettes)
+	return nil
+}
+
+// CyclePalettes switches to the next palette and returns it
+func (gameboy *Gameboy) CyclePalettes() ppu.Palettes {
+	gameboy.paletteIndex = (gameboy.paletteIndex + 1) % len(gameboy.palettes)
+	gameboy.PPU.Palettes = gameboy.palettes[gameboy.paletteIndex]
+	return gameboy.PPU.Palettes
 }
 
 func (gameboy *Gameboy) Run() {
+	// A frame is 154 lines of 456 dots, which is slightly slower than 60 Hz
+	normalFrameTime := time.Second * cpu.CyclesPerFrame / cpu.ClockSpeed
+
+	now := time.Now()
+	deadline := now
+	lastFrame := now
+	var lastDraw time.Time
+
+	gameboy.running = true
+	for gameboy.running {
+		if gameboy.paused && !gameboy.advance {
+			time.Sleep(normalFrameTime)
+			gameboy.handleEvents()
+			gameboy.runCommands()
+
+			deadline = time.Now()
+			lastFrame = deadline
+			continue
+		}
+		gameboy.advance = false
+
+		gameboy.RunFrame()
+		gameboy.handleEvents()
+		gameboy.runCommands()
 
-	frameTime := time.Second / 60
-
-	ticker := time.NewTicker(frameTime)
-	start := time.Now()
-	frames := 0
-	for range ticker.C {
-		MAXCYCLES := 69905
-		cyclesThisUpdate := 0
-
-		for cyclesThisUpdate < MAXCYCLES {
-			cycles := gameboy.CPU.Step()
-			gameboy.PPU.Step(cycles)
-			gameboy.APU.Tick(cycles)
-			cyclesThisUpdate += cycles
-
-			if gameboy.Controller.Debug {
-				fmt.Printf("OPCODE: %#x, Desc: %v, LY: %#x, PC: %#x, SP: %#x, IME: %v, IE: %#x, IF: %#x, LCDC: %#x, AF: %#x, BC: %#x, DE: %#x, HL: %#x\n",
-					gameboy.CPU.GetOpcode(),
-					gameboy.CPU.CurrentInstruction.Description,
-					gameboy.PPU.LY,
-					gameboy.CPU.PC,
-					gameboy.CPU.SP,
-					gameboy.CPU.IME,
-					gameboy.CPU.IE,
-					gameboy.CPU.IF,
-					gameboy.PPU.LCDC,
-					utils.JoinBytes(gameboy.CPU.Registers.A, gameboy.CPU.Registers.F),
-					utils.JoinBytes(gameboy.CPU.Registers.B, gameboy.CPU.Registers.C),
-					utils.JoinBytes(gameboy.CPU.Registers.D, gameboy.CPU.Registers.E),
-					utils.JoinBytes(gameboy.CPU.Registers.H, gameboy.CPU.Registers.L),
-				)
-			}
+		if !gameboy.running {
+			break
 		}
 
-		// Check for events
-		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			switch e := event.(type) {
-			case *sdl.QuitEvent:
-				gameboy.Quit()
-
-			case *sdl.KeyboardEvent:
-				if e.Type == sdl.KEYDOWN {
-					switch e.Keysym.Sym {
-					case sdl.K_RIGHT:
-						gameboy.Controller.KeyPressed(control.RIGHT)
-					case sdl.K_LEFT:
-						gameboy.Controller.KeyPressed(control.LEFT)
-					case sdl.K_UP:
-						gameboy.Controller.KeyPressed(control.UP)
-					case sdl.K_DOWN:
-						gameboy.Controller.KeyPressed(control.DOWN)
-					case sdl.K_s:
-						gameboy.Controller.KeyPressed(control.A)
-					case sdl.K_a:
-						gameboy.Controller.KeyPressed(control.B)
-					case sdl.K_SPACE:
-						gameboy.Controller.KeyPressed(control.SELECT)
-					case sdl.K_RETURN:
-						gameboy.Controller.KeyPressed(control.START)
-					case sdl.K_z:
-						gameboy.Controller.KeyPressed(control.DEBUG)
-					}
-				} else if e.Type == sdl.KEYUP {
-					switch e.Keysym.Sym {
-					case sdl.K_RIGHT:
-						gameboy.Controller.KeyReleased(control.RIGHT)
-					case sdl.K_LEFT:
-						gameboy.Controller.KeyReleased(control.LEFT)
-					case sdl.K_UP:
-						gameboy.Controller.KeyReleased(control.UP)
-					case sdl.K_DOWN:
-						gameboy.Controller.KeyReleased(control.DOWN)
-					case sdl.K_s:
-						gameboy.Controller.KeyReleased(control.A)
-					case sdl.K_a:
-						gameboy.Controller.KeyReleased(control.B)
-					case sdl.K_SPACE:
-						gameboy.Controller.KeyReleased(control.SELECT)
-					case sdl.K_RETURN:
-						gameboy.Controller.KeyReleased(control.START)
-					case sdl.K_z:
-						gameboy.Controller.KeyPressed(control.DEBUG)
-					}
-				}
-			}
+		now = time.Now()
+		if elapsed := now.Sub(lastFrame); elapsed > 0 {
+			// Smoothed over the last few frames
+			gameboy.measuredSpeed += (float64(normalFrameTime)/float64(elapsed) - gameboy.measuredSpeed) / 8
+		}
+		lastFrame = now
+
+		// Drawing is skipped while behind, as long as the screen is still
+		// drawn at least as often as it would be at normal speed
+		deadline = deadline.Add(gameboy.frameTime())
+		if now.Before(deadline) || now.Sub(lastDraw) >= normalFrameTime || gameboy.paused {
+			gameboy.Window.DrawFrame(gameboy.PPU.FrameBuffer)
+			gameboy.updateViewers()
+			lastDraw = now
 		}
 
-		gameboy.Window.DrawFrame(gameboy.PPU.FrameBuffer)
-		frames++
-		since := time.Since(start)
-		if since > time.Second {
-			start = time.Now()
-			frames = 0
+		if wait := time.Until(deadline); wait > 0 {
+			time.Sleep(wait)
+		} else if gameboy.turbo || -wait > maxLag {
+			// Too far behind to catch up, such as after the window was dragged
+			deadline = time.Now()
 		}
 	}
+
+	gameboy.closeViewers()
+
+	if err := gameboy.StopRecording(); err != nil {
+		fmt.Printf("Error saving recording: %v\n", err)
+	}
+	if err := gameboy.StopAudioRecording(); err != nil {
+		fmt.Printf("Error saving audio recording: %v\n", err)
+	}
+	if err := gameboy.StopVGM(); err != nil {
+		fmt.Printf("Error saving VGM: %v\n", err)
+	}
+	if err := gameboy.StopMIDI(); err != nil {
+		fmt.Printf("Error saving MIDI: %v\n", err)
+	}
+	if err := gameboy.StopMovie(); err != nil {
+		fmt.Printf("Error saving movie: %v\n", err)
+	}
+}
+
+// RunFrame runs the emulator until the PPU has finished drawing a frame, or for
+// one frame's worth of cycles while the LCD is off. The audio for the frame is
+// sent to the APU sinks and the frame is recorded if recording.
+func (gameboy *Gameboy) RunFrame() {
+	// Buttons pressed by a movie request the joypad interrupt at the start of the frame,
+	// as the buttons pressed by the player do when they are handled between frames
+	gameboy.Controller.Update()
+
+	frame := gameboy.PPU.FrameCount
+	for cycles := 0; cycles < cpu.CyclesPerFrame && gameboy.PPU.FrameCount == frame; {
+		cycles += gameboy.step()
+	}
+
+	gameboy.APU.Flush()
+
+	// The frame ends at the start of VBlank, when GameShark codes are written
+	if gameboy.Cheats != nil {
+		gameboy.Cheats.Apply()
+	}
+
+	if gameboy.midiRecorder != nil {
+		gameboy.midiRecorder.Update()
+	}
+
+	if gameboy.movie != nil {
+		gameboy.endMovieFrame()
+	}
+
+	if gameboy.recorder != nil {
+		if err := gameboy.recorder.WriteFrame(gameboy.PPU.FrameBuffer); err != nil {
+			fmt.Printf("Error recording frame: %v\n", err)
+			gameboy.StopRecording()
+		}
+	}
+}
+
+// step runs a single CPU instruction and the rest of the hardware for the same number of cycles
+func (gameboy *Gameboy) step() int {
+	cycles := gameboy.CPU.Step()
+	gameboy.PPU.Step(cycles)
+	gameboy.APU.Tick(cycles)
+
+	if gameboy.Controller.Debug {
+		fmt.Printf("OPCODE: %#x, Desc: %v, LY: %#x, PC: %#x, SP: %#x, IME: %v, IE: %#x, IF: %#x, LCDC: %#x, AF: %#x, BC: %#x, DE: %#x, HL: %#x\n",
+			gameboy.CPU.GetOpcode(),
+			gameboy.CPU.CurrentInstruction.Description,
+			gameboy.PPU.LY,
+			gameboy.CPU.PC,
+			gameboy.CPU.SP,
+			gameboy.CPU.IME,
+			gameboy.CPU.IE,
+			gameboy.CPU.IF,
+			gameboy.PPU.LCDC,
+			utils.JoinBytes(gameboy.CPU.Registers.A, gameboy.CPU.Registers.F),
+			utils.JoinBytes(gameboy.CPU.Registers.B, gameboy.CPU.Registers.C),
+			utils.JoinBytes(gameboy.CPU.Registers.D, gameboy.CPU.Registers.E),
+			utils.JoinBytes(gameboy.CPU.Registers.H, gameboy.CPU.Registers.L),
+		)
+	}
+
+	return cycles
 }
 
 func (gameboy *Gameboy) Quit() {
@@ -0,0 +1,138 @@
+package gameboy
+
+import (
+	"fmt"
+
+	"github.com/kevinbrolly/GopherBoy/control"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// handleEvents handles SDL input and window events. Keys and gamepad buttons are
+// passed to the controller as inputs, which presses the buttons bound to them and
+// returns the hotkeys bound to them.
+func (gameboy *Gameboy) handleEvents() {
+	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+		switch e := event.(type) {
+		case *sdl.QuitEvent:
+			gameboy.Quit()
+
+		case *sdl.WindowEvent:
+			// Keys released while the window isn't focused are never seen
+			if e.Event == sdl.WINDOWEVENT_FOCUS_LOST {
+				gameboy.Controller.ReleaseAll()
+			}
+			// With viewer windows open SDL only quits once every window is closed,
+			// so closing the screen's window quits and closing a viewer closes it
+			if e.Event == sdl.WINDOWEVENT_CLOSE && !gameboy.closeViewerWindow(e.WindowID) {
+				gameboy.Quit()
+			}
+
+		case *sdl.KeyboardEvent:
+			input := sdl.GetKeyName(e.Keysym.Sym)
+			if e.Type == sdl.KEYDOWN {
+				gameboy.runHotkey(gameboy.Controller.InputPressed(input))
+			} else if e.Type == sdl.KEYUP {
+				gameboy.Controller.InputReleased(input)
+			}
+
+		case *sdl.ControllerDeviceEvent:
+			if e.Type == sdl.CONTROLLERDEVICEADDED {
+				gameboy.openGamepad(int(e.Which))
+			} else if e.Type == sdl.CONTROLLERDEVICEREMOVED {
+				gameboy.closeGamepad(e.Which)
+			}
+
+		case *sdl.ControllerButtonEvent:
+			input := control.PadInput(int(e.Which), sdl.GameControllerGetStringForButton(sdl.GameControllerButton(e.Button)))
+			if e.Type == sdl.CONTROLLERBUTTONDOWN {
+				gameboy.runHotkey(gameboy.Controller.InputPressed(input))
+			} else if e.Type == sdl.CONTROLLERBUTTONUP {
+				gameboy.Controller.InputReleased(input)
+			}
+
+		case *sdl.ControllerAxisEvent:
+			axis := control.PadInput(int(e.Which), sdl.GameControllerGetStringForAxis(sdl.GameControllerAxis(e.Axis)))
+			gameboy.runHotkey(gameboy.Controller.AxisMoved(axis, float64(e.Value)/32768))
+		}
+	}
+}
+
+// openGamepad opens a gamepad that has been plugged in, SDL also sends
+// an event for each gamepad that is already plugged in when it starts
+func (gameboy *Gameboy) openGamepad(index int) {
+	gamepad := sdl.GameControllerOpen(index)
+	if gamepad == nil {
+		fmt.Printf("Error opening gamepad: %v\n", sdl.GetError())
+		return
+	}
+
+	gameboy.gamepads[gamepad.Joystick().InstanceID()] = gamepad
+	fmt.Printf("Connected gamepad: %v\n", gamepad.Name())
+}
+
+// closeGamepad closes a gamepad that has been unplugged, releasing what was held on it as its releases will never come
+func (gameboy *Gameboy) closeGamepad(id sdl.JoystickID) {
+	gamepad, ok := gameboy.gamepads[id]
+	if !ok {
+		return
+	}
+
+	fmt.Printf("Disconnected gamepad: %v\n", gamepad.Name())
+	gamepad.Close()
+	delete(gameboy.gamepads, id)
+	gameboy.Controller.ReleasePad(int(id))
+}
+
+// runHotkey runs an emulator hotkey
+func (gameboy *Gameboy) runHotkey(hotkey string) {
+	switch hotkey {
+	case control.HotkeyDebug:
+		gameboy.Controller.KeyPressed(control.DEBUG)
+	case control.HotkeyPalette:
+		palettes := gameboy.CyclePalettes()
+		fmt.Printf("Palette: %v\n", palettes.Name)
+	case control.HotkeyMIDI:
+		gameboy.toggleMIDI()
+	case control.HotkeyVGM:
+		gameboy.toggleVGM()
+	case control.HotkeyAudio:
+		gameboy.toggleAudioRecording()
+	case control.HotkeyRecord:
+		gameboy.toggleRecording()
+	case control.HotkeyTurbo:
+		gameboy.SetTurbo(!gameboy.turbo)
+		fmt.Printf("Turbo: %v\n", gameboy.turbo)
+	case control.HotkeySpeedUp:
+		gameboy.stepSpeed(true)
+	case control.HotkeySlowDown:
+		gameboy.stepSpeed(false)
+	case control.HotkeyPause:
+		gameboy.SetPaused(!gameboy.paused)
+		if gameboy.paused {
+			fmt.Println("Paused")
+		} else {
+			fmt.Println("Resumed")
+		}
+	case control.HotkeyFrameAdvance:
+		gameboy.AdvanceFrame()
+	case control.HotkeyMovieReadOnly:
+		gameboy.toggleMovieReadOnly()
+	case control.HotkeyViewTiles:
+		gameboy.toggleViewer(ViewTiles)
+	case control.HotkeyViewMap9800:
+		gameboy.toggleViewer(ViewMap9800)
+	case control.HotkeyViewMap9C00:
+		gameboy.toggleViewer(ViewMap9C00)
+	case control.HotkeyViewOAM:
+		gameboy.toggleViewer(ViewOAM)
+	case control.HotkeyExportViews:
+		gameboy.saveViews()
+	case control.HotkeyScreenshot:
+		if filename, err := gameboy.SaveScreenshot(); err != nil {
+			fmt.Printf("Error saving screenshot: %v\n", err)
+		} else {
+			fmt.Printf("Saved screenshot: %v\n", filename)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package gameboy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/kevinbrolly/GopherBoy/movie"
+)
+
+// StartMovieRecording records the buttons pressed from now on to the movie file filename,
+// which is written by StopMovie. It should be called before the first frame is run, as
+// movies start from power on.
+func (gameboy *Gameboy) StartMovieRecording(filename string) error {
+	if gameboy.movie != nil {
+		return fmt.Errorf("already recording or playing a movie")
+	}
+
+	m := &movie.Movie{
+		ROMHash: gameboy.Cartridge.Hash(),
+		Model:   gameboy.APU.Model.String(),
+	}
+	gameboy.movie = movie.NewRecording(m, gameboy.Controller)
+	gameboy.movieFile = filename
+	return nil
+}
+
+// PlayMovie plays back the movie file filename, it should be called before the first frame is
+// run. A read-write movie can be taken over with the read-only hotkey and is saved by StopMovie.
+func (gameboy *Gameboy) PlayMovie(filename string, readOnly bool) error {
+	if gameboy.movie != nil {
+		return fmt.Errorf("already recording or playing a movie")
+	}
+
+	m, err := movie.Load(filename)
+	if err != nil {
+		return err
+	}
+
+	if hash := gameboy.Cartridge.Hash(); m.ROMHash != hash {
+		return fmt.Errorf("movie was recorded with ROM %v but the ROM is %v", m.ROMHash, hash)
+	}
+	if model := gameboy.APU.Model.String(); m.Model != model {
+		return fmt.Errorf("movie was recorded on a %v but this is a %v", m.Model, model)
+	}
+
+	gameboy.movie = movie.NewPlayback(m, gameboy.Controller, readOnly)
+	gameboy.movieFile = filename
+	return nil
+}
+
+// StopMovie stops recording or playing back the movie, saving it if it has been recorded
+func (gameboy *Gameboy) StopMovie() error {
+	if gameboy.movie == nil {
+		return nil
+	}
+
+	session := gameboy.movie
+	session.Close()
+	gameboy.movie = nil
+
+	if !session.Recording() {
+		return nil
+	}
+	return session.Movie.Save(gameboy.movieFile)
+}
+
+// toggleMovieReadOnly switches the movie between read-only and read-write, taking over a movie being played back
+func (gameboy *Gameboy) toggleMovieReadOnly() {
+	if gameboy.movie == nil {
+		return
+	}
+
+	gameboy.movie.SetReadOnly(!gameboy.movie.ReadOnly())
+	if gameboy.movie.ReadOnly() {
+		fmt.Println("Movie is read-only")
+	} else {
+		fmt.Printf("Movie is read-write, recording from frame %v\n", gameboy.movie.Frame())
+	}
+}
+
+// endMovieFrame records or checks the state at the end of a frame
+func (gameboy *Gameboy) endMovieFrame() {
+	if err := gameboy.movie.EndFrame(gameboy.StateHash()); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
+	if gameboy.movie.Finished() {
+		fmt.Printf("Movie finished after %v frames\n", gameboy.movie.Frame())
+		gameboy.movie = nil
+	}
+}
+
+// StateHash returns a hash of the CPU registers, RAM, I/O registers, VRAM, OAM and the color
+// IDs on screen, which diverge quickly once the emulation is different to a recording. It
+// doesn't depend on the colors the screen is drawn in, so the palette can be changed freely.
+func (gameboy *Gameboy) StateHash() uint64 {
+	h := fnv.New64a()
+
+	cpu := gameboy.CPU
+	r := cpu.Registers
+	h.Write([]byte{r.A, r.F, r.B, r.C, r.D, r.E, r.H, r.L})
+	binary.Write(h, binary.LittleEndian, []uint16{cpu.SP, cpu.PC})
+
+	h.Write(gameboy.WorkingRAM[:])
+	h.Write(gameboy.HRAM[:])
+
+	// P1 is skipped, as reading it polls the movie
+	for addr := 0xFF01; addr <= 0xFF7F; addr++ {
+		h.Write([]byte{gameboy.MMU.Peek(uint16(addr))})
+	}
+	h.Write([]byte{gameboy.MMU.Peek(0xFFFF)})
+
+	h.Write(gameboy.PPU.VRAM[:])
+	for _, sprite := range gameboy.PPU.OAM {
+		h.Write([]byte{sprite.Y, sprite.X, sprite.TileNumber, sprite.Attributes})
+	}
+	for _, line := range gameboy.PPU.ColorIDs {
+		h.Write(line[:])
+	}
+
+	return h.Sum64()
+}
@@ -0,0 +1,40 @@
+package gameboy
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestStateHash(t *testing.T) {
+	gameboy := NewGameboy(nil)
+	hash := gameboy.StateHash()
+
+	// Changing the palette recolors the screen without changing the state
+	palettes := gameboy.CyclePalettes()
+	draw.Draw(gameboy.PPU.FrameBuffer, gameboy.PPU.FrameBuffer.Bounds(), &image.Uniform{palettes.BG[0]}, image.Point{}, draw.Src)
+	if h := gameboy.StateHash(); h != hash {
+		t.Errorf("StateHash() should have been %016x after changing the palette but was %016x", hash, h)
+	}
+
+	cases := []struct {
+		Name   string
+		Change func()
+	}{
+		{"Screen", func() { gameboy.PPU.ColorIDs[10][10] = 2 }},
+		{"VRAM", func() { gameboy.PPU.VRAM[0x1800] = 1 }},
+		{"OAM", func() { gameboy.PPU.OAM[0].X = 8 }},
+		{"I/O register", func() { gameboy.PPU.SCX = 3 }},
+		{"Work RAM", func() { gameboy.WorkingRAM[0] = 1 }},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			before := gameboy.StateHash()
+			tt.Change()
+			if h := gameboy.StateHash(); h == before {
+				t.Errorf("StateHash() should have changed but was %016x", h)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package gameboy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kevinbrolly/GopherBoy/midi"
+	"github.com/kevinbrolly/GopherBoy/record"
+	"github.com/kevinbrolly/GopherBoy/vgm"
+)
+
+// StartRecording records every frame and the audio from now on to filename, in
+// the format given by its extension, with the audio in a WAV file alongside it
+func (gameboy *Gameboy) StartRecording(filename string) error {
+	if gameboy.recorder != nil {
+		return fmt.Errorf("already recording")
+	}
+
+	recorder, err := record.Create(filename, gameboy.APU.SampleRate())
+	if err != nil {
+		return err
+	}
+
+	gameboy.recorder = recorder
+	gameboy.APU.AddSink(recorder)
+	return nil
+}
+
+// StopRecording stops recording and finishes the recorded files
+func (gameboy *Gameboy) StopRecording() error {
+	if gameboy.recorder == nil {
+		return nil
+	}
+
+	// Record any audio that hasn't been sent yet
+	gameboy.APU.Flush()
+	gameboy.APU.RemoveSink(gameboy.recorder)
+
+	err := gameboy.recorder.Close()
+	gameboy.recorder = nil
+	return err
+}
+
+// Recording returns true while recording
+func (gameboy *Gameboy) Recording() bool {
+	return gameboy.recorder != nil
+}
+
+// toggleRecording starts recording to a timestamped file in RecordDir, or stops recording
+func (gameboy *Gameboy) toggleRecording() {
+	if gameboy.Recording() {
+		if err := gameboy.StopRecording(); err != nil {
+			fmt.Printf("Error saving recording: %v\n", err)
+		} else {
+			fmt.Println("Stopped recording")
+		}
+		return
+	}
+
+	filename, err := gameboy.recordingFilename(gameboy.RecordFormat.Extension())
+	if err == nil {
+		err = gameboy.StartRecording(filename)
+	}
+	if err != nil {
+		fmt.Printf("Error starting recording: %v\n", err)
+		return
+	}
+	fmt.Printf("Recording to %v\n", filename)
+}
+
+// StartAudioRecording records the audio from now on to the WAV file filename. With stems,
+// each channel is also recorded to its own WAV file before it is mixed by NR50 and NR51.
+func (gameboy *Gameboy) StartAudioRecording(filename string, stems bool) error {
+	if gameboy.audioRecorder != nil {
+		return fmt.Errorf("already recording audio")
+	}
+
+	recorder, err := record.CreateAudio(filename, gameboy.APU.SampleRate(), stems)
+	if err != nil {
+		return err
+	}
+
+	gameboy.audioRecorder = recorder
+	gameboy.APU.AddSink(recorder)
+	if stems {
+		gameboy.APU.AddChannelSink(recorder)
+	}
+	return nil
+}
+
+// StopAudioRecording stops recording audio and finishes the WAV files
+func (gameboy *Gameboy) StopAudioRecording() error {
+	if gameboy.audioRecorder == nil {
+		return nil
+	}
+
+	// Record any audio that hasn't been sent yet
+	gameboy.APU.Flush()
+	gameboy.APU.RemoveSink(gameboy.audioRecorder)
+	gameboy.APU.RemoveChannelSink(gameboy.audioRecorder)
+
+	err := gameboy.audioRecorder.Close()
+	gameboy.audioRecorder = nil
+	return err
+}
+
+// RecordingAudio returns true while recording audio
+func (gameboy *Gameboy) RecordingAudio() bool {
+	return gameboy.audioRecorder != nil
+}
+
+// toggleAudioRecording starts recording audio to a timestamped file in RecordDir, or stops recording
+func (gameboy *Gameboy) toggleAudioRecording() {
+	if gameboy.RecordingAudio() {
+		if err := gameboy.StopAudioRecording(); err != nil {
+			fmt.Printf("Error saving audio recording: %v\n", err)
+		} else {
+			fmt.Println("Stopped recording audio")
+		}
+		return
+	}
+
+	filename, err := gameboy.recordingFilename(".wav")
+	if err == nil {
+		err = gameboy.StartAudioRecording(filename, gameboy.AudioStems)
+	}
+	if err != nil {
+		fmt.Printf("Error starting audio recording: %v\n", err)
+		return
+	}
+	fmt.Printf("Recording audio to %v\n", filename)
+}
+
+// StartVGM logs every write to the sound registers and wave RAM from now on to the VGM file filename
+func (gameboy *Gameboy) StartVGM(filename string) error {
+	if gameboy.vgmRecorder != nil {
+		return fmt.Errorf("already logging VGM")
+	}
+
+	recorder, err := vgm.Create(filename, gameboy.APU)
+	if err != nil {
+		return err
+	}
+
+	gameboy.vgmRecorder = recorder
+	return nil
+}
+
+// StopVGM stops logging sound register writes and finishes the VGM file
+func (gameboy *Gameboy) StopVGM() error {
+	if gameboy.vgmRecorder == nil {
+		return nil
+	}
+
+	err := gameboy.vgmRecorder.Close()
+	gameboy.vgmRecorder = nil
+	return err
+}
+
+// RecordingVGM returns true while logging sound register writes
+func (gameboy *Gameboy) RecordingVGM() bool {
+	return gameboy.vgmRecorder != nil
+}
+
+// toggleVGM starts logging sound register writes to a timestamped file in RecordDir, or stops logging
+func (gameboy *Gameboy) toggleVGM() {
+	if gameboy.RecordingVGM() {
+		if err := gameboy.StopVGM(); err != nil {
+			fmt.Printf("Error saving VGM: %v\n", err)
+		} else {
+			fmt.Println("Stopped logging VGM")
+		}
+		return
+	}
+
+	filename, err := gameboy.recordingFilename(".vgm")
+	if err == nil {
+		err = gameboy.StartVGM(filename)
+	}
+	if err != nil {
+		fmt.Printf("Error starting VGM log: %v\n", err)
+		return
+	}
+	fmt.Printf("Logging VGM to %v\n", filename)
+}
+
+// StartMIDI transcribes the music from now on to the Standard MIDI File filename
+func (gameboy *Gameboy) StartMIDI(filename string) error {
+	if gameboy.midiRecorder != nil {
+		return fmt.Errorf("already recording MIDI")
+	}
+
+	recorder, err := midi.Create(filename, gameboy.APU)
+	if err != nil {
+		return err
+	}
+
+	gameboy.midiRecorder = recorder
+	return nil
+}
+
+// StopMIDI stops transcribing the music and writes the MIDI file
+func (gameboy *Gameboy) StopMIDI() error {
+	if gameboy.midiRecorder == nil {
+		return nil
+	}
+
+	err := gameboy.midiRecorder.Close()
+	gameboy.midiRecorder = nil
+	return err
+}
+
+// RecordingMIDI returns true while transcribing the music
+func (gameboy *Gameboy) RecordingMIDI() bool {
+	return gameboy.midiRecorder != nil
+}
+
+// toggleMIDI starts transcribing the music to a timestamped file in RecordDir, or stops transcribing
+func (gameboy *Gameboy) toggleMIDI() {
+	if gameboy.RecordingMIDI() {
+		if err := gameboy.StopMIDI(); err != nil {
+			fmt.Printf("Error saving MIDI: %v\n", err)
+		} else {
+			fmt.Println("Stopped recording MIDI")
+		}
+		return
+	}
+
+	filename, err := gameboy.recordingFilename(".mid")
+	if err == nil {
+		err = gameboy.StartMIDI(filename)
+	}
+	if err != nil {
+		fmt.Printf("Error starting MIDI recording: %v\n", err)
+		return
+	}
+	fmt.Printf("Recording MIDI to %v\n", filename)
+}
+
+// recordingFilename returns a timestamped filename in RecordDir with the extension ext. A video
+// recording also writes a WAV file with the same name, so recordings started in the same
+// millisecond are numbered rather than one overwriting the other's files.
+func (gameboy *Gameboy) recordingFilename(ext string) (string, error) {
+	dir := gameboy.RecordDir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("GopherBoy-%s", time.Now().Format("20060102-150405.000")))
+	name := base
+	for i := 2; fileExists(name+ext) || fileExists(name+".wav"); i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return name + ext, nil
+}
+
+// fileExists returns true if there is a file at filename
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
@@ -0,0 +1,37 @@
+package gameboy
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecordingFilename(t *testing.T) {
+	gameboy := NewGameboy(nil)
+	gameboy.RecordDir = t.TempDir()
+
+	// A video recording and its WAV file, then an audio recording started straight after,
+	// which would be given the video's WAV filename if started in the same millisecond
+	video, err := gameboy.recordingFilename(".y4m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range []string{video, strings.TrimSuffix(video, ".y4m") + ".wav"} {
+		if err := os.WriteFile(filename, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		audio, err := gameboy.recordingFilename(".wav")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fileExists(audio) {
+			t.Errorf("recordingFilename() should not have returned the existing file %v", audio)
+		}
+		if err := os.WriteFile(audio, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package gameboy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/cpu"
+)
+
+// testROMsEnv names the directory holding the test ROM suites, which aren't distributed with
+// GopherBoy, laid out as they are released, such as dmg_sound/rom_singles/01-registers.gb
+// for blargg's tests and acceptance/ie_push.gb for mooneye's.
+// The tests that run them are skipped when it isn't set or a ROM is missing.
+const testROMsEnv = "GOPHERBOY_TEST_ROMS"
+
+// loadTestROM returns a Gameboy with the test ROM at path, in the test ROM directory, loaded
+func loadTestROM(t *testing.T, path ...string) *Gameboy {
+	dir := os.Getenv(testROMsEnv)
+	if dir == "" {
+		t.Skipf("%v is not set", testROMsEnv)
+	}
+
+	filename := filepath.Join(append([]string{dir}, path...)...)
+	if _, err := os.Stat(filename); err != nil {
+		t.Skipf("test ROM is missing: %v", err)
+	}
+
+	gameboy := NewGameboy(nil)
+	gameboy.LoadCartridge(filename)
+	return gameboy
+}
+
+func TestBlarggDMGSound(t *testing.T) {
+	roms := []string{
+		"01-registers",
+		"02-len ctr",
+		"03-trigger",
+		"04-sweep",
+		"05-sweep details",
+		"06-overflow on trigger",
+		"07-len sweep period sync",
+		"08-len ctr during power",
+		"09-wave read while on",
+		"10-wave trigger while on",
+		"11-regs after power",
+		"12-wave write while on",
+	}
+
+	for _, rom := range roms {
+		t.Run(rom, func(t *testing.T) {
+			gameboy := loadTestROM(t, "dmg_sound", "rom_singles", rom+".gb")
+
+			// blargg's tests write DE B0 61 to $A001-$A003 once they have started, then their
+			// status to $A000, which is $80 while running and 0 once passed, and the text
+			// they print from $A004
+			status := func() byte {
+				mmu := gameboy.MMU
+				if mmu.Peek(0xA001) != 0xDE || mmu.Peek(0xA002) != 0xB0 || mmu.Peek(0xA003) != 0x61 {
+					return 0x80
+				}
+				return mmu.Peek(0xA000)
+			}
+
+			for frame := 0; frame < 60*60 && status() == 0x80; frame++ {
+				gameboy.RunFrame()
+			}
+
+			if result := status(); result != 0 {
+				var text []byte
+				for addr := uint16(0xA004); addr < 0xC000 && gameboy.MMU.Peek(addr) != 0; addr++ {
+					text = append(text, gameboy.MMU.Peek(addr))
+				}
+				t.Errorf("result should have been 0 but was %#x: %s", result, text)
+			}
+		})
+	}
+}
+
+func TestMooneye(t *testing.T) {
+	roms := []string{
+		"ie_push",
+		"halt_ime0_ei",
+		"halt_ime0_nointr_timing",
+		"di_timing-GS",
+		"oam_dma_start",
+		"oam_dma_restart",
+		"oam_dma_timing",
+	}
+
+	for _, rom := range roms {
+		t.Run(rom, func(t *testing.T) {
+			gameboy := loadTestROM(t, "acceptance", rom+".gb")
+
+			// Mooneye's tests run LD B,B once they have finished, with the registers
+			// set to the Fibonacci numbers 3, 5, 8, 13, 21 and 34 if they passed
+			for cycles := 0; gameboy.CPU.GetOpcode() != 0x40; {
+				if cycles > 60*cpu.CyclesPerFrame {
+					t.Fatalf("test ROM should have finished within 60 frames")
+				}
+				cycles += gameboy.step()
+			}
+
+			r := gameboy.CPU.Registers
+			registers := [6]byte{r.B, r.C, r.D, r.E, r.H, r.L}
+			if expected := [6]byte{3, 5, 8, 13, 21, 34}; registers != expected {
+				t.Errorf("B, C, D, E, H and L should have been %v but were %v", expected, registers)
+			}
+		})
+	}
+}
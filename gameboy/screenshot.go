@@ -0,0 +1,83 @@
+package gameboy
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ScreenshotFormat controls how screenshots are written
+type ScreenshotFormat struct {
+	// Scale is the integer scale factor, each pixel is drawn as a Scale x Scale square
+	Scale int
+	// Indexed writes the 2-bit color ID of each pixel with the current
+	// palette as the PNG palette, instead of writing RGB colors
+	Indexed bool
+}
+
+// Screenshot writes the current frame to w as a PNG
+func (gameboy *Gameboy) Screenshot(w io.Writer, format ScreenshotFormat) error {
+	var frame image.Image = gameboy.PPU.FrameBuffer
+	if format.Indexed {
+		frame = gameboy.PPU.IndexedFrame()
+	}
+
+	if format.Scale > 1 {
+		frame = scaleImage(frame, format.Scale)
+	}
+
+	return png.Encode(w, frame)
+}
+
+// SaveScreenshot writes the current frame to a timestamped file in
+// ScreenshotDir using ScreenshotFormat and returns the filename
+func (gameboy *Gameboy) SaveScreenshot() (string, error) {
+	dir := gameboy.ScreenshotDir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(dir, fmt.Sprintf("GopherBoy-%s.png", time.Now().Format("20060102-150405.000")))
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+
+	if err := gameboy.Screenshot(f, gameboy.ScreenshotFormat); err != nil {
+		f.Close()
+		return "", err
+	}
+	return filename, f.Close()
+}
+
+// scaleImage scales img by an integer factor using nearest neighbour
+// scaling, so the pixels stay sharp and paletted images keep their palette
+func scaleImage(img image.Image, scale int) image.Image {
+	bounds := img.Bounds()
+	rect := image.Rect(0, 0, bounds.Dx()*scale, bounds.Dy()*scale)
+
+	if paletted, ok := img.(*image.Paletted); ok {
+		scaled := image.NewPaletted(rect, paletted.Palette)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				scaled.SetColorIndex(x, y, paletted.ColorIndexAt(bounds.Min.X+x/scale, bounds.Min.Y+y/scale))
+			}
+		}
+		return scaled
+	}
+
+	scaled := image.NewRGBA(rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			scaled.Set(x, y, img.At(bounds.Min.X+x/scale, bounds.Min.Y+y/scale))
+		}
+	}
+	return scaled
+}
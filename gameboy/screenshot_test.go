@@ -0,0 +1,74 @@
+package gameboy
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/ppu"
+)
+
+func TestScreenshot(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Scale   int
+		Indexed bool
+	}{
+		{"1x", 1, false},
+		{"3x", 3, false},
+		{"Indexed 1x", 1, true},
+		{"Indexed 2x", 2, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			gameboy := NewGameboy(nil)
+			gameboy.PPU.BGP = 0xE4
+			gameboy.PPU.Palettes = ppu.Presets[0]
+			// A dark pixel in the top left corner of a light screen
+			draw.Draw(gameboy.PPU.FrameBuffer, gameboy.PPU.FrameBuffer.Bounds(), &image.Uniform{ppu.Presets[0].BG[0]}, image.Point{}, draw.Src)
+			gameboy.PPU.ColorIDs[0][0] = 3
+			gameboy.PPU.FrameBuffer.SetRGBA(0, 0, ppu.Presets[0].BG[3])
+
+			var b bytes.Buffer
+			if err := gameboy.Screenshot(&b, ScreenshotFormat{Scale: tt.Scale, Indexed: tt.Indexed}); err != nil {
+				t.Fatal(err)
+			}
+			img, err := png.Decode(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if size := img.Bounds().Size(); size != image.Pt(160*tt.Scale, 144*tt.Scale) {
+				t.Errorf("Size should have been %vx%v but was %v", 160*tt.Scale, 144*tt.Scale, size)
+			}
+
+			if paletted, ok := img.(*image.Paletted); ok != tt.Indexed {
+				t.Errorf("Indexed should have been %v but was %v", tt.Indexed, ok)
+			} else if ok {
+				if len(paletted.Palette) != 4 {
+					t.Errorf("Palette should have had 4 colors but had %v", len(paletted.Palette))
+				}
+				if id := paletted.ColorIndexAt(tt.Scale-1, tt.Scale-1); id != 3 {
+					t.Errorf("Color ID should have been 3 but was %v", id)
+				}
+				if id := paletted.ColorIndexAt(tt.Scale, tt.Scale); id != 0 {
+					t.Errorf("Color ID after the pixel should have been 0 but was %v", id)
+				}
+			}
+
+			// The pixel covers a Scale x Scale square
+			dark := ppu.Presets[0].BG[3]
+			r, g, b2, _ := img.At(tt.Scale-1, tt.Scale-1).RGBA()
+			er, eg, eb, _ := dark.RGBA()
+			if r != er || g != eg || b2 != eb {
+				t.Errorf("Scaled pixel should have been %v but was %v", dark, img.At(tt.Scale-1, tt.Scale-1))
+			}
+			if r, _, _, _ := img.At(tt.Scale, tt.Scale).RGBA(); r == er {
+				t.Errorf("Pixel after the scaled pixel should not have been %v", dark)
+			}
+		})
+	}
+}
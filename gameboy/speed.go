@@ -0,0 +1,159 @@
+package gameboy
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/kevinbrolly/GopherBoy/apu"
+	"github.com/kevinbrolly/GopherBoy/cpu"
+)
+
+const (
+	MinSpeed = 0.25
+	MaxSpeed = 8
+
+	// maxLag is how far behind Run can fall before it stops trying to catch up
+	maxLag = 250 * time.Millisecond
+	// audioLatency is how far ahead of real time sound can be sent when skipping audio
+	audioLatency = 50 * time.Millisecond
+)
+
+// speeds are the speeds stepped through by the speed up and slow down hotkeys
+var speeds = []float64{0.25, 0.5, 1, 2, 4, 8}
+
+// AudioMode is what happens to the sound when running faster or slower than normal
+type AudioMode int
+
+const (
+	// AudioSkip drops frames of sound to keep up when running fast and leaves gaps when running slowly
+	AudioSkip AudioMode = iota
+	// AudioStretch time-stretches the sound to the speed, keeping its pitch
+	AudioStretch
+)
+
+// audioOutput sends the APU's sound to the audio device, adjusted to the speed
+type audioOutput struct {
+	gameboy   *Gameboy
+	sink      apu.Sink
+	stretcher *apu.Stretcher
+
+	// start is when sound started being skipped, sent is the number of frames sent since
+	start time.Time
+	sent  int
+}
+
+// SetAudio sets the sink that plays the sound in real time, unlike the sinks added
+// to the APU it is kept in step with real time when not running at normal speed
+func (gameboy *Gameboy) SetAudio(sink apu.Sink) {
+	gameboy.audio.sink = sink
+	gameboy.audio.stretcher = apu.NewStretcher(sink, gameboy.APU.SampleRate())
+	gameboy.audio.reset()
+}
+
+// Speed returns how many times faster than normal the emulator runs
+func (gameboy *Gameboy) Speed() float64 {
+	return gameboy.speed
+}
+
+// SetSpeed sets how many times faster than normal the emulator runs, from MinSpeed to MaxSpeed
+func (gameboy *Gameboy) SetSpeed(speed float64) {
+	gameboy.speed = math.Max(MinSpeed, math.Min(MaxSpeed, speed))
+	gameboy.audio.reset()
+}
+
+// Turbo returns true if the emulator is running as fast as it can
+func (gameboy *Gameboy) Turbo() bool {
+	return gameboy.turbo
+}
+
+// SetTurbo runs the emulator as fast as it can, ignoring the speed
+func (gameboy *Gameboy) SetTurbo(turbo bool) {
+	gameboy.turbo = turbo
+	gameboy.audio.reset()
+}
+
+// Paused returns true if the emulator is paused
+func (gameboy *Gameboy) Paused() bool {
+	return gameboy.paused
+}
+
+// SetPaused pauses or resumes the emulator
+func (gameboy *Gameboy) SetPaused(paused bool) {
+	gameboy.paused = paused
+	gameboy.audio.reset()
+}
+
+// AdvanceFrame pauses the emulator and has Run run a single frame
+func (gameboy *Gameboy) AdvanceFrame() {
+	gameboy.SetPaused(true)
+	gameboy.advance = true
+}
+
+// stepSpeed moves the speed up or down by one of the preset speeds
+func (gameboy *Gameboy) stepSpeed(up bool) {
+	speed := gameboy.speed
+	if up {
+		for _, s := range speeds {
+			if s > speed {
+				speed = s
+				break
+			}
+		}
+	} else {
+		for i := len(speeds) - 1; i >= 0; i-- {
+			if speeds[i] < speed {
+				speed = speeds[i]
+				break
+			}
+		}
+	}
+
+	gameboy.SetSpeed(speed)
+	fmt.Printf("Speed: %vx\n", gameboy.speed)
+}
+
+// frameTime returns how long a frame should take in real time at the current speed, 0 in turbo mode
+func (gameboy *Gameboy) frameTime() time.Duration {
+	if gameboy.turbo {
+		return 0
+	}
+	return time.Duration(float64(time.Second*cpu.CyclesPerFrame/cpu.ClockSpeed) / gameboy.speed)
+}
+
+func (o *audioOutput) reset() {
+	o.start = time.Now()
+	o.sent = 0
+}
+
+// WriteSamples passes the sound straight through at normal speed. Otherwise it either drops whole
+// blocks of samples that would get ahead of real time, or time-stretches them to the speed, with
+// the speed measured in turbo mode.
+func (o *audioOutput) WriteSamples(samples []int16) {
+	if o.sink == nil {
+		return
+	}
+
+	gameboy := o.gameboy
+	if gameboy.speed == 1 && !gameboy.turbo {
+		o.sink.WriteSamples(samples)
+		return
+	}
+
+	switch gameboy.AudioMode {
+	case AudioSkip:
+		sampleRate := gameboy.APU.SampleRate()
+		ahead := time.Duration(o.sent) * time.Second / time.Duration(sampleRate)
+		if ahead <= time.Since(o.start)+audioLatency {
+			o.sink.WriteSamples(samples)
+			o.sent += len(samples) / 2
+		}
+
+	case AudioStretch:
+		o.stretcher.Speed = gameboy.speed
+		if gameboy.turbo {
+			o.stretcher.Speed = gameboy.measuredSpeed
+		}
+		o.stretcher.WriteSamples(samples)
+	}
+}
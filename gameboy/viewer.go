@@ -0,0 +1,213 @@
+package gameboy
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kevinbrolly/GopherBoy/apu"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// View is a picture of the PPU's memory, for seeing how a game draws its graphics
+type View string
+
+const (
+	// ViewTiles shows the 384 tiles in VRAM, in both banks on CGB
+	ViewTiles View = "tiles"
+	// ViewMap9800 and ViewMap9C00 show the tile maps with the screen and window outlined
+	ViewMap9800 View = "map9800"
+	ViewMap9C00 View = "map9c00"
+	// ViewOAM shows the 40 sprites in OAM
+	ViewOAM View = "oam"
+)
+
+// Views are the views that can be rendered
+var Views = []View{ViewTiles, ViewMap9800, ViewMap9C00, ViewOAM}
+
+// viewerScale is how many times larger than the view a viewer window is
+const viewerScale = 3
+
+// viewerBackground is drawn behind the transparent pixels of the OAM view in a viewer window
+var viewerBackground = color.RGBA{128, 128, 128, 0xFF}
+
+// viewer is a window showing a view, which is redrawn with the screen
+type viewer struct {
+	view     View
+	window   *sdl.Window
+	renderer *sdl.Renderer
+}
+
+// RenderView renders a view of the PPU's memory as it is now
+func (gameboy *Gameboy) RenderView(view View) (*image.RGBA, error) {
+	switch view {
+	case ViewTiles:
+		return gameboy.PPU.TileView(gameboy.APU.Model == apu.CGB), nil
+	case ViewMap9800:
+		return gameboy.PPU.TileMapView(false), nil
+	case ViewMap9C00:
+		return gameboy.PPU.TileMapView(true), nil
+	case ViewOAM:
+		return gameboy.PPU.OAMView(), nil
+	}
+	return nil, fmt.Errorf("view should have been one of %v but was %q", Views, view)
+}
+
+// ExportView writes a view to w as a PNG, scaled by the ScreenshotFormat's scale
+func (gameboy *Gameboy) ExportView(view View, w io.Writer) error {
+	img, err := gameboy.RenderView(view)
+	if err != nil {
+		return err
+	}
+
+	var scaled image.Image = img
+	if gameboy.ScreenshotFormat.Scale > 1 {
+		scaled = scaleImage(img, gameboy.ScreenshotFormat.Scale)
+	}
+	return png.Encode(w, scaled)
+}
+
+// SaveView writes a view to a timestamped file in ScreenshotDir and returns the filename
+func (gameboy *Gameboy) SaveView(view View) (string, error) {
+	dir := gameboy.ScreenshotDir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(dir, fmt.Sprintf("GopherBoy-%s-%s.png", view, time.Now().Format("20060102-150405.000")))
+	return filename, gameboy.saveView(view, filename)
+}
+
+func (gameboy *Gameboy) saveView(view View, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := gameboy.ExportView(view, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// saveViews saves every view, for the export-views hotkey
+func (gameboy *Gameboy) saveViews() {
+	for _, view := range Views {
+		if filename, err := gameboy.SaveView(view); err != nil {
+			fmt.Printf("Error saving %v view: %v\n", view, err)
+		} else {
+			fmt.Printf("Saved %v view: %v\n", view, filename)
+		}
+	}
+}
+
+// ToggleViewer opens a window showing a view, or closes it if it is open
+func (gameboy *Gameboy) ToggleViewer(view View) error {
+	if v, ok := gameboy.viewers[view]; ok {
+		gameboy.closeViewer(v)
+		return nil
+	}
+
+	img, err := gameboy.RenderView(view)
+	if err != nil {
+		return err
+	}
+
+	v := &viewer{view: view}
+	size := img.Bounds().Size().Mul(viewerScale)
+	v.window, err = sdl.CreateWindow(fmt.Sprintf("GopherBoy %v", view), sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
+		int32(size.X), int32(size.Y), sdl.WINDOW_ALLOW_HIGHDPI)
+	if err != nil {
+		return err
+	}
+
+	v.renderer, err = sdl.CreateRenderer(v.window, -1, 0)
+	if err != nil {
+		v.window.Destroy()
+		return err
+	}
+
+	gameboy.viewers[view] = v
+	return v.draw(img)
+}
+
+// toggleViewer toggles a viewer window for the viewer hotkeys
+func (gameboy *Gameboy) toggleViewer(view View) {
+	if err := gameboy.ToggleViewer(view); err != nil {
+		fmt.Printf("Error opening %v view: %v\n", view, err)
+	}
+}
+
+// updateViewers redraws the open viewer windows, it is called whenever the screen is drawn
+func (gameboy *Gameboy) updateViewers() {
+	for _, v := range gameboy.viewers {
+		img, err := gameboy.RenderView(v.view)
+		if err == nil {
+			err = v.draw(img)
+		}
+		if err != nil {
+			fmt.Printf("Error drawing %v view: %v\n", v.view, err)
+			gameboy.closeViewer(v)
+		}
+	}
+}
+
+// closeViewerWindow closes the viewer shown in a window, returning false if the window isn't a viewer
+func (gameboy *Gameboy) closeViewerWindow(windowID uint32) bool {
+	for _, v := range gameboy.viewers {
+		if id, err := v.window.GetID(); err == nil && id == windowID {
+			gameboy.closeViewer(v)
+			return true
+		}
+	}
+	return false
+}
+
+func (gameboy *Gameboy) closeViewer(v *viewer) {
+	v.renderer.Destroy()
+	v.window.Destroy()
+	delete(gameboy.viewers, v.view)
+}
+
+// closeViewers closes every viewer window
+func (gameboy *Gameboy) closeViewers() {
+	for _, v := range gameboy.viewers {
+		gameboy.closeViewer(v)
+	}
+}
+
+// draw draws a view to the window, scaled to fill it
+func (v *viewer) draw(img *image.RGBA) error {
+	bounds := img.Bounds()
+	surface, err := sdl.CreateRGBSurface(0, int32(bounds.Dx()), int32(bounds.Dy()), 32, 0, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	defer surface.Free()
+
+	draw.Draw(surface, surface.Bounds(), &image.Uniform{viewerBackground}, image.Point{}, draw.Src)
+	draw.Draw(surface, surface.Bounds(), img, bounds.Min, draw.Over)
+
+	texture, err := v.renderer.CreateTextureFromSurface(surface)
+	if err != nil {
+		return err
+	}
+	defer texture.Destroy()
+
+	if err := v.renderer.Copy(texture, nil, nil); err != nil {
+		return err
+	}
+	v.renderer.Present()
+	return nil
+}
@@ -0,0 +1,120 @@
+package gbs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	// HeaderSize is the size of the GBS header, the music data follows it
+	HeaderSize = 0x70
+
+	magic = "GBS"
+)
+
+// File is a Game Boy Sound System rip, the music code and data of a game with a
+// header giving the routines that start a song and play it a frame at a time
+type File struct {
+	Version   byte
+	SongCount byte
+	// FirstSong is the song to play first, numbered from 1
+	FirstSong byte
+
+	// LoadAddress is where the data is loaded in the ROM address space
+	LoadAddress uint16
+	// InitAddress is called with the song number, counting from 0, in A to start a song
+	InitAddress uint16
+	// PlayAddress is called at the play rate to play the song
+	PlayAddress  uint16
+	StackPointer uint16
+
+	// TimerModulo and TimerControl are written to TMA and TAC, if the timer is enabled
+	// in TimerControl the play routine is called on the timer interrupt instead of VBlank
+	TimerModulo  byte
+	TimerControl byte
+
+	Title     string
+	Author    string
+	Copyright string
+
+	Data []byte
+}
+
+// Load reads a GBS file
+func Load(filename string) (*File, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses the header and data of a GBS file
+//
+// Offset Size Description
+// ------ ---- -----------
+// 00     3    Identifier string ("GBS")
+// 03     1    Version (1)
+// 04     1    Number of songs (1-255)
+// 05     1    First song (usually 1)
+// 06     2    Load address ($400-$7fff)
+// 08     2    Init address ($400-$7fff)
+// 0a     2    Play address ($400-$7fff)
+// 0c     2    Stack pointer
+// 0e     1    Timer modulo
+// 0f     1    Timer control
+// 10     32   Title string
+// 30     32   Author string
+// 50     32   Copyright string
+// 70     nnnn Code and data, loaded at the load address
+func Parse(data []byte) (*File, error) {
+	if len(data) < HeaderSize || string(data[:3]) != magic {
+		return nil, fmt.Errorf("not a GBS file")
+	}
+
+	f := &File{
+		Version:      data[0x03],
+		SongCount:    data[0x04],
+		FirstSong:    data[0x05],
+		LoadAddress:  binary.LittleEndian.Uint16(data[0x06:]),
+		InitAddress:  binary.LittleEndian.Uint16(data[0x08:]),
+		PlayAddress:  binary.LittleEndian.Uint16(data[0x0A:]),
+		StackPointer: binary.LittleEndian.Uint16(data[0x0C:]),
+		TimerModulo:  data[0x0E],
+		TimerControl: data[0x0F],
+		Title:        headerString(data[0x10:0x30]),
+		Author:       headerString(data[0x30:0x50]),
+		Copyright:    headerString(data[0x50:0x70]),
+		Data:         data[HeaderSize:],
+	}
+
+	if f.Version != 1 {
+		return nil, fmt.Errorf("unsupported GBS version %v", f.Version)
+	}
+	if f.SongCount == 0 {
+		return nil, fmt.Errorf("GBS file has no songs")
+	}
+	if f.FirstSong == 0 || f.FirstSong > f.SongCount {
+		f.FirstSong = 1
+	}
+	if f.LoadAddress < 0x400 || f.LoadAddress > 0x7FFF {
+		return nil, fmt.Errorf("invalid GBS load address %#04x", f.LoadAddress)
+	}
+
+	return f, nil
+}
+
+// UsesTimer returns true if the play routine is called on the timer interrupt instead of VBlank
+func (f *File) UsesTimer() bool {
+	return f.TimerControl&0x04 != 0
+}
+
+// headerString returns a zero padded string from the header
+func headerString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,199 @@
+package gbs
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kevinbrolly/GopherBoy/cpu"
+)
+
+const (
+	loadAddress = 0x400
+	initAddress = 0x400
+	playAddress = 0x410
+)
+
+// newTestGBS returns a GBS file whose init routine stores the song number at 0xC000
+// and whose play routine counts the number of times it is called at 0xC001
+func newTestGBS(timerModulo, timerControl byte) []byte {
+	header := make([]byte, HeaderSize)
+	copy(header, "GBS")
+	header[0x03] = 1
+	header[0x04] = 3
+	header[0x05] = 2
+	binary.LittleEndian.PutUint16(header[0x06:], loadAddress)
+	binary.LittleEndian.PutUint16(header[0x08:], initAddress)
+	binary.LittleEndian.PutUint16(header[0x0A:], playAddress)
+	binary.LittleEndian.PutUint16(header[0x0C:], 0xDFFF)
+	header[0x0E] = timerModulo
+	header[0x0F] = timerControl
+	copy(header[0x10:], "Title")
+	copy(header[0x30:], "Author")
+	copy(header[0x50:], "Copyright")
+
+	code := make([]byte, 2*bankSize-loadAddress+1)
+	// init: LD (0xC000),A; RET
+	copy(code[initAddress-loadAddress:], []byte{0xEA, 0x00, 0xC0, 0xC9})
+	// play: LD HL,0xC001; INC (HL); RET
+	copy(code[playAddress-loadAddress:], []byte{0x21, 0x01, 0xC0, 0x34, 0xC9})
+	// The first byte of bank 2
+	code[2*bankSize-loadAddress] = 0x42
+
+	return append(header, code...)
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse(newTestGBS(0xAB, 0x04))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"SongCount", f.SongCount, byte(3)},
+		{"FirstSong", f.FirstSong, byte(2)},
+		{"LoadAddress", f.LoadAddress, uint16(loadAddress)},
+		{"InitAddress", f.InitAddress, uint16(initAddress)},
+		{"PlayAddress", f.PlayAddress, uint16(playAddress)},
+		{"StackPointer", f.StackPointer, uint16(0xDFFF)},
+		{"TimerModulo", f.TimerModulo, byte(0xAB)},
+		{"UsesTimer", f.UsesTimer(), true},
+		{"Title", f.Title, "Title"},
+		{"Author", f.Author, "Author"},
+		{"Copyright", f.Copyright, "Copyright"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.value != c.expected {
+				t.Errorf("%v should have been %v but was %v", c.name, c.expected, c.value)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(data []byte)
+	}{
+		{"Magic", func(data []byte) { data[0] = 'X' }},
+		{"Version", func(data []byte) { data[0x03] = 2 }},
+		{"No songs", func(data []byte) { data[0x04] = 0 }},
+		{"Load address", func(data []byte) { binary.LittleEndian.PutUint16(data[0x06:], 0x100) }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := newTestGBS(0, 0)
+			c.modify(data)
+			if _, err := Parse(data); err == nil {
+				t.Errorf("parsing should have failed")
+			}
+		})
+	}
+}
+
+func TestPlayRate(t *testing.T) {
+	cases := []struct {
+		name         string
+		timerModulo  byte
+		timerControl byte
+		expected     byte
+	}{
+		// VBlank is called about 59.7 times a second
+		{"VBlank", 0, 0, 59},
+		// 4096 Hz / (256 - 0) = 16 Hz
+		{"Timer 16 Hz", 0x00, 0x04, 16},
+		// 16384 Hz / (256 - 0) = 64 Hz
+		{"Timer 64 Hz", 0x00, 0x07, 64},
+		// 4096 Hz / (256 - 0xC0) = 64 Hz
+		{"Timer modulo", 0xC0, 0x04, 64},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f, err := Parse(newTestGBS(c.timerModulo, c.timerControl))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := NewPlayer(f)
+			if err := p.Start(3); err != nil {
+				t.Fatal(err)
+			}
+
+			// 1 second
+			for i := 0; i < cpu.ClockSpeed/cpu.CyclesPerFrame; i++ {
+				p.RunFrame()
+			}
+
+			if song := p.MMU.ReadByte(0xC000); song != 2 {
+				t.Errorf("init should have been called with song 2 but was called with %v", song)
+			}
+			if calls := p.MMU.ReadByte(0xC001); calls < c.expected-1 || calls > c.expected+1 {
+				t.Errorf("play should have been called %v times but was called %v times", c.expected, calls)
+			}
+		})
+	}
+}
+
+func TestBankSwitching(t *testing.T) {
+	f, err := Parse(newTestGBS(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlayer(f)
+	p.Start(1)
+
+	cases := []struct {
+		bank     byte
+		expected byte
+	}{
+		{2, 0x42},
+		{1, 0x00},
+		// Banks past the end of the data read as 0xFF
+		{5, 0xFF},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			p.MMU.WriteByte(0x2000, c.bank)
+			if value := p.MMU.ReadByte(0x4000); value != c.expected {
+				t.Errorf("0x4000 should have been %#x but was %#x", c.expected, value)
+			}
+		})
+	}
+}
+
+func TestRenderWAV(t *testing.T) {
+	f, err := Parse(newTestGBS(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlayer(f)
+	p.Length = 500 * time.Millisecond
+	p.Fade = 250 * time.Millisecond
+
+	filename := filepath.Join(t.TempDir(), "song.wav")
+	if err := p.RenderWAV(filename, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 0.75 seconds of 16 bit stereo after the 44 byte header
+	expected := int64(44 + p.SampleRate*3/4*4)
+	if info.Size() != expected {
+		t.Errorf("WAV file should have been %v bytes but was %v", expected, info.Size())
+	}
+}
@@ -0,0 +1,304 @@
+package gbs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kevinbrolly/GopherBoy/apu"
+	"github.com/kevinbrolly/GopherBoy/cpu"
+	"github.com/kevinbrolly/GopherBoy/mmu"
+	"github.com/kevinbrolly/GopherBoy/record"
+	"github.com/kevinbrolly/GopherBoy/utils"
+)
+
+const (
+	// returnAddress is pushed as the return address of the init and play routines, the CPU
+	// is halted when it reaches it. It is below the load address so no code is ever there.
+	returnAddress = 0x0070
+
+	bankSize = 0x4000
+)
+
+// Player plays the songs in a GBS file on a minimal Game Boy: the CPU, timer and APU
+// with ROM, RAM and HRAM. The music data is mapped into ROM with the first bank fixed at
+// 0x0000-0x3FFF and a switchable bank at 0x4000-0x7FFF, selected by writing to 0x2000-0x3FFF.
+type Player struct {
+	File *File
+
+	MMU *mmu.MMU
+	CPU *cpu.CPU
+	APU *apu.APU
+
+	// Length is how long each song plays for before it fades out over Fade,
+	// a Length of 0 plays the song forever
+	Length time.Duration
+	Fade   time.Duration
+
+	// SampleRate is the sample rate of the audio sent to the sinks
+	SampleRate int
+
+	song int
+	rom  []byte
+	bank int
+	ram  [0x4000]byte // 0xA000 -> 0xDFFF
+	hram [127]byte    // 0xFF80 -> 0xFFFE
+
+	frameCycles int
+	samples     int
+	buffer      []int16
+	sinks       []apu.Sink
+}
+
+func NewPlayer(file *File) *Player {
+	p := &Player{
+		File:       file,
+		SampleRate: apu.Frequency,
+	}
+
+	// ROM is rounded up to whole banks, with at least the fixed bank and one switchable bank
+	size := int(file.LoadAddress) + len(file.Data)
+	size = (size + bankSize - 1) / bankSize * bankSize
+	if size < 2*bankSize {
+		size = 2 * bankSize
+	}
+	p.rom = make([]byte, size)
+	copy(p.rom[file.LoadAddress:], file.Data)
+
+	// The RST vectors jump to the same offset from the load address,
+	// the interrupt vectors return straight away
+	for vector := 0x00; vector <= 0x38; vector += 8 {
+		target := file.LoadAddress + uint16(vector)
+		p.rom[vector] = 0xC3 // JP a16
+		p.rom[vector+1] = byte(target)
+		p.rom[vector+2] = byte(target >> 8)
+	}
+	for vector := 0x40; vector <= 0x60; vector += 8 {
+		p.rom[vector] = 0xD9 // RETI
+	}
+
+	return p
+}
+
+// Song returns the song being played, numbered from 1
+func (p *Player) Song() int {
+	return p.song
+}
+
+// Start resets the hardware and starts playing song, numbered from 1
+func (p *Player) Start(song int) error {
+	if song < 1 || song > int(p.File.SongCount) {
+		return fmt.Errorf("song should have been from 1 to %v but was %v", p.File.SongCount, song)
+	}
+
+	p.MMU = mmu.NewMMU()
+	p.CPU = cpu.NewCPU(p.MMU)
+	p.APU = apu.NewAPU(p.MMU)
+	p.APU.Clock = p.CPU
+	p.APU.SetSampleRate(p.SampleRate)
+	p.APU.AddSink(p)
+
+	// ROM
+	p.MMU.MapMemoryRange(p, 0x0000, 0x7FFF)
+	// External and working RAM
+	p.MMU.MapMemoryRange(p, 0xA000, 0xDFFF)
+	// HRAM
+	p.MMU.MapMemoryRange(p, 0xFF80, 0xFFFE)
+
+	p.song = song
+	p.bank = 1
+	p.ram = [len(p.ram)]byte{}
+	p.hram = [len(p.hram)]byte{}
+	p.frameCycles = 0
+	p.samples = 0
+
+	// Sound is turned on at full volume on both outputs
+	p.MMU.WriteByte(apu.NR52, 0x80)
+	p.MMU.WriteByte(apu.NR51, 0xFF)
+	p.MMU.WriteByte(apu.NR50, 0x77)
+
+	// TIMA starts at the modulo so the first play call comes a whole timer period after init
+	p.MMU.WriteByte(cpu.TIMA, p.File.TimerModulo)
+	p.MMU.WriteByte(cpu.TMA, p.File.TimerModulo)
+	p.MMU.WriteByte(cpu.TAC, p.File.TimerControl)
+
+	// Interrupts are never serviced, the timer interrupt is only
+	// enabled so the halted CPU wakes up to call the play routine
+	p.CPU.IME = false
+	p.CPU.IF = 0
+	p.CPU.IE = 0
+	if p.File.UsesTimer() {
+		p.CPU.IE = utils.SetBit(0, cpu.TIMER_OVERFLOW_INTERRUPT)
+	}
+
+	p.CPU.SP = p.File.StackPointer
+	p.CPU.Registers.A = byte(song - 1)
+	p.call(p.File.InitAddress)
+
+	return nil
+}
+
+// RunFrame runs the player for a frame's worth of cycles and sends the audio to the sinks
+func (p *Player) RunFrame() {
+	for cycles := 0; cycles < cpu.CyclesPerFrame; {
+		cycles += p.step()
+	}
+	p.APU.Flush()
+}
+
+// Ended returns true once the song has played for Length and faded out
+func (p *Player) Ended() bool {
+	return p.Length > 0 && p.samples >= p.durationSamples(p.Length+p.Fade)
+}
+
+// RenderWAV plays song for Length and its fade out as fast as possible, recording it to filename
+func (p *Player) RenderWAV(filename string, song int) error {
+	if p.Length <= 0 {
+		return fmt.Errorf("a length is needed to render a song")
+	}
+
+	recorder, err := record.CreateAudio(filename, p.SampleRate, false)
+	if err != nil {
+		return err
+	}
+	p.AddSink(recorder)
+	defer p.RemoveSink(recorder)
+
+	if err := p.Start(song); err != nil {
+		recorder.Close()
+		return err
+	}
+	for !p.Ended() {
+		p.RunFrame()
+	}
+
+	return recorder.Close()
+}
+
+// AddSink adds a sink that receives the songs' samples
+func (p *Player) AddSink(sink apu.Sink) {
+	p.sinks = append(p.sinks, sink)
+}
+
+// RemoveSink stops sending samples to sink
+func (p *Player) RemoveSink(sink apu.Sink) {
+	for i, existing := range p.sinks {
+		if existing == sink {
+			p.sinks = append(p.sinks[:i], p.sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// WriteSamples fades out the APU's samples and sends them to the sinks,
+// anything after the end of the song is dropped
+func (p *Player) WriteSamples(samples []int16) {
+	fadeStart := p.durationSamples(p.Length)
+	fadeLength := p.durationSamples(p.Fade)
+
+	p.buffer = p.buffer[:0]
+	for i := 0; i+1 < len(samples); i += 2 {
+		if p.Ended() {
+			break
+		}
+
+		gain := 1.0
+		if p.Length > 0 && p.samples >= fadeStart {
+			gain = 1 - float64(p.samples-fadeStart)/float64(fadeLength)
+		}
+		p.buffer = append(p.buffer, int16(float64(samples[i])*gain), int16(float64(samples[i+1])*gain))
+		p.samples++
+	}
+
+	if len(p.buffer) == 0 {
+		return
+	}
+	for _, sink := range p.sinks {
+		sink.WriteSamples(p.buffer)
+	}
+}
+
+// durationSamples returns the number of samples played in d
+func (p *Player) durationSamples(d time.Duration) int {
+	return int(d.Seconds() * float64(p.SampleRate))
+}
+
+// step runs a single CPU instruction and the APU for the same number of cycles,
+// calling the play routine when it is due and the CPU is waiting for it
+func (p *Player) step() int {
+	if p.CPU.PC == returnAddress {
+		if p.playDue() {
+			p.call(p.File.PlayAddress)
+		} else {
+			p.CPU.Halt = true
+		}
+	}
+
+	cycles := p.CPU.Step()
+	p.APU.Tick(cycles)
+	p.frameCycles += cycles
+
+	return cycles
+}
+
+// playDue returns true if the play routine should be called, either on
+// the timer interrupt or at the start of VBlank
+func (p *Player) playDue() bool {
+	if p.File.UsesTimer() {
+		if !utils.IsBitSet(p.CPU.IF, cpu.TIMER_OVERFLOW_INTERRUPT) {
+			return false
+		}
+		p.CPU.IF = utils.ClearBit(p.CPU.IF, cpu.TIMER_OVERFLOW_INTERRUPT)
+		return true
+	}
+
+	if p.frameCycles < cpu.CyclesPerFrame {
+		return false
+	}
+	p.frameCycles -= cpu.CyclesPerFrame
+	return true
+}
+
+// call pushes returnAddress and jumps to addr
+func (p *Player) call(addr uint16) {
+	hb, lb := utils.SplitBytes(returnAddress)
+	p.CPU.SP--
+	p.MMU.WriteByte(p.CPU.SP, hb)
+	p.CPU.SP--
+	p.MMU.WriteByte(p.CPU.SP, lb)
+
+	p.CPU.PC = addr
+	p.CPU.Halt = false
+}
+
+func (p *Player) ReadByte(addr uint16) byte {
+	switch {
+	case addr <= 0x3FFF:
+		return p.rom[addr]
+	case addr >= 0x4000 && addr <= 0x7FFF:
+		offset := p.bank*bankSize + int(addr-0x4000)
+		if offset >= len(p.rom) {
+			return 0xFF
+		}
+		return p.rom[offset]
+	case addr >= 0xA000 && addr <= 0xDFFF:
+		return p.ram[addr-0xA000]
+	case addr >= 0xFF80 && addr <= 0xFFFE:
+		return p.hram[addr-0xFF80]
+	}
+	return 0
+}
+
+func (p *Player) WriteByte(addr uint16, value byte) {
+	switch {
+	case addr >= 0x2000 && addr <= 0x3FFF:
+		// Bank 0 can't be selected in the switchable bank, 0 selects bank 1
+		p.bank = int(value)
+		if p.bank == 0 {
+			p.bank = 1
+		}
+	case addr >= 0xA000 && addr <= 0xDFFF:
+		p.ram[addr-0xA000] = value
+	case addr >= 0xFF80 && addr <= 0xFFFE:
+		p.hram[addr-0xFF80] = value
+	}
+}
@@ -0,0 +1,120 @@
+package midi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// Event is a MIDI or meta event at a time in ticks from the start of the track
+type Event struct {
+	Tick uint32
+	Data []byte
+}
+
+// Track is a track of a Standard MIDI File
+type Track struct {
+	Events []Event
+}
+
+// Add adds an event, events can be added in any order as they are sorted when written
+func (t *Track) Add(tick uint32, data ...byte) {
+	t.Events = append(t.Events, Event{tick, data})
+}
+
+// NoteOn starts a note on a MIDI channel from 0 to 15
+func (t *Track) NoteOn(tick uint32, channel, note, velocity byte) {
+	t.Add(tick, 0x90|channel, note, velocity)
+}
+
+// NoteOff ends a note
+func (t *Track) NoteOff(tick uint32, channel, note byte) {
+	t.Add(tick, 0x80|channel, note, 0)
+}
+
+// ControlChange sets a controller's value
+func (t *Track) ControlChange(tick uint32, channel, controller, value byte) {
+	t.Add(tick, 0xB0|channel, controller, value)
+}
+
+// ProgramChange selects the instrument of a channel
+func (t *Track) ProgramChange(tick uint32, channel, program byte) {
+	t.Add(tick, 0xC0|channel, program)
+}
+
+// PitchBend bends the pitch of a channel, value is from 0 to 16383 with 8192 as no bend
+func (t *Track) PitchBend(tick uint32, channel byte, value uint16) {
+	t.Add(tick, 0xE0|channel, byte(value&0x7F), byte(value>>7&0x7F))
+}
+
+// Meta adds a meta event, such as a track name or tempo
+func (t *Track) Meta(tick uint32, metaType byte, data []byte) {
+	event := append([]byte{0xFF, metaType}, varLen(uint32(len(data)))...)
+	t.Add(tick, append(event, data...)...)
+}
+
+// Meta event types
+const (
+	MetaTrackName     = 0x03
+	MetaEndOfTrack    = 0x2F
+	MetaTempo         = 0x51
+	MetaTimeSignature = 0x58
+)
+
+// File is a format 1 Standard MIDI File, where the tracks play at the same time
+type File struct {
+	// Division is the number of ticks per quarter note
+	Division uint16
+	Tracks   []*Track
+}
+
+// WriteTo writes the file in the Standard MIDI File format
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString("MThd")
+	binary.Write(&buf, binary.BigEndian, uint32(6))
+	binary.Write(&buf, binary.BigEndian, uint16(1))
+	binary.Write(&buf, binary.BigEndian, uint16(len(f.Tracks)))
+	binary.Write(&buf, binary.BigEndian, f.Division)
+
+	for _, track := range f.Tracks {
+		data := track.encode()
+		buf.WriteString("MTrk")
+		binary.Write(&buf, binary.BigEndian, uint32(len(data)))
+		buf.Write(data)
+	}
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// encode returns the track's events sorted by time, each preceded by the number of ticks
+// since the previous event, and ending with an end of track event
+func (t *Track) encode() []byte {
+	events := append([]Event{}, t.Events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Tick < events[j].Tick
+	})
+
+	var data []byte
+	var tick uint32
+	for _, event := range events {
+		data = append(data, varLen(event.Tick-tick)...)
+		data = append(data, event.Data...)
+		tick = event.Tick
+	}
+
+	return append(data, 0x00, 0xFF, MetaEndOfTrack, 0x00)
+}
+
+// varLen encodes value as a variable length quantity, 7 bits
+// per byte with the top bit set on all but the last byte
+func varLen(value uint32) []byte {
+	data := []byte{byte(value & 0x7F)}
+	for value >>= 7; value > 0; value >>= 7 {
+		data = append([]byte{byte(value&0x7F) | 0x80}, data...)
+	}
+	return data
+}
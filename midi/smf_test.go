@@ -0,0 +1,55 @@
+package midi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVarLen(t *testing.T) {
+	cases := []struct {
+		value    uint32
+		expected []byte
+	}{
+		{0x00, []byte{0x00}},
+		{0x7F, []byte{0x7F}},
+		{0x80, []byte{0x81, 0x00}},
+		{0x2000, []byte{0xC0, 0x00}},
+		{0x3FFF, []byte{0xFF, 0x7F}},
+		{0x0FFFFFFF, []byte{0xFF, 0xFF, 0xFF, 0x7F}},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			if result := varLen(c.value); !bytes.Equal(result, c.expected) {
+				t.Errorf("%#x should have been encoded as % x but was % x", c.value, c.expected, result)
+			}
+		})
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	track := &Track{}
+	// Added out of order
+	track.NoteOff(200, 1, 60)
+	track.NoteOn(0, 1, 60, 100)
+	track.PitchBend(100, 1, 0x2001)
+
+	f := &File{Division: 480, Tracks: []*Track{track}}
+
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{
+		'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 1, 0, 1, 0x01, 0xE0,
+		'M', 'T', 'r', 'k', 0, 0, 0, 16,
+		0x00, 0x91, 60, 100,
+		0x64, 0xE1, 0x01, 0x40,
+		0x64, 0x81, 60, 0,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("file should have been % x but was % x", expected, buf.Bytes())
+	}
+}
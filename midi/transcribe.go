@@ -0,0 +1,277 @@
+package midi
+
+import (
+	"math"
+	"os"
+
+	"github.com/kevinbrolly/GopherBoy/apu"
+	"github.com/kevinbrolly/GopherBoy/cpu"
+)
+
+const (
+	// Division is the number of ticks per quarter note, at the
+	// default tempo of 120 BPM there are 960 ticks per second
+	Division       = 480
+	ticksPerSecond = 960
+	tempo          = 500000 // microseconds per quarter note
+
+	// bendRange is the pitch bend range in semitones, set on each channel with RPN 0.
+	// Pitch changes within it are played as pitch bends, larger changes start a new note.
+	bendRange = 12
+)
+
+// General MIDI controllers
+const (
+	controllerDataEntry    = 6
+	controllerExpression   = 11
+	controllerDataEntryLSB = 38
+	controllerRPNLSB       = 100
+	controllerRPNMSB       = 101
+)
+
+// General MIDI programs and drums
+const (
+	programSquareLead = 80 // Lead 1 (square)
+	programSynthBass  = 38 // Synth Bass 1, the wave channel is usually the bass line
+
+	drumBass        = 36
+	drumSnare       = 38
+	drumClosedHiHat = 42
+	drumOpenHiHat   = 46
+
+	drumChannel = 9
+)
+
+var trackNames = [4]string{"Square 1", "Square 2", "Wave", "Noise"}
+
+// voice follows the note being played by an APU channel on a MIDI channel
+type voice struct {
+	track   *Track
+	channel byte
+	drum    bool
+
+	playing bool
+	note    byte
+	// volume is the volume the note started at, later changes
+	// to the volume are sent as changes to the expression
+	volume     byte
+	expression byte
+	bend       uint16
+}
+
+// Recorder transcribes the music played by the APU to a Standard MIDI File, with one track
+// for each channel. Notes start when a channel is triggered or becomes audible, with a velocity
+// from its volume, and end when it is stopped or silent. Changes in pitch, such as sweeps and
+// vibrato, are pitch bends and changes in volume are changes to the expression. The noise
+// channel is played on the drum channel with a drum picked from the noise it makes.
+type Recorder struct {
+	apu   *apu.APU
+	file  *os.File
+	start uint64
+
+	File   *File
+	voices [4]*voice
+}
+
+// Create starts transcribing the APU's music, which is written to filename by Close
+func Create(filename string, a *apu.APU) (*Recorder, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewRecorder(a)
+	r.file = f
+	return r, nil
+}
+
+// NewRecorder starts transcribing the APU's music to File
+func NewRecorder(a *apu.APU) *Recorder {
+	r := &Recorder{
+		apu:   a,
+		start: a.Cycles(),
+		File:  &File{Division: Division},
+	}
+
+	conductor := &Track{}
+	conductor.Meta(0, MetaTrackName, []byte("GopherBoy"))
+	conductor.Meta(0, MetaTempo, []byte{tempo >> 16, tempo >> 8 & 0xFF, tempo & 0xFF})
+	conductor.Meta(0, MetaTimeSignature, []byte{4, 2, 24, 8})
+	r.File.Tracks = append(r.File.Tracks, conductor)
+
+	programs := [3]byte{programSquareLead, programSquareLead, programSynthBass}
+	for i, name := range trackNames {
+		v := &voice{
+			track:      &Track{},
+			channel:    byte(i),
+			drum:       i == 3,
+			expression: 127,
+			bend:       8192,
+		}
+		v.track.Meta(0, MetaTrackName, []byte(name))
+
+		if v.drum {
+			v.channel = drumChannel
+		} else {
+			v.track.ProgramChange(0, v.channel, programs[i])
+			v.track.ControlChange(0, v.channel, controllerRPNMSB, 0)
+			v.track.ControlChange(0, v.channel, controllerRPNLSB, 0)
+			v.track.ControlChange(0, v.channel, controllerDataEntry, bendRange)
+			v.track.ControlChange(0, v.channel, controllerDataEntryLSB, 0)
+		}
+
+		r.voices[i] = v
+		r.File.Tracks = append(r.File.Tracks, v.track)
+	}
+
+	// Start any notes that are already playing
+	r.update(r.start, -1)
+
+	a.AddWriteListener(r)
+	return r
+}
+
+// RegisterWritten follows the channels' state after every write, so triggers and pitch changes
+// made by writing the registers are heard at the time they are written
+func (r *Recorder) RegisterWritten(cycle uint64, addr uint16, value byte) {
+	triggered := -1
+	if value&0x80 != 0 {
+		switch addr {
+		case apu.NR14:
+			triggered = 0
+		case apu.NR24:
+			triggered = 1
+		case apu.NR34:
+			triggered = 2
+		case apu.NR44:
+			triggered = 3
+		}
+	}
+
+	r.update(cycle, triggered)
+}
+
+// Update follows changes in the channels' state made by the APU itself, such as
+// sweeps, envelopes and length counters, it should be called at least once a frame
+func (r *Recorder) Update() {
+	r.update(r.apu.Cycles(), -1)
+}
+
+// Close stops transcribing, ending any notes that are playing, and writes the file
+func (r *Recorder) Close() error {
+	r.apu.RemoveWriteListener(r)
+
+	tick := r.tick(r.apu.Cycles())
+	for _, v := range r.voices {
+		v.stop(tick)
+	}
+
+	if r.file == nil {
+		return nil
+	}
+
+	_, err := r.File.WriteTo(r.file)
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (r *Recorder) tick(cycle uint64) uint32 {
+	return uint32((cycle - r.start) * ticksPerSecond / cpu.ClockSpeed)
+}
+
+func (r *Recorder) update(cycle uint64, triggered int) {
+	tick := r.tick(cycle)
+	for i, state := range r.apu.Channels() {
+		r.voices[i].update(tick, state, i == triggered)
+	}
+}
+
+func (v *voice) update(tick uint32, state apu.ChannelState, triggered bool) {
+	if !state.Enabled || state.Volume == 0 {
+		v.stop(tick)
+		return
+	}
+
+	if v.drum {
+		note := drumNote(state)
+		if !v.playing || triggered || note != v.note {
+			v.stop(tick)
+			v.start(tick, note, state.Volume)
+		}
+		return
+	}
+
+	pitch := pitch(state.Frequency)
+	if !v.playing || triggered || math.Abs(pitch-float64(v.note)) > bendRange {
+		v.stop(tick)
+		v.start(tick, byte(math.Round(pitch)), state.Volume)
+	}
+
+	v.bendTo(tick, pitch-float64(v.note))
+	v.setExpression(tick, state.Volume)
+}
+
+func (v *voice) start(tick uint32, note byte, volume byte) {
+	if v.expression != 127 && !v.drum {
+		v.track.ControlChange(tick, v.channel, controllerExpression, 127)
+		v.expression = 127
+	}
+
+	v.playing = true
+	v.note = note
+	v.volume = volume
+	v.track.NoteOn(tick, v.channel, note, velocity(volume))
+}
+
+func (v *voice) stop(tick uint32) {
+	if !v.playing {
+		return
+	}
+	v.playing = false
+	v.track.NoteOff(tick, v.channel, v.note)
+}
+
+// bendTo bends the pitch of the note by semitones
+func (v *voice) bendTo(tick uint32, semitones float64) {
+	bend := math.Round(8192 + semitones/bendRange*8192)
+	value := uint16(math.Max(0, math.Min(16383, bend)))
+	if value != v.bend {
+		v.track.PitchBend(tick, v.channel, value)
+		v.bend = value
+	}
+}
+
+// setExpression scales the note's velocity by the change in volume since it started
+func (v *voice) setExpression(tick uint32, volume byte) {
+	expression := byte(math.Min(127, math.Round(127*float64(volume)/float64(v.volume))))
+	if expression != v.expression {
+		v.track.ControlChange(tick, v.channel, controllerExpression, expression)
+		v.expression = expression
+	}
+}
+
+// pitch returns the MIDI note number of frequency, where A4 at 440 Hz is 69
+func pitch(frequency float64) float64 {
+	return math.Max(0, math.Min(127, 69+12*math.Log2(frequency/440)))
+}
+
+// velocity converts a volume from 0 to 15 to a velocity from 1 to 127
+func velocity(volume byte) byte {
+	return byte(math.Max(1, math.Round(float64(volume)*127/15)))
+}
+
+// drumNote picks a drum for the noise channel. Short noise sounds metallic like a closed hi-hat,
+// otherwise the faster the LFSR is clocked the brighter the noise, from a bass drum up to an open hi-hat.
+func drumNote(state apu.ChannelState) byte {
+	switch {
+	case state.ShortNoise:
+		return drumClosedHiHat
+	case state.Frequency >= 131072:
+		return drumOpenHiHat
+	case state.Frequency >= 16384:
+		return drumSnare
+	}
+	return drumBass
+}
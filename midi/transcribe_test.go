@@ -0,0 +1,86 @@
+package midi
+
+import (
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/apu"
+	"github.com/kevinbrolly/GopherBoy/cpu"
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+// noteEvents returns the note on, note off and pitch bend events in a track
+func noteEvents(track *Track) [][]byte {
+	var events [][]byte
+	for _, event := range track.Events {
+		switch event.Data[0] & 0xF0 {
+		case 0x80, 0x90, 0xE0:
+			events = append(events, event.Data)
+		}
+	}
+	return events
+}
+
+// bend returns the pitch bend from note to the square channel's frequency register x
+func bend(x int, note byte) []byte {
+	track := &Track{}
+	v := &voice{track: track, channel: 1, note: note, bend: 8192}
+	v.bendTo(0, pitch(131072/float64(2048-x))-float64(note))
+	return track.Events[0].Data
+}
+
+func TestTranscribe(t *testing.T) {
+	a := apu.NewAPU(mmu.NewMMU())
+	a.WriteByte(apu.NR52, 0x80)
+
+	r := NewRecorder(a)
+
+	// A4, 131072/(2048-1750) = 440 Hz
+	a.WriteByte(apu.NR22, 0xF0)
+	a.WriteByte(apu.NR23, 1750&0xFF)
+	a.WriteByte(apu.NR24, 0x80|1750>>8)
+	a.Tick(cpu.ClockSpeed / 10)
+
+	// A small change in pitch is a pitch bend
+	a.WriteByte(apu.NR23, 1760&0xFF)
+	a.Tick(cpu.ClockSpeed / 10)
+
+	// Retriggering starts a new note, with the velocity of the new volume
+	a.WriteByte(apu.NR22, 0x80)
+	a.WriteByte(apu.NR24, 0x80|1760>>8)
+	a.Tick(cpu.ClockSpeed / 10)
+
+	// Turning off the DAC ends the note
+	a.WriteByte(apu.NR22, 0x00)
+
+	// A short noise is a closed hi-hat
+	a.WriteByte(apu.NR42, 0xF0)
+	a.WriteByte(apu.NR43, 0x08)
+	a.WriteByte(apu.NR44, 0x80)
+
+	r.Close()
+
+	square2 := noteEvents(r.File.Tracks[2])
+	expected := [][]byte{
+		{0x91, 69, 127},
+		bend(1750, 69),
+		bend(1760, 69),
+		{0x81, 69, 0},
+		{0x91, 70, velocity(8)},
+		bend(1760, 70),
+		{0x81, 70, 0},
+	}
+
+	if len(square2) != len(expected) {
+		t.Fatalf("square 2 should have had %v events but had %v: % x", len(expected), len(square2), square2)
+	}
+	for i := range expected {
+		if string(square2[i]) != string(expected[i]) {
+			t.Errorf("event %v should have been % x but was % x", i, expected[i], square2[i])
+		}
+	}
+
+	noise := noteEvents(r.File.Tracks[4])
+	if len(noise) != 2 || noise[0][0] != 0x99 || noise[0][1] != drumClosedHiHat || noise[1][0] != 0x89 {
+		t.Errorf("noise should have played a closed hi-hat but played % x", noise)
+	}
+}
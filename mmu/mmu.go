@@ -9,8 +9,18 @@ type Memory interface {
 	WriteByte(addr uint16, b byte)
 }
 
+// BusMaster is implemented by anything that can take the bus away
+// from the CPU, such as OAM DMA.
+type BusMaster interface {
+	// BusConflict returns the value the CPU sees when accessing addr
+	// while the bus master holds the bus, ok is false if the access
+	// is unaffected.
+	BusConflict(addr uint16) (value byte, ok bool)
+}
+
 type MMU struct {
 	locations map[uint16]Memory
+	busMaster BusMaster
 }
 
 func NewMMU() *MMU {
@@ -31,6 +41,15 @@ func (m *MMU) MapMemoryRange(memory Memory, startAddr uint16, endAddr uint16) {
 	}
 }
 
+// MemoryAt returns the memory mapped at addr, or nil if nothing is mapped
+func (m *MMU) MemoryAt(addr uint16) Memory {
+	return m.locations[addr]
+}
+
+func (m *MMU) SetBusMaster(busMaster BusMaster) {
+	m.busMaster = busMaster
+}
+
 func (m *MMU) RequestInterrupt(interrupt byte) {
 	var IFAddress uint16 = 0xFF0F
 
@@ -39,14 +58,32 @@ func (m *MMU) RequestInterrupt(interrupt byte) {
 }
 
 func (m *MMU) ReadByte(addr uint16) byte {
-	if l := m.locations[addr]; l != nil {
-		return l.ReadByte(addr)
+	if m.busMaster != nil {
+		if value, ok := m.busMaster.BusConflict(addr); ok {
+			return value
+		}
 	}
-	return 0
+	return m.Peek(addr)
 }
 
 func (m *MMU) WriteByte(addr uint16, value byte) {
+	if m.busMaster != nil {
+		if _, ok := m.busMaster.BusConflict(addr); ok {
+			// Writes made while another master holds the bus are lost
+			return
+		}
+	}
 	if l := m.locations[addr]; l != nil {
 		l.WriteByte(addr, value)
 	}
 }
+
+// Peek reads a byte straight from the mapped memory, bypassing any
+// bus conflicts. It is used by bus masters such as DMA to read their
+// source data.
+func (m *MMU) Peek(addr uint16) byte {
+	if l := m.locations[addr]; l != nil {
+		return l.ReadByte(addr)
+	}
+	return 0
+}
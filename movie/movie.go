@@ -0,0 +1,205 @@
+package movie
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	header  = "GopherBoy movie"
+	version = 1
+
+	// buttonChars are the characters buttons are written as, in the order of their bits in
+	// control: right, left, up, down, A, B, select and start. A button that isn't pressed is a '.'
+	buttonChars = "RLUDABsS"
+)
+
+// Poll is the buttons the game saw on a number of reads of the joypad in a row
+type Poll struct {
+	// Buttons has a bit set for each button pressed, in the order of the buttons in control
+	Buttons byte
+	// Reads is the number of reads of P1, it is 0 for the buttons held in a frame the game never read them in
+	Reads int
+}
+
+// Frame is the input to a frame and the hash of the emulator's state at the end of it
+type Frame struct {
+	Polls []Poll
+	Hash  uint64
+}
+
+// Movie is a recording of the buttons pressed from power on, which can be
+// played back to reproduce a run exactly. The buttons are recorded every time the game reads the
+// joypad so input that changes within a frame is reproduced, and the hash of each frame is used
+// to detect when playback has desynced from the recording.
+type Movie struct {
+	// ROMHash is the SHA-1 of the ROM, in hex
+	ROMHash string
+	// Model is the hardware model, such as "DMG"
+	Model string
+	// Rerecords is the number of times the movie has been taken over and recorded again
+	Rerecords int
+
+	Frames []Frame
+}
+
+// Load reads a movie from a file
+func Load(filename string) (*Movie, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Save writes a movie to a file
+func (m *Movie) Save(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = m.Write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Write writes the movie as text, a header of "key value" lines is followed by one line per
+// frame with the frame's hash and its polls. Each poll is its buttons followed by "x" and the
+// number of reads, such as "R...A...x4" for right and A held for 4 reads.
+//
+//	GopherBoy movie 1
+//	rom 0123456789abcdef0123456789abcdef01234567
+//	model DMG
+//	rerecords 0
+//	frame 1a2b3c4d5e6f7081 ........x8 R.......x8
+func (m *Movie) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "%s %d\n", header, version)
+	fmt.Fprintf(bw, "rom %s\n", m.ROMHash)
+	fmt.Fprintf(bw, "model %s\n", m.Model)
+	fmt.Fprintf(bw, "rerecords %d\n", m.Rerecords)
+
+	for _, frame := range m.Frames {
+		fmt.Fprintf(bw, "frame %016x", frame.Hash)
+		for _, poll := range frame.Polls {
+			fmt.Fprintf(bw, " %sx%d", formatButtons(poll.Buttons), poll.Reads)
+		}
+		bw.WriteByte('\n')
+	}
+
+	return bw.Flush()
+}
+
+// Read reads a movie written by Write
+func Read(r io.Reader) (*Movie, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+
+	if !scanner.Scan() || scanner.Text() != fmt.Sprintf("%s %d", header, version) {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("not a GopherBoy movie")
+	}
+
+	m := &Movie{}
+	for n := 2; scanner.Scan(); n++ {
+		line := strings.Fields(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		var err error
+		switch line[0] {
+		case "rom":
+			m.ROMHash = strings.Join(line[1:], "")
+		case "model":
+			m.Model = strings.Join(line[1:], "")
+		case "rerecords":
+			if len(line) == 2 {
+				m.Rerecords, err = strconv.Atoi(line[1])
+			}
+		case "frame":
+			var frame Frame
+			frame, err = parseFrame(line[1:])
+			m.Frames = append(m.Frames, frame)
+		default:
+			err = fmt.Errorf("unknown %q", line[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// parseFrame parses the hash and polls of a frame
+func parseFrame(fields []string) (Frame, error) {
+	if len(fields) < 2 {
+		return Frame{}, fmt.Errorf("frame should have had a hash and polls")
+	}
+
+	hash, err := strconv.ParseUint(fields[0], 16, 64)
+	if err != nil {
+		return Frame{}, fmt.Errorf("invalid frame hash %q", fields[0])
+	}
+
+	frame := Frame{Hash: hash}
+	for _, field := range fields[1:] {
+		i := strings.IndexByte(field, 'x')
+		if i != len(buttonChars) {
+			return Frame{}, fmt.Errorf("invalid poll %q", field)
+		}
+
+		buttons, err := parseButtons(field[:i])
+		if err != nil {
+			return Frame{}, err
+		}
+		reads, err := strconv.Atoi(field[i+1:])
+		if err != nil || reads < 0 {
+			return Frame{}, fmt.Errorf("invalid poll %q", field)
+		}
+
+		frame.Polls = append(frame.Polls, Poll{Buttons: buttons, Reads: reads})
+	}
+
+	return frame, nil
+}
+
+func formatButtons(buttons byte) string {
+	b := []byte("........")
+	for i := range buttonChars {
+		if buttons&(1<<i) != 0 {
+			b[i] = buttonChars[i]
+		}
+	}
+	return string(b)
+}
+
+func parseButtons(s string) (byte, error) {
+	var buttons byte
+	for i := range buttonChars {
+		switch s[i] {
+		case buttonChars[i]:
+			buttons |= 1 << i
+		case '.':
+		default:
+			return 0, fmt.Errorf("invalid buttons %q", s)
+		}
+	}
+	return buttons, nil
+}
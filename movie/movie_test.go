@@ -0,0 +1,155 @@
+package movie
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/control"
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+func TestWriteRead(t *testing.T) {
+	m := &Movie{
+		ROMHash:   "0123456789abcdef0123456789abcdef01234567",
+		Model:     "DMG",
+		Rerecords: 3,
+		Frames: []Frame{
+			{Hash: 0x1A2B3C4D5E6F7081, Polls: []Poll{{Buttons: 0x00, Reads: 8}, {Buttons: 0x11, Reads: 4}}},
+			{Hash: 0xFFFFFFFFFFFFFFFF, Polls: []Poll{{Buttons: 0xFF, Reads: 0}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "frame 1a2b3c4d5e6f7081 ........x8 R...A...x4\n") {
+		t.Errorf("the first frame was written wrong:\n%v", buf.String())
+	}
+
+	result, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, m) {
+		t.Errorf("movie should have been %+v but was %+v", m, result)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	cases := []string{
+		"",
+		"GopherBoy movie 2\n",
+		"GopherBoy movie 1\nframe 00\n",
+		"GopherBoy movie 1\nframe 00 ......x1\n",
+		"GopherBoy movie 1\nframe 00 ....Q...x1\n",
+		"GopherBoy movie 1\nframe zz ........x1\n",
+		"GopherBoy movie 1\nturbo on\n",
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			if _, err := Read(strings.NewReader(c)); err == nil {
+				t.Errorf("%q should have been an error", c)
+			}
+		})
+	}
+}
+
+// readButtons reads P1 with the buttons selected, returning a bit set for each pressed button
+func readButtons(c *control.Controller) byte {
+	c.WriteByte(control.P1, 0x10)
+	return ^c.ReadByte(control.P1) & 0x0F
+}
+
+func TestRecordAndPlayBack(t *testing.T) {
+	c := control.NewController(mmu.NewMMU())
+	m := &Movie{}
+	s := NewRecording(m, c)
+
+	// Frame 0, A is pressed between two reads
+	readButtons(c)
+	c.KeyPressed(control.A)
+	readButtons(c)
+	readButtons(c)
+	s.EndFrame(1)
+
+	// Frame 1, a lag frame with A still held
+	s.EndFrame(2)
+
+	// Frame 2
+	c.KeyReleased(control.A)
+	readButtons(c)
+	s.EndFrame(3)
+	s.Close()
+
+	expected := []Frame{
+		{Hash: 1, Polls: []Poll{{Buttons: 0x00, Reads: 1}, {Buttons: 0x10, Reads: 2}}},
+		{Hash: 2, Polls: []Poll{{Buttons: 0x10, Reads: 0}}},
+		{Hash: 3, Polls: []Poll{{Buttons: 0x00, Reads: 1}}},
+	}
+	if !reflect.DeepEqual(m.Frames, expected) {
+		t.Fatalf("frames should have been %+v but were %+v", expected, m.Frames)
+	}
+
+	// The player's input is ignored during playback
+	c.KeyPressed(control.B)
+	s = NewPlayback(m, c, true)
+
+	reads := []byte{readButtons(c), readButtons(c), readButtons(c)}
+	if !bytes.Equal(reads, []byte{0x0, 0x1, 0x1}) {
+		t.Errorf("frame 0 reads should have been [0 1 1] but were %v", reads)
+	}
+	if err := s.EndFrame(1); err != nil {
+		t.Errorf("frame 0 shouldn't have desynced: %v", err)
+	}
+
+	// Reading the joypad in a lag frame sees the buttons held
+	if buttons := readButtons(c); buttons != 0x1 {
+		t.Errorf("frame 1 should have read 0x1 but read %#x", buttons)
+	}
+	if err, ok := s.EndFrame(7).(*DesyncError); !ok || err.Frame != 1 {
+		t.Errorf("frame 1 should have desynced but returned %v", err)
+	}
+
+	readButtons(c)
+	if err := s.EndFrame(8); err != nil {
+		t.Errorf("a desync should only be reported once but returned %v", err)
+	}
+
+	// At the end of a read-only movie the player takes over
+	if !s.Finished() || c.Source != nil {
+		t.Errorf("playback should have finished")
+	}
+	if buttons := readButtons(c); buttons != 0x2 {
+		t.Errorf("the player's B should have been read but read %#x", buttons)
+	}
+}
+
+func TestTakeOver(t *testing.T) {
+	c := control.NewController(mmu.NewMMU())
+	m := &Movie{}
+	for i := 0; i < 5; i++ {
+		m.Frames = append(m.Frames, Frame{Hash: uint64(i), Polls: []Poll{{Buttons: 0x01, Reads: 1}}})
+	}
+
+	s := NewPlayback(m, c, true)
+	s.EndFrame(0)
+	s.EndFrame(1)
+
+	// Switching to read-write records over the rest of the movie from frame 2
+	s.SetReadOnly(false)
+	c.KeyPressed(control.START)
+	readButtons(c)
+	s.EndFrame(20)
+
+	if len(m.Frames) != 3 || m.Frames[2].Hash != 20 || m.Frames[2].Polls[0].Buttons != 0x80 {
+		t.Errorf("frame 2 should have been re-recorded but the frames were %+v", m.Frames)
+	}
+	if m.Rerecords != 1 {
+		t.Errorf("Rerecords should have been 1 but was %v", m.Rerecords)
+	}
+}
@@ -0,0 +1,188 @@
+package movie
+
+import (
+	"fmt"
+
+	"github.com/kevinbrolly/GopherBoy/control"
+)
+
+// DesyncError is returned when the state at the end of a frame played back
+// doesn't match the recording, after which playback is no longer meaningful
+type DesyncError struct {
+	Frame    int
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *DesyncError) Error() string {
+	return fmt.Sprintf("movie desynced at frame %v, the state hash should have been %016x but was %016x", e.Frame, e.Expected, e.Actual)
+}
+
+// Session records or plays back a movie through a controller, as its source. It plays back
+// until the end of the movie, then in read-only mode the player takes over without being
+// recorded, and in read-write mode their input is recorded onto the end of the movie.
+type Session struct {
+	Movie *Movie
+
+	controller *control.Controller
+	readOnly   bool
+	recording  bool
+	finished   bool
+	desynced   bool
+
+	// frame is the number of the frame being played or recorded
+	frame int
+	// poll and reads are the poll being played back and the number of reads of it so far
+	poll  int
+	reads int
+	// current is the frame being recorded
+	current Frame
+}
+
+// NewRecording starts recording a new movie of controller's buttons
+func NewRecording(m *Movie, controller *control.Controller) *Session {
+	s := &Session{
+		Movie:      m,
+		controller: controller,
+		recording:  true,
+	}
+	controller.Source = s
+	return s
+}
+
+// NewPlayback starts playing back a movie through controller
+func NewPlayback(m *Movie, controller *control.Controller, readOnly bool) *Session {
+	s := &Session{
+		Movie:      m,
+		controller: controller,
+		readOnly:   readOnly,
+	}
+	controller.Source = s
+	s.checkEnd()
+	return s
+}
+
+// Frame returns the number of the frame being played or recorded
+func (s *Session) Frame() int {
+	return s.frame
+}
+
+// Recording returns true while the player's input is being recorded
+func (s *Session) Recording() bool {
+	return s.recording
+}
+
+// Finished returns true once a read-only movie has been played to the end
+func (s *Session) Finished() bool {
+	return s.finished
+}
+
+// ReadOnly returns true if the movie can't be recorded over
+func (s *Session) ReadOnly() bool {
+	return s.readOnly
+}
+
+// SetReadOnly switches between read-only and read-write, it should be called between frames.
+// Switching to read-write while playing back takes over the movie: the rest of the movie is
+// cut off and the player's input is recorded from this frame.
+func (s *Session) SetReadOnly(readOnly bool) {
+	s.readOnly = readOnly
+	if readOnly || s.recording {
+		return
+	}
+
+	if s.finished {
+		// The player has been playing on unrecorded since the end of the movie
+		return
+	}
+
+	s.Movie.Frames = s.Movie.Frames[:s.frame]
+	s.Movie.Rerecords++
+	s.recording = true
+}
+
+// Poll records or plays back the buttons the game sees on each read of the joypad
+func (s *Session) Poll(held byte) byte {
+	if s.recording {
+		s.record(held)
+		return held
+	}
+	return s.play()
+}
+
+// EndFrame finishes the frame, with the hash of the state at the end of it. A
+// recorded frame is added to the movie, a frame played back is checked against
+// the recording, returning a *DesyncError the first time they don't match.
+func (s *Session) EndFrame(hash uint64) error {
+	if s.finished {
+		return nil
+	}
+
+	if s.recording {
+		// A frame the game didn't read the joypad in still records the buttons held
+		if len(s.current.Polls) == 0 {
+			s.current.Polls = append(s.current.Polls, Poll{Buttons: s.controller.Buttons()})
+		}
+		s.current.Hash = hash
+		s.Movie.Frames = append(s.Movie.Frames, s.current)
+		s.current = Frame{}
+		s.frame++
+		return nil
+	}
+
+	var err error
+	if expected := s.Movie.Frames[s.frame].Hash; expected != hash && !s.desynced {
+		s.desynced = true
+		err = &DesyncError{Frame: s.frame, Expected: expected, Actual: hash}
+	}
+
+	s.frame++
+	s.poll = 0
+	s.reads = 0
+	s.checkEnd()
+	return err
+}
+
+// Close stops recording or playing back, the player's input goes straight to the game again
+func (s *Session) Close() {
+	if s.controller.Source == s {
+		s.controller.Source = nil
+	}
+}
+
+// record adds a read of the joypad to the frame being recorded
+func (s *Session) record(buttons byte) {
+	polls := s.current.Polls
+	if n := len(polls); n > 0 && polls[n-1].Buttons == buttons {
+		polls[n-1].Reads++
+		return
+	}
+	s.current.Polls = append(polls, Poll{Buttons: buttons, Reads: 1})
+}
+
+// play returns the buttons for the next read of the joypad. If the game reads
+// the joypad more times than recorded, the last poll of the frame is repeated.
+func (s *Session) play() byte {
+	polls := s.Movie.Frames[s.frame].Polls
+	for s.poll < len(polls)-1 && s.reads >= polls[s.poll].Reads {
+		s.poll++
+		s.reads = 0
+	}
+	s.reads++
+	return polls[s.poll].Buttons
+}
+
+// checkEnd switches from playing back to recording at the end of a read-write
+// movie, or hands control back to the player at the end of a read-only one
+func (s *Session) checkEnd() {
+	if s.frame < len(s.Movie.Frames) {
+		return
+	}
+
+	if s.readOnly {
+		s.finished = true
+		s.Close()
+	} else {
+		s.recording = true
+	}
+}
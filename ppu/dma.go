@@ -0,0 +1,97 @@
+package ppu
+
+const (
+	// OAM DMA copies 160 bytes, one byte per M-cycle
+	dmaLength = 0xA0
+
+	// The transfer starts one M-cycle after DMA is written
+	dmaStartDelay = 1
+)
+
+type dma struct {
+	// active is true while the transfer holds the bus
+	active bool
+	source uint16
+	index  uint16
+
+	// value is the last byte transferred, which is what the CPU
+	// sees when it reads from the bus the transfer is using
+	value byte
+
+	// A write to DMA schedules a new transfer, any transfer that is
+	// already running continues until the new one starts
+	pending      bool
+	pendingDelay int
+	pendingSrc   uint16
+}
+
+func (d *dma) start(value byte) {
+	// The value holds the source address of the OAM data divided by 100
+	// so we have to multiply it first
+	d.pending = true
+	d.pendingDelay = dmaStartDelay
+	d.pendingSrc = uint16(value) << 8
+}
+
+// stepDMA advances OAM DMA by the given number of M-cycles
+func (ppu *PPU) stepDMA(mCycles int) {
+	for i := 0; i < mCycles; i++ {
+		if ppu.dma.active {
+			ppu.transferDMAByte()
+		}
+
+		if ppu.dma.pending {
+			ppu.dma.pendingDelay--
+			if ppu.dma.pendingDelay == 0 {
+				ppu.dma.pending = false
+				ppu.dma.active = true
+				ppu.dma.source = ppu.dma.pendingSrc
+				ppu.dma.index = 0
+			}
+		}
+	}
+}
+
+func (ppu *PPU) transferDMAByte() {
+	sourceAddr := ppu.dma.source + ppu.dma.index
+
+	// Sources from 0xE000 upwards read from Working RAM, the same as echo RAM
+	if sourceAddr >= 0xE000 {
+		sourceAddr -= 0x2000
+	}
+
+	ppu.dma.value = ppu.mmu.Peek(sourceAddr)
+	ppu.writeOAM(0xFE00+ppu.dma.index, ppu.dma.value)
+
+	ppu.dma.index++
+	if ppu.dma.index == dmaLength {
+		ppu.dma.active = false
+	}
+}
+
+// BusConflict is called by the MMU for every CPU access. While a transfer
+// is running the CPU can only reach the I/O registers and HRAM, OAM reads
+// return 0xFF and any access to the bus the transfer is reading from sees
+// the byte being transferred.
+func (ppu *PPU) BusConflict(addr uint16) (value byte, ok bool) {
+	if !ppu.dma.active {
+		return 0, false
+	}
+
+	switch {
+	case addr >= 0xFF00:
+		return 0, false
+	case addr >= 0xFE00:
+		return 0xFF, true
+	case isVRAMBus(addr) == isVRAMBus(ppu.dma.source):
+		return ppu.dma.value, true
+	}
+
+	return 0, false
+}
+
+// The DMG has two buses, the VRAM bus and the external bus which is
+// shared by the cartridge and Working RAM.
+func isVRAMBus(addr uint16) bool {
+	return addr >= 0x8000 && addr <= 0x9FFF
+}
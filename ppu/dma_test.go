@@ -0,0 +1,122 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+type testRAM struct {
+	data [0x10000]byte
+}
+
+func (r *testRAM) ReadByte(addr uint16) byte {
+	return r.data[addr]
+}
+
+func (r *testRAM) WriteByte(addr uint16, value byte) {
+	r.data[addr] = value
+}
+
+func newDMATestPPU() (*mmu.MMU, *PPU, *testRAM) {
+	m := mmu.NewMMU()
+	ram := &testRAM{}
+	m.MapMemoryRange(ram, 0x0000, 0x7FFF)
+	m.MapMemoryRange(ram, 0xC000, 0xDFFF)
+	m.MapMemoryRange(ram, 0xFF80, 0xFFFE)
+
+	ppu := NewPPU(m)
+
+	for i := uint16(0); i < dmaLength; i++ {
+		ram.data[0xC000+i] = byte(i + 1)
+	}
+
+	return m, ppu, ram
+}
+
+func TestDMATransfer(t *testing.T) {
+	m, ppu, _ := newDMATestPPU()
+
+	m.WriteByte(DMA, 0xC0)
+
+	// 1 M-cycle start up delay followed by one byte per M-cycle
+	ppu.stepDMA(dmaStartDelay + dmaLength)
+
+	if ppu.dma.active {
+		t.Errorf("DMA should have finished after %v M-cycles", dmaStartDelay+dmaLength)
+	}
+
+	for i := uint16(0); i < dmaLength; i++ {
+		if value := m.ReadByte(0xFE00 + i); value != byte(i+1) {
+			t.Errorf("OAM %#x should have been %#x but was %#x", 0xFE00+i, byte(i+1), value)
+		}
+	}
+
+	if m.ReadByte(DMA) != 0xC0 {
+		t.Errorf("DMA register should read back the last value written")
+	}
+}
+
+func TestDMABusConflicts(t *testing.T) {
+	m, ppu, ram := newDMATestPPU()
+	ram.data[0xFF80] = 0x42
+	ram.data[0x0100] = 0x99
+
+	m.WriteByte(DMA, 0xC0)
+
+	// OAM is still accessible during the start up delay
+	if m.ReadByte(0xFE00) == 0xFF {
+		t.Errorf("OAM should be readable before the transfer starts")
+	}
+
+	ppu.stepDMA(dmaStartDelay + 3)
+
+	cases := []struct {
+		Name     string
+		Address  uint16
+		Expected byte
+	}{
+		{"OAM", 0xFE00, 0xFF},
+		{"ROM shares the external bus", 0x0100, 0x03},
+		{"WRAM shares the external bus", 0xC050, 0x03},
+		{"HRAM", 0xFF80, 0x42},
+		{"VRAM is on a separate bus", 0x8000, 0x00},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			if value := m.ReadByte(tt.Address); value != tt.Expected {
+				t.Errorf("read from %#x should have returned %#x but returned %#x", tt.Address, tt.Expected, value)
+			}
+		})
+	}
+
+	// Writes to the conflicting bus are lost
+	m.WriteByte(0xC050, 0x11)
+	if ram.data[0xC050] == 0x11 {
+		t.Errorf("write to WRAM during DMA should have been ignored")
+	}
+}
+
+func TestDMARestart(t *testing.T) {
+	m, ppu, ram := newDMATestPPU()
+	for i := uint16(0); i < dmaLength; i++ {
+		ram.data[0xD000+i] = 0xA0
+	}
+
+	m.WriteByte(DMA, 0xC0)
+	ppu.stepDMA(dmaStartDelay + 10)
+
+	// Restarting mid transfer keeps OAM blocked while the new transfer starts
+	m.WriteByte(DMA, 0xD0)
+	ppu.stepDMA(1)
+	if m.ReadByte(0xFE00) != 0xFF {
+		t.Errorf("OAM should stay blocked while a restarted transfer starts up")
+	}
+
+	ppu.stepDMA(dmaLength)
+	for i := uint16(0); i < dmaLength; i++ {
+		if value := ppu.readOAM(0xFE00 + i); value != 0xA0 {
+			t.Errorf("OAM %#x should have been 0xa0 but was %#x", 0xFE00+i, value)
+		}
+	}
+}
@@ -16,30 +16,15 @@ type Dot struct {
 	Type            DotType
 }
 
-func (d *Dot) ToRGBA(palette byte) color.RGBA {
-	var paletteNum byte
+// Shade returns the shade of the dot, using the palette
+// register to map its color identifier to one of the four shades
+func (d *Dot) Shade(register byte) byte {
 	var bitmask byte = 0x3
-	switch d.ColorIdentifier {
-	case 0:
-		paletteNum = palette & bitmask
-	case 1:
-		paletteNum = (palette >> 2) & bitmask
-	case 2:
-		paletteNum = (palette >> 4) & bitmask
-	case 3:
-		paletteNum = (palette >> 6) & bitmask
-	}
-
-	switch paletteNum {
-	case 0:
-		return color.RGBA{224, 248, 208, 0}
-	case 1:
-		return color.RGBA{136, 192, 112, 0}
-	case 2:
-		return color.RGBA{52, 104, 86, 0}
-	case 3:
-		return color.RGBA{8, 24, 32, 0}
-	}
+	return (register >> (d.ColorIdentifier * 2)) & bitmask
+}
 
-	return color.RGBA{}
+// ToRGBA returns the color of the dot, using the palette register
+// to map its color identifier to a shade of the palette
+func (d *Dot) ToRGBA(register byte, palette Palette) color.RGBA {
+	return palette[d.Shade(register)]
 }
@@ -5,23 +5,124 @@ import (
 	"github.com/kevinbrolly/GopherBoy/utils"
 )
 
+type fetcherState int
+
+// The background fetcher takes 2 dots to read each of the tile number and
+// the two bytes of tile data, then tries to push the 8 pixels of the tile line
+// to the FIFO every dot until there is room
+const (
+	fetchTileNumber fetcherState = iota
+	fetchTileDataLow
+	fetchTileDataHigh
+	fetchPush
+)
+
 type Fetcher struct {
 	tileMapAddress  uint16
 	tileDataAddress uint16
 	SCY             byte
 	LY              byte
 	memory          mmu.Memory
+
+	// Registers the PPU updates every dot, so writes made
+	// during mode 3 take effect part way through the scanline
+	SCX        byte
+	mapAddress uint16
+	spriteSize byte
+
+	// window is true while fetching window tiles, which are
+	// not scrolled by SCX
+	window bool
+
+	state fetcherState
+	dots  int
+	// tileX is the number of tiles fetched so far on this line
+	tileX byte
+
+	// The first fetch on each line is thrown away, which
+	// delays the first pixel of mode 3 by 6 dots
+	dummyFetch bool
+
+	tileIdentifier byte
+	data1          byte
+	data2          byte
+}
+
+// Reset restarts the fetcher at the beginning of a scanline
+func (f *Fetcher) Reset() {
+	f.state = fetchTileNumber
+	f.dots = 0
+	f.tileX = 0
+	f.window = false
+	f.dummyFetch = true
+}
+
+// StartWindow restarts the fetcher at the first tile of the window
+func (f *Fetcher) StartWindow() {
+	f.state = fetchTileNumber
+	f.dots = 0
+	f.tileX = 0
+	f.window = true
+}
+
+// StopWindow switches the fetcher back to the background, continuing at tileX
+func (f *Fetcher) StopWindow(tileX byte) {
+	f.window = false
+	f.tileX = tileX
+}
+
+// Tick advances the fetcher by one dot, pushing a line of
+// pixels onto fifo once one has been fetched and there is room
+func (f *Fetcher) Tick(fifo *Fifo) {
+	f.dots++
+
+	switch f.state {
+	case fetchTileNumber:
+		if f.dots == 2 {
+			column := f.tileX
+			if !f.window {
+				column += f.SCX / 8
+			}
+			f.tileMapAddress = f.mapAddress + uint16(column&0x1F)
+			f.tileIdentifier = f.getTileIdentifier()
+			f.nextState(fetchTileDataLow)
+		}
+	case fetchTileDataLow:
+		if f.dots == 2 {
+			f.data1 = f.memory.ReadByte(f.tileLineAddress())
+			f.nextState(fetchTileDataHigh)
+		}
+	case fetchTileDataHigh:
+		if f.dots == 2 {
+			f.data2 = f.memory.ReadByte(f.tileLineAddress() + 1)
+
+			if f.dummyFetch {
+				f.dummyFetch = false
+				f.nextState(fetchTileNumber)
+			} else {
+				f.nextState(fetchPush)
+			}
+		}
+	case fetchPush:
+		// Pixels can only be pushed when the FIFO has room for them
+		if fifo.Length() <= 8 {
+			fifo.PushDots(tileLineDots(f.data1, f.data2))
+			f.tileX++
+			f.nextState(fetchTileNumber)
+		}
+	}
 }
 
-func (f *Fetcher) FirstTileLine() []*Dot {
-	tileLine := f.fetchTileLine(f.getTileIdentifier())
-	return tileLine
+func (f *Fetcher) nextState(state fetcherState) {
+	f.state = state
+	f.dots = 0
 }
 
-func (f *Fetcher) NextTileLine() []*Dot {
-	tileLine := f.fetchTileLine(f.getTileIdentifier())
-	f.tileMapAddress++
-	return tileLine
+// tileLineAddress returns the address of the first byte of
+// tile data for the current line of the fetched tile
+func (f *Fetcher) tileLineAddress() uint16 {
+	verticalLine := (f.LY + f.SCY) % 8
+	return f.getTileDataAddress(f.tileIdentifier) + uint16(verticalLine)*2
 }
 
 func (f *Fetcher) getTileIdentifier() byte {
@@ -55,15 +156,8 @@ func (f *Fetcher) getTileData(dataAddress uint16, line byte) (data1, data2 byte)
 	return data1, data2
 }
 
-func (f *Fetcher) fetchTileLine(tileIdentifier byte) []*Dot {
-	tileDataAddress := f.getTileDataAddress(tileIdentifier)
-	// Find the correct vertical line we're on of the
-	// tile to get the tile data from memory
-	verticalLine := (f.LY + f.SCY) % 8
-	verticalLine = verticalLine * 2 // each vertical line takes up two bytes of memory
-
-	data1, data2 := f.getTileData(tileDataAddress, verticalLine)
-
+// tileLineDots decodes the two bytes of a line of tile data into 8 background dots
+func tileLineDots(data1, data2 byte) []*Dot {
 	line := make([]*Dot, 8)
 	dataBit := 7
 	for i := byte(0); i <= 7; i++ {
@@ -85,11 +179,22 @@ func (f *Fetcher) fetchTileLine(tileIdentifier byte) []*Dot {
 	return line
 }
 
-func (f *Fetcher) fetchSpriteLine(sprite *Sprite) []*Dot {
-	verticalLine := f.LY - (sprite.Y - 16)
-	verticalLine = verticalLine * 2 // each vertical line takes up two bytes of memory
-	spriteDataAddress := 0x8000 + uint16(sprite.TileNumber)*16
-	data1, data2 := f.getTileData(spriteDataAddress, verticalLine)
+func (f *Fetcher) fetchSpriteLine(sprite *Sprite, ly byte) []*Dot {
+	verticalLine := int(ly) + 16 - int(sprite.Y)
+	if sprite.YFlip() {
+		verticalLine = int(f.spriteSize) - 1 - verticalLine
+	}
+
+	// In 8x16 mode bit 0 of the tile number is ignored, the top
+	// half of the sprite is the even tile and the bottom half the odd one
+	tileNumber := sprite.TileNumber
+	if f.spriteSize == 16 {
+		tileNumber &= 0xFE
+	}
+
+	spriteDataAddress := 0x8000 + uint16(tileNumber)*16
+	// each vertical line takes up two bytes of memory
+	data1, data2 := f.getTileData(spriteDataAddress, byte(verticalLine*2))
 
 	line := make([]*Dot, 8)
 	dataBit := 7
@@ -83,31 +83,37 @@ func TestGetTileDataAddress(t *testing.T) {
 	}
 }
 
-func TestGetTileLine(t *testing.T) {
-	data := make([]byte, 0x10000)
-	data[0x8000] = 0xFF
-	data[0x8001] = 0xFF
-
-	mmu := &MockMMU{
-		data: data,
-	}
-
-	fetcher := &Fetcher{
-		tileMapAddress:  0x0000,
-		tileDataAddress: 0x8000,
-		SCY:             0,
-		memory:          mmu,
+func TestTileLineAddress(t *testing.T) {
+	cases := []struct {
+		TileDataAddress uint16
+		TileIdentifier  byte
+		LY              byte
+		SCY             byte
+		Expected        uint16
+	}{
+		{0x8000, 0, 0, 0, 0x8000},
+		{0x8000, 1, 3, 0, 0x8016},
+		{0x8000, 1, 3, 2, 0x801A},
+		{0x8000, 1, 7, 1, 0x8010},
+		{0x8800, 0, 0, 0, 0x9000},
+		{0x8800, 128, 1, 0, 0x8802},
 	}
-
-	TileLine := fetcher.fetchTileLine(0)
-	for _, pixel := range TileLine {
-		if pixel.ColorIdentifier != 0x3 {
-			t.Errorf("getTileLine() should have returned pixels with ColourIdentifier 3 but instead returned %x", pixel.ColorIdentifier)
-		}
+	for _, tt := range cases {
+		t.Run(fmt.Sprintf("Tile %v LY %v SCY %v", tt.TileIdentifier, tt.LY, tt.SCY), func(t *testing.T) {
+			fetcher := &Fetcher{
+				tileDataAddress: tt.TileDataAddress,
+				tileIdentifier:  tt.TileIdentifier,
+				LY:              tt.LY,
+				SCY:             tt.SCY,
+			}
+			if address := fetcher.tileLineAddress(); address != tt.Expected {
+				t.Errorf("tileLineAddress() should have returned %#x but returned %#x", tt.Expected, address)
+			}
+		})
 	}
 }
 
-func TestGetNextTileLine(t *testing.T) {
+func TestFetcherTick(t *testing.T) {
 	data := make([]byte, 0x10000)
 	data[0x0000] = 0x00
 	data[0x0001] = 0x01
@@ -132,41 +138,83 @@ func TestGetNextTileLine(t *testing.T) {
 		data: data,
 	}
 
-	fetcher := &Fetcher{
-		tileMapAddress:  0x0000,
-		tileDataAddress: 0x8000,
-		LY:              0,
-		SCY:             0,
-		memory:          mmu,
+	for _, ly := range []byte{0, 1} {
+		t.Run(fmt.Sprintf("LY %v", ly), func(t *testing.T) {
+			fetcher := &Fetcher{
+				mapAddress:      0x0000,
+				tileDataAddress: 0x8000,
+				LY:              ly,
+				memory:          mmu,
+			}
+			fetcher.Reset()
+			fifo := &Fifo{}
+
+			// The tiles in the map are fetched in turn, the first after the 6 dot dummy
+			// fetch at the start of the line, 6 dots to fetch it and 1 to push it
+			dots := 0
+			for i, expected := range []byte{0x3, 0x2, 0x1} {
+				for fifo.Length() < 8 {
+					fetcher.Tick(fifo)
+					dots++
+				}
+				if i == 0 && dots != 13 {
+					t.Errorf("the first tile should have been pushed after 13 dots but was pushed after %v", dots)
+				}
+
+				for j := 0; j < 8; j++ {
+					if dot := fifo.PopDot(); dot.ColorIdentifier != expected {
+						t.Errorf("tile %v should have had ColorIdentifier %x but had %x", i, expected, dot.ColorIdentifier)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFetchSpriteLine(t *testing.T) {
+	mmu := &MockMMU{
+		data: make([]byte, 0x10000),
+	}
+
+	// The low byte of each line of tiles 0-3 is the tile number
+	// in the high nibble and the line number in the low nibble
+	for tile := 0; tile < 4; tile++ {
+		for line := 0; line < 8; line++ {
+			mmu.data[0x8000+tile*16+line*2] = byte(tile<<4 | line)
+		}
 	}
 
 	cases := []struct {
-		Fetcher                  *Fetcher
-		ExpectedColourIdentifier byte
+		Name       string
+		SpriteSize byte
+		LY         byte
+		Sprite     Sprite
+		Expected   byte
 	}{
-		{fetcher, 0x3}, // 11
-		{fetcher, 0x2}, // 10
-		{fetcher, 0x1}, // 01
+		{"8x8", 8, 2, Sprite{Y: 16, TileNumber: 1}, 0x12},
+		{"8x8 Y flip", 8, 2, Sprite{Y: 16, TileNumber: 1, Attributes: 0x40}, 0x15},
+		{"8x8 X flip", 8, 0, Sprite{Y: 16, TileNumber: 1, Attributes: 0x20}, 0x08},
+		{"8x8 partly above the screen", 8, 0, Sprite{Y: 12, TileNumber: 1}, 0x14},
+		{"8x16 top half ignores bit 0", 16, 1, Sprite{Y: 16, TileNumber: 3}, 0x21},
+		{"8x16 bottom half", 16, 9, Sprite{Y: 16, TileNumber: 2}, 0x31},
+		{"8x16 Y flip", 16, 1, Sprite{Y: 16, TileNumber: 3, Attributes: 0x40}, 0x36},
+		{"8x16 Y flip bottom half", 16, 9, Sprite{Y: 16, TileNumber: 2, Attributes: 0x40}, 0x26},
 	}
-
 	for _, tt := range cases {
-		tileLine := tt.Fetcher.NextTileLine()
-		for _, pixel := range tileLine {
-			if pixel.ColorIdentifier != tt.ExpectedColourIdentifier {
-				t.Errorf("getTileLine() should have returned pixels with ColourIdentifier %x but instead returned %x", tt.ExpectedColourIdentifier, pixel.ColorIdentifier)
+		t.Run(tt.Name, func(t *testing.T) {
+			fetcher := &Fetcher{
+				spriteSize: tt.SpriteSize,
+				memory:     mmu,
 			}
-		}
-	}
 
-	fetcher.tileMapAddress = 0x0000
-	fetcher.LY = 1
+			var low byte
+			for _, dot := range fetcher.fetchSpriteLine(&tt.Sprite, tt.LY) {
+				low = low<<1 | dot.ColorIdentifier&0x01
+			}
 
-	for _, tt := range cases {
-		tileLine := tt.Fetcher.NextTileLine()
-		for _, pixel := range tileLine {
-			if pixel.ColorIdentifier != tt.ExpectedColourIdentifier {
-				t.Errorf("getTileLine() should have returned pixels with ColourIdentifier %x but instead returned %x", tt.ExpectedColourIdentifier, pixel.ColorIdentifier)
+			if low != tt.Expected {
+				t.Errorf("fetchSpriteLine() should have fetched %#x but fetched %#x", tt.Expected, low)
 			}
-		}
+		})
 	}
 }
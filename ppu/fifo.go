@@ -10,6 +10,19 @@ func (fifo *Fifo) PushDots(dots []*Dot) {
 	}
 }
 
+// MixDots merges a line of sprite dots into the FIFO. Dots already in the
+// FIFO belong to sprites with a higher priority, so they are only replaced
+// where they are transparent.
+func (fifo *Fifo) MixDots(dots []*Dot) {
+	for i, dot := range dots {
+		if i >= len(fifo.dots) {
+			fifo.dots = append(fifo.dots, dot)
+		} else if fifo.dots[i].ColorIdentifier == 0 {
+			fifo.dots[i] = dot
+		}
+	}
+}
+
 func (fifo *Fifo) PopDot() (dot *Dot) {
 	dot, fifo.dots = fifo.dots[0], fifo.dots[1:]
 	return
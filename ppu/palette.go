@@ -0,0 +1,199 @@
+package ppu
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Palette maps the four DMG shades, from lightest to darkest, to colors
+type Palette [4]color.RGBA
+
+// Palettes holds the colors used for the background and window
+// and for sprites using each of the two object palettes
+type Palettes struct {
+	Name string
+	BG   Palette
+	OBP0 Palette
+	OBP1 Palette
+}
+
+// NewPalettes returns Palettes that use the same colors for the background and sprites
+func NewPalettes(name string, palette Palette) Palettes {
+	return Palettes{
+		Name: name,
+		BG:   palette,
+		OBP0: palette,
+		OBP1: palette,
+	}
+}
+
+// Built in palettes
+var (
+	// The green tint of the original DMG screen
+	DMGPalette = Palette{
+		{224, 248, 208, 0xFF},
+		{136, 192, 112, 0xFF},
+		{52, 104, 86, 0xFF},
+		{8, 24, 32, 0xFF},
+	}
+
+	// The grey screen of the Game Boy Pocket
+	PocketPalette = Palette{
+		{196, 207, 161, 0xFF},
+		{139, 149, 109, 0xFF},
+		{77, 83, 60, 0xFF},
+		{31, 31, 31, 0xFF},
+	}
+
+	// The blue green backlight of the Game Boy Light
+	LightPalette = Palette{
+		{0, 178, 132, 0xFF},
+		{0, 156, 116, 0xFF},
+		{0, 105, 74, 0xFF},
+		{0, 81, 56, 0xFF},
+	}
+
+	// Evenly spaced greys from white to black
+	HighContrastPalette = Palette{
+		{255, 255, 255, 0xFF},
+		{170, 170, 170, 0xFF},
+		{85, 85, 85, 0xFF},
+		{0, 0, 0, 0xFF},
+	}
+
+	// Shades of yellow and blue, which are distinguishable
+	// with the common forms of color blindness
+	ColorBlindPalette = Palette{
+		{255, 244, 186, 0xFF},
+		{240, 180, 60, 0xFF},
+		{40, 100, 170, 0xFF},
+		{10, 20, 60, 0xFF},
+	}
+)
+
+// Presets are the built in palettes that can be cycled through, the first is the default
+var Presets = []Palettes{
+	NewPalettes("DMG", DMGPalette),
+	NewPalettes("Pocket", PocketPalette),
+	NewPalettes("Light", LightPalette),
+	NewPalettes("High Contrast", HighContrastPalette),
+	NewPalettes("Color Blind", ColorBlindPalette),
+}
+
+// LoadPaletteFile loads custom palettes from a file, see ReadPalettes for the supported formats.
+// The palettes are named after the file.
+func LoadPaletteFile(filename string) (Palettes, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Palettes{}, err
+	}
+	defer f.Close()
+
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return ReadPalettes(name, f)
+}
+
+// ReadPalettes reads custom palettes in either JASC-PAL (.pal) format, or as a list
+// of hex colors such as "#E0F8D0", "0xE0F8D0" or "E0F8D0" separated by commas or
+// whitespace, which is the format BGB copies palettes in.
+// 4 colors set the background and both sprite palettes, 12 colors set
+// the background, OBP0 and OBP1 palettes in that order.
+func ReadPalettes(name string, r io.Reader) (Palettes, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip blank lines and comments
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return Palettes{}, err
+	}
+
+	var colors []color.RGBA
+	var err error
+	if len(lines) > 0 && lines[0] == "JASC-PAL" {
+		colors, err = parseJASC(lines)
+	} else {
+		colors, err = parseHexList(lines)
+	}
+	if err != nil {
+		return Palettes{}, err
+	}
+
+	switch len(colors) {
+	case 4:
+		return NewPalettes(name, Palette{colors[0], colors[1], colors[2], colors[3]}), nil
+	case 12:
+		return Palettes{
+			Name: name,
+			BG:   Palette{colors[0], colors[1], colors[2], colors[3]},
+			OBP0: Palette{colors[4], colors[5], colors[6], colors[7]},
+			OBP1: Palette{colors[8], colors[9], colors[10], colors[11]},
+		}, nil
+	}
+	return Palettes{}, fmt.Errorf("palette must have 4 or 12 colors but has %v", len(colors))
+}
+
+// parseJASC parses a JASC-PAL file, which has a header of "JASC-PAL",
+// the version and the number of colors followed by one "R G B" line per color
+func parseJASC(lines []string) ([]color.RGBA, error) {
+	if len(lines) < 3 {
+		return nil, fmt.Errorf("JASC-PAL header is incomplete")
+	}
+
+	count, err := strconv.Atoi(lines[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JASC-PAL color count %q", lines[2])
+	}
+	if len(lines)-3 < count {
+		return nil, fmt.Errorf("JASC-PAL should have %v colors but has %v", count, len(lines)-3)
+	}
+
+	colors := make([]color.RGBA, count)
+	for i, line := range lines[3 : 3+count] {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid JASC-PAL color %q", line)
+		}
+
+		var rgb [3]uint8
+		for j, field := range fields {
+			value, err := strconv.ParseUint(field, 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JASC-PAL color %q", line)
+			}
+			rgb[j] = uint8(value)
+		}
+		colors[i] = color.RGBA{rgb[0], rgb[1], rgb[2], 0xFF}
+	}
+	return colors, nil
+}
+
+// parseHexList parses colors written as 6 digit hex values
+func parseHexList(lines []string) ([]color.RGBA, error) {
+	var colors []color.RGBA
+	for _, line := range lines {
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		for _, field := range fields {
+			hex := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(field), "#"), "0x")
+			value, err := strconv.ParseUint(hex, 16, 32)
+			if len(hex) != 6 || err != nil {
+				return nil, fmt.Errorf("invalid hex color %q", field)
+			}
+			colors = append(colors, color.RGBA{uint8(value >> 16), uint8(value >> 8), uint8(value), 0xFF})
+		}
+	}
+	return colors, nil
+}
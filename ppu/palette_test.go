@@ -0,0 +1,122 @@
+package ppu
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestReadPalettes(t *testing.T) {
+	white := color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	grey := color.RGBA{0xAA, 0xAA, 0xAA, 0xFF}
+	dark := color.RGBA{0x55, 0x55, 0x55, 0xFF}
+	black := color.RGBA{0x00, 0x00, 0x00, 0xFF}
+	red := color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+
+	greys := Palette{white, grey, dark, black}
+	reds := Palette{white, red, dark, black}
+
+	cases := []struct {
+		Name     string
+		Input    string
+		Expected Palettes
+	}{
+		{"Hex list", "#FFFFFF\n#AAAAAA\n#555555\n#000000\n", NewPalettes("test", greys)},
+		{"BGB comma separated", "ffffff,aaaaaa,555555,000000", NewPalettes("test", greys)},
+		{"0x prefix and comments", "; greys\n0xFFFFFF 0xAAAAAA\n0x555555 0x000000\n", NewPalettes("test", greys)},
+		{"JASC-PAL", "JASC-PAL\n0100\n4\n255 255 255\n170 170 170\n85 85 85\n0 0 0\n", NewPalettes("test", greys)},
+		{
+			"12 colors set each palette",
+			"FFFFFF,AAAAAA,555555,000000\nFFFFFF,FF0000,555555,000000\nFFFFFF,AAAAAA,555555,000000",
+			Palettes{Name: "test", BG: greys, OBP0: reds, OBP1: greys},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			palettes, err := ReadPalettes("test", strings.NewReader(tt.Input))
+			if err != nil {
+				t.Fatalf("ReadPalettes() returned error: %v", err)
+			}
+			if palettes != tt.Expected {
+				t.Errorf("ReadPalettes() should have returned %v but returned %v", tt.Expected, palettes)
+			}
+		})
+	}
+}
+
+func TestReadPalettesErrors(t *testing.T) {
+	cases := []struct {
+		Name  string
+		Input string
+	}{
+		{"Too few colors", "FFFFFF,AAAAAA,555555"},
+		{"Invalid hex", "FFFFFF,AAAAAA,555555,GGGGGG"},
+		{"Short hex", "FFF,AAA,555,000"},
+		{"JASC-PAL missing colors", "JASC-PAL\n0100\n4\n255 255 255\n"},
+		{"JASC-PAL invalid color", "JASC-PAL\n0100\n4\n255 255 255\n170 170\n85 85 85\n0 0 0\n"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			if _, err := ReadPalettes("test", strings.NewReader(tt.Input)); err == nil {
+				t.Errorf("ReadPalettes() should have returned an error")
+			}
+		})
+	}
+}
+
+func TestPalettesApplied(t *testing.T) {
+	ppu := newTestPPU()
+	for i := 0; i < 16; i++ {
+		ppu.VRAM[0x10+i] = 0xFF
+	}
+	ppu.Palettes = NewPalettes("test", HighContrastPalette)
+	ppu.Palettes.OBP1 = ColorBlindPalette
+	ppu.BGP = 0xE4
+	ppu.OBP1 = 0xE4
+	*ppu.OAM[0] = Sprite{Y: 16, X: 8, TileNumber: 1, Attributes: 0x10}
+	ppu.WriteByte(LCDC, 0x93)
+
+	stepToLine(ppu, 1)
+
+	if c := ppu.FrameBuffer.RGBAAt(0, 0); c != ColorBlindPalette[3] {
+		t.Errorf("sprite pixel should have been %v but was %v", ColorBlindPalette[3], c)
+	}
+	if c := ppu.FrameBuffer.RGBAAt(8, 0); c != HighContrastPalette[0] {
+		t.Errorf("background pixel should have been %v but was %v", HighContrastPalette[0], c)
+	}
+}
+
+func TestSpritePaletteSelection(t *testing.T) {
+	cases := []struct {
+		Name       string
+		Attributes byte
+		Expected   color.RGBA
+	}{
+		{"OBP0", 0x00, HighContrastPalette[3]},
+		{"OBP1", 0x10, HighContrastPalette[0]},
+		// Bit 2 is part of the CGB palette number, it doesn't select the DMG palette
+		{"Bit 2 set", 0x04, HighContrastPalette[3]},
+		{"OBP1 with bit 2 set", 0x14, HighContrastPalette[0]},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu := newTestPPU()
+			for i := 0; i < 16; i++ {
+				ppu.VRAM[0x10+i] = 0xFF
+			}
+			ppu.Palettes = NewPalettes("test", HighContrastPalette)
+			// Color 3 is shade 3 in OBP0 and shade 0 in OBP1
+			ppu.OBP0 = 0xE4
+			ppu.OBP1 = 0x1B
+			*ppu.OAM[0] = Sprite{Y: 16, X: 8, TileNumber: 1, Attributes: tt.Attributes}
+			ppu.WriteByte(LCDC, 0x93)
+
+			stepToLine(ppu, 1)
+
+			if c := ppu.FrameBuffer.RGBAAt(0, 0); c != tt.Expected {
+				t.Errorf("sprite pixel should have been %v but was %v", tt.Expected, c)
+			}
+		})
+	}
+}
@@ -101,11 +101,16 @@ type PPU struct {
 	mmu *mmu.MMU
 
 	FrameBuffer *image.RGBA
+	// ColorIDs holds the 2-bit color identifier of each pixel of the frame
+	// buffer, before it is mapped to a shade by BGP, OBP0 or OBP1
+	ColorIDs [144][160]byte
 
 	VRAM [16384]byte
 
-	OAM            []*Sprite
-	VisibleSprites []*Sprite
+	OAM []*Sprite
+	// VisibleSprites are copies of the sprites on the current line, taken by the OAM search,
+	// so changes to OAM during the line don't move the sprites being drawn
+	VisibleSprites []Sprite
 
 	STAT *stat // LCD Status/Mode
 	SCY  byte  // Scroll Y
@@ -161,9 +166,58 @@ type PPU struct {
 	// LCDC Bit 0 - BG Display (for CGB see below) (0=Off, 1=On)
 	backgroundEnabled bool
 
-	Cycles int // Number of cycles since the last LCD Status Mode Change
+	Cycles int // Number of dots since the start of the current scanline
+
+	FrameCount int // Number of frames drawn, incremented at the start of VBlank
+
+	// statLine is the internal STAT interrupt line, the OR of all enabled STAT sources
+	statLine bool
+
+	// RestrictAccess blocks CPU access to VRAM during mode 3 and to OAM
+	// during modes 2 and 3, as on hardware. Some badly behaved homebrew
+	// only works with it turned off.
+	RestrictAccess bool
+
+	// Palettes are the colors the four shades of each palette register are drawn in
+	Palettes Palettes
+
+	// firstLine is set for the first line after the LCD is turned on, which skips OAM search
+	firstLine bool
+	// blankFrame is set for the first frame after the LCD is turned on, which is not drawn
+	blankFrame bool
+
+	dma dma // OAM DMA transfer
+
+	// Mode 3 pixel transfer
+	fetcher    *Fetcher
+	bgFifo     *Fifo
+	spriteFifo *Fifo
+	// lx is the number of pixels pushed to the LCD on the current line
+	lx int
+	// scrollDiscard is the number of pixels still to be discarded for SCX
+	scrollDiscard int
+
+	// Sprites on the current line that have not been fetched yet
+	pendingSprites  []Sprite
+	fetchingSprite  *Sprite
+	spriteFetchDots int
+	lastSpriteTile  int
+
+	// The window has its own line counter, which only advances on lines
+	// where the window was drawn, so hiding the window for a few lines
+	// carries on from the same window line when it is shown again
+	windowLine byte
+	// wyTriggered is set once LY has matched WY during the current frame
+	wyTriggered bool
+	// windowDrawn is set once the window has started on the current line
+	windowDrawn bool
+	// windowWrap is set when the window was started by WX=166 on the previous line
+	windowWrap bool
 }
 
+// Fetching a sprite line pauses the background fetcher for at least 6 dots
+const spriteFetchDots = 6
+
 type pixelAttributes struct {
 	colorIdentifier byte
 	palette         byte
@@ -184,7 +238,7 @@ func NewPPU(mmu *mmu.MMU) *PPU {
 	ppu := &PPU{
 		mmu:            mmu,
 		OAM:            oam,
-		VisibleSprites: make([]*Sprite, 10),
+		VisibleSprites: make([]Sprite, 0, 10),
 		FrameBuffer:    rectImage,
 		STAT: &stat{
 			coincidenceInterruptEnabled: false,
@@ -203,8 +257,16 @@ func NewPPU(mmu *mmu.MMU) *PPU {
 		OBP1: 0xFF,
 		WY:   0x00,
 		WX:   0x00,
+
+		bgFifo:     &Fifo{},
+		spriteFifo: &Fifo{},
+
+		RestrictAccess: true,
+		Palettes:       Presets[0],
 	}
 
+	ppu.fetcher = &Fetcher{memory: vram{ppu}}
+
 	ppu.setLCDCFields(0x91)
 
 	mmu.MapMemory(ppu, LCDC)
@@ -226,6 +288,9 @@ func NewPPU(mmu *mmu.MMU) *PPU {
 	// OAM RAM
 	mmu.MapMemoryRange(ppu, 0xFE00, 0xFE9F)
 
+	// OAM DMA can take the bus away from the CPU
+	mmu.SetBusMaster(ppu)
+
 	return ppu
 }
 
@@ -241,6 +306,10 @@ func (ppu *PPU) ReadByte(addr uint16) byte {
 		return ppu.SCX
 	case addr == LY:
 		return ppu.LY
+	case addr == LYC:
+		return ppu.LYC
+	case addr == DMA:
+		return ppu.DMA
 	case addr == BGP:
 		return ppu.BGP
 	case addr == OBP0:
@@ -272,22 +341,15 @@ func (ppu *PPU) ReadByte(addr uint16) byte {
 	case addr == OBPD:
 		return ppu.spritePaletteData[ppu.spritePaletteIndex]
 	case addr >= 0x8000 && addr <= 0x9FFF:
+		if !ppu.vramAccessible() {
+			return 0xFF
+		}
 		return ppu.VRAM[addr&0x1FFF]
 	case addr >= 0xFE00 && addr <= 0xFE9F:
-		oamAddr := addr & 0x9F
-		sprite := ppu.OAM[oamAddr/4] // 4 bits per sprite
-		spriteBit := oamAddr % 4
-
-		switch spriteBit {
-		case 0:
-			return sprite.Y
-		case 1:
-			return sprite.X
-		case 2:
-			return sprite.TileNumber
-		case 3:
-			return sprite.Attributes
+		if !ppu.oamAccessible() {
+			return 0xFF
 		}
+		return ppu.readOAM(addr)
 	}
 	return 0
 }
@@ -295,10 +357,17 @@ func (ppu *PPU) ReadByte(addr uint16) byte {
 func (ppu *PPU) WriteByte(addr uint16, value byte) {
 	switch {
 	case addr == LCDC:
+		lcdWasEnabled := ppu.lcdEnabled
 		ppu.LCDC = value
 		ppu.setLCDCFields(value)
+
+		if lcdWasEnabled && !ppu.lcdEnabled {
+			ppu.disableLCD()
+		} else if !lcdWasEnabled && ppu.lcdEnabled {
+			ppu.enableLCD()
+		}
 	case addr == STAT:
-		ppu.STAT.setStat(value)
+		ppu.writeSTAT(value)
 	case addr == SCY:
 		ppu.SCY = value
 	case addr == SCX:
@@ -309,16 +378,8 @@ func (ppu *PPU) WriteByte(addr uint16, value byte) {
 	case addr == LYC:
 		ppu.LYC = value
 	case addr == DMA:
-		// The value holds the source address of the OAM data divided by 100
-		// so we have to multiply it first
-		var sourceAddr uint16 = uint16(value) << 8
-		for _, sprite := range ppu.OAM {
-			sprite.Y = ppu.mmu.ReadByte(sourceAddr)
-			sprite.X = ppu.mmu.ReadByte(sourceAddr + 1)
-			sprite.TileNumber = ppu.mmu.ReadByte(sourceAddr + 2)
-			sprite.Attributes = ppu.mmu.ReadByte(sourceAddr + 3)
-			sourceAddr = sourceAddr + 4
-		}
+		ppu.DMA = value
+		ppu.dma.start(value)
 	case addr == BGP:
 		ppu.BGP = value
 	case addr == OBP0:
@@ -339,25 +400,77 @@ func (ppu *PPU) WriteByte(addr uint16, value byte) {
 	case addr == BGPD:
 		ppu.backgroundPaletteData[ppu.backgroundPaletteIndex] = value
 	case addr >= 0x8000 && addr <= 0x9FFF:
-		ppu.VRAM[addr&0x1FFF] = value
+		if ppu.vramAccessible() {
+			ppu.VRAM[addr&0x1FFF] = value
+		}
 	case addr >= 0xFE00 && addr <= 0xFE9F:
-		oamAddr := addr & 0x9F
-		sprite := ppu.OAM[oamAddr/4] // 4 bits per sprite
-		spriteBit := oamAddr % 4
-
-		switch spriteBit {
-		case 0:
-			sprite.Y = value
-		case 1:
-			sprite.X = value
-		case 2:
-			sprite.TileNumber = value
-		case 3:
-			sprite.Attributes = value
+		if ppu.oamAccessible() {
+			ppu.writeOAM(addr, value)
 		}
 	}
 }
 
+// vramAccessible returns true if the CPU can access VRAM, which
+// is locked while the PPU is reading it during pixel transfer
+func (ppu *PPU) vramAccessible() bool {
+	return !ppu.RestrictAccess || !ppu.lcdEnabled || ppu.STAT.mode != MODE3
+}
+
+// oamAccessible returns true if the CPU can access OAM, which is
+// locked while the PPU is reading it during OAM search and pixel transfer
+func (ppu *PPU) oamAccessible() bool {
+	return !ppu.RestrictAccess || !ppu.lcdEnabled || (ppu.STAT.mode != MODE2 && ppu.STAT.mode != MODE3)
+}
+
+func (ppu *PPU) readOAM(addr uint16) byte {
+	oamAddr := addr & 0xFF
+	sprite := ppu.OAM[oamAddr/4] // 4 bytes per sprite
+	spriteBit := oamAddr % 4
+
+	switch spriteBit {
+	case 0:
+		return sprite.Y
+	case 1:
+		return sprite.X
+	case 2:
+		return sprite.TileNumber
+	case 3:
+		return sprite.Attributes
+	}
+	return 0
+}
+
+func (ppu *PPU) writeOAM(addr uint16, value byte) {
+	oamAddr := addr & 0xFF
+	sprite := ppu.OAM[oamAddr/4] // 4 bytes per sprite
+	spriteBit := oamAddr % 4
+
+	switch spriteBit {
+	case 0:
+		sprite.Y = value
+	case 1:
+		sprite.X = value
+	case 2:
+		sprite.TileNumber = value
+	case 3:
+		sprite.Attributes = value
+	}
+}
+
+// vram gives the PPU's fetcher direct access to video memory
+// without going through the CPU bus.
+type vram struct {
+	ppu *PPU
+}
+
+func (v vram) ReadByte(addr uint16) byte {
+	return v.ppu.VRAM[addr&0x1FFF]
+}
+
+func (v vram) WriteByte(addr uint16, value byte) {
+	v.ppu.VRAM[addr&0x1FFF] = value
+}
+
 // setLCDCFields takes a byte written to LCDC
 // and extracts the attributes to set fields on the GPU Struct
 func (ppu *PPU) setLCDCFields(value byte) {
@@ -403,114 +516,181 @@ func (ppu *PPU) setLCDCFields(value byte) {
 }
 
 func (ppu *PPU) Step(cycles int) {
+	// DMA runs whether or not the LCD is enabled
+	ppu.stepDMA(cycles / 4)
+
+	// While the LCD is off the PPU is stopped
 	if ppu.lcdEnabled {
-		ppu.Cycles += cycles
+		// The PPU advances one dot per cycle
+		for i := 0; i < cycles; i++ {
+			ppu.tick()
+		}
+	}
+}
 
-		// STAT indicates the current status of the LCD controller.
-		switch ppu.STAT.mode {
-		// HBlank
-		// After the last HBlank, push the screen data to canvas
-		case MODE0:
-			if ppu.Cycles >= 204 {
-				// Reset the cycle counter
-				ppu.Cycles = 0
+// disableLCD stops the PPU at the start of line 0 and blanks the screen
+func (ppu *PPU) disableLCD() {
+	ppu.LY = 0
+	ppu.Cycles = 0
+	ppu.STAT.mode = MODE0
+	ppu.STAT.coincidenceFlag = ppu.LY == ppu.LYC
+	ppu.statLine = false
+
+	white := ppu.Palettes.BG[0]
+	draw.Draw(ppu.FrameBuffer, ppu.FrameBuffer.Bounds(), &image.Uniform{white}, image.ZP, draw.Src)
+	ppu.ColorIDs = [144][160]byte{}
+}
 
-				// Increase the scanline
-				ppu.LY++
+// enableLCD restarts the PPU from line 0. The first line after the LCD is
+// turned on skips OAM search, staying in mode 0 until pixel transfer starts,
+// and nothing is drawn until the second frame.
+func (ppu *PPU) enableLCD() {
+	ppu.LY = 0
+	ppu.Cycles = 0
+	ppu.STAT.mode = MODE0
+	ppu.firstLine = true
+	ppu.blankFrame = true
+	ppu.updateSTATLine(ppu.STAT)
+}
 
-				// 143 is the last line, update the screen and enter VBlank
-				if ppu.LY == 144 {
-					// Request VBLANK interrupt
-					ppu.mmu.RequestInterrupt(VBLANK_INTERRUPT)
-
-					// Enter GPU Mode 1/VBlank
-					ppu.STAT.mode = MODE1
-					if ppu.STAT.vblankInterruptEnabled {
-						ppu.mmu.RequestInterrupt(LCDC_INTERRUPT)
-					}
-				} else {
-					// Enter GPU Mode 2/OAM Access
-					ppu.STAT.mode = MODE2
-					if ppu.STAT.oamInterruptEnabled {
-						ppu.mmu.RequestInterrupt(LCDC_INTERRUPT)
-					}
-				}
+func (ppu *PPU) tick() {
+	ppu.Cycles++
+
+	// STAT indicates the current status of the LCD controller.
+	switch ppu.STAT.mode {
+	// HBlank
+	// After the last HBlank, push the screen data to canvas
+	case MODE0:
+		if ppu.firstLine && ppu.Cycles >= 80 {
+			ppu.firstLine = false
+			ppu.OAMSearch()
+			ppu.STAT.mode = MODE3
+			ppu.startPixelTransfer()
+		} else if ppu.Cycles >= 456 {
+			// Reset the cycle counter
+			ppu.Cycles = 0
+
+			// Increase the scanline
+			ppu.LY++
+
+			// 143 is the last line, update the screen and enter VBlank
+			if ppu.LY == 144 {
+				// Request VBLANK interrupt
+				ppu.mmu.RequestInterrupt(VBLANK_INTERRUPT)
+
+				// Enter GPU Mode 1/VBlank
+				ppu.STAT.mode = MODE1
+				ppu.FrameCount++
+
+				// The next frame is drawn normally
+				ppu.blankFrame = false
+			} else {
+				// Enter GPU Mode 2/OAM Access
+				ppu.STAT.mode = MODE2
 			}
+		}
 
-		// VBlank
-		// After 10 lines, restart scanline and draw the next frame
-		case MODE1:
-			if ppu.Cycles >= 456 {
-				// Reset the cycle counter
-				ppu.Cycles = 0
+	// VBlank
+	// After 10 lines, restart scanline and draw the next frame
+	case MODE1:
+		// LY only reads 153 for the first few dots of the last
+		// VBlank line, for the rest of the line it is already 0
+		if ppu.LY == 153 && ppu.Cycles == 4 {
+			ppu.LY = 0
+		}
+
+		if ppu.Cycles >= 456 {
+			// Reset the cycle counter
+			ppu.Cycles = 0
 
+			if ppu.LY == 0 {
+				// We have done 10 lines of VBlank, start the next frame
+				// Enter GPU Mode 2/OAM Access
+				ppu.STAT.mode = MODE2
+			} else {
 				// Increase the scanline
 				ppu.LY++
-
-				// If Scanline is 153 we have done 10 lines of VBlank
-				if ppu.LY > 153 {
-					// Start of next Frame
-					// Enter GPU Mode 2/OAM Access
-					ppu.STAT.mode = MODE2
-					if ppu.STAT.oamInterruptEnabled {
-						ppu.mmu.RequestInterrupt(LCDC_INTERRUPT)
-					}
-
-					// Reset the Scanline
-					ppu.LY = 0
-				}
 			}
+		}
 
-		// OAM access mode, scanline active
-		case MODE2:
-			if ppu.Cycles >= 80 {
-				// Do OAMSearch
-				ppu.OAMSearch()
-				// Reset the cycle counter
-				ppu.Cycles = 0
-				// Enter GPU Mode 3
-				ppu.STAT.mode = MODE3
-			}
+	// OAM access mode, scanline active
+	case MODE2:
+		if ppu.Cycles >= 80 {
+			// Do OAMSearch
+			ppu.OAMSearch()
+			// Enter GPU Mode 3
+			ppu.STAT.mode = MODE3
+			ppu.startPixelTransfer()
+		}
 
-		// VRAM access mode, scanline active
-		// Treat end of mode 3 as end of scanline
-		case MODE3:
-			if ppu.Cycles >= 172 {
-				// Reset the cycle counter
-				ppu.Cycles = 0
-
-				// Enter GPU Mode 0/HBlank
-				ppu.STAT.mode = MODE0
-				if ppu.STAT.hblankInterruptEnabled {
-					ppu.mmu.RequestInterrupt(LCDC_INTERRUPT)
-				}
+	// VRAM access mode, scanline active
+	// Mode 3 lasts until all 160 pixels of the scanline have been
+	// pushed to the LCD, its length depends on SCX, sprites and the window
+	case MODE3:
+		ppu.tickPixelTransfer()
 
-				// Write a scanline to the framebuffer
-				ppu.renderScanline()
-			}
+		if ppu.lx == 160 {
+			ppu.endPixelTransfer()
+
+			// Enter GPU Mode 0/HBlank
+			ppu.STAT.mode = MODE0
 		}
+	}
 
-		// If LY == LYC then set the STAT match flag and perform
-		// the match flag interrupt if it has been requested
-		if ppu.LY == ppu.LYC {
-			ppu.STAT.coincidenceFlag = true
+	ppu.updateSTATLine(ppu.STAT)
+}
 
-			if ppu.STAT.coincidenceInterruptEnabled {
-				ppu.mmu.RequestInterrupt(LCDC_INTERRUPT)
-			}
-		} else {
-			ppu.STAT.coincidenceFlag = false
+// updateSTATLine updates the LY=LYC coincidence flag and the internal STAT
+// interrupt line. The line is the OR of every enabled interrupt source and
+// the LCDC interrupt is only requested when it goes from low to high, so
+// while one source holds the line high no other source can raise another
+// interrupt.
+func (ppu *PPU) updateSTATLine(s *stat) {
+	ppu.STAT.coincidenceFlag = ppu.LY == ppu.LYC
+
+	line := s.coincidenceInterruptEnabled && ppu.STAT.coincidenceFlag
+
+	switch ppu.STAT.mode {
+	case MODE0:
+		line = line || s.hblankInterruptEnabled
+	case MODE1:
+		line = line || s.vblankInterruptEnabled
+		// The OAM source is also checked as line 144 starts, even though
+		// the PPU goes straight to VBlank instead of mode 2
+		if ppu.LY == 144 && ppu.Cycles == 0 {
+			line = line || s.oamInterruptEnabled
 		}
+	case MODE2:
+		line = line || s.oamInterruptEnabled
+	}
 
-	} else {
-		ppu.Cycles = 456
-		ppu.LY = 0
-		ppu.STAT.mode = MODE0
+	if line && !ppu.statLine {
+		ppu.mmu.RequestInterrupt(LCDC_INTERRUPT)
+	}
+	ppu.statLine = line
+}
+
+// writeSTAT sets the STAT interrupt sources. On the DMG every source is
+// enabled for one cycle during the write, so writing to STAT during HBlank,
+// VBlank or while LY=LYC raises the STAT interrupt whatever value is written.
+func (ppu *PPU) writeSTAT(value byte) {
+	if ppu.lcdEnabled {
+		ppu.updateSTATLine(&stat{
+			coincidenceInterruptEnabled: true,
+			vblankInterruptEnabled:      true,
+			hblankInterruptEnabled:      true,
+		})
+	}
+
+	ppu.STAT.setStat(value)
+
+	if ppu.lcdEnabled {
+		ppu.updateSTATLine(ppu.STAT)
 	}
 }
 
 func (ppu *PPU) OAMSearch() {
-	visibleSprites := make([]*Sprite, 0)
+	visibleSprites := make([]Sprite, 0, 10)
 
 	for _, sprite := range ppu.OAM {
 		// Only 10 sprites can be displayed on any one line. When this limit is exceeded,
@@ -519,8 +699,9 @@ func (ppu *PPU) OAMSearch() {
 			break
 		}
 
-		if sprite.X != 0 && ((ppu.LY + 16) >= sprite.Y) && ((ppu.LY + 16) < (sprite.Y + ppu.spriteSize)) {
-			visibleSprites = append(visibleSprites, sprite)
+		// Sprites off the left or right of the screen still count towards the limit
+		if line := int(ppu.LY) + 16 - int(sprite.Y); line >= 0 && line < int(ppu.spriteSize) {
+			visibleSprites = append(visibleSprites, *sprite)
 		}
 	}
 
@@ -528,91 +709,254 @@ func (ppu *PPU) OAMSearch() {
 	// the one with the smaller x coordinate (closer to the left)
 	// will have priority and appear above any others.
 	// This applies in Non CGB Mode only.
-	sort.Slice(visibleSprites, func(i, j int) bool {
-		return visibleSprites[i].X < visibleSprites[j].X
-	})
-
 	// When sprites with the same x coordinate values overlap,
 	// they have priority according to table ordering. (i.e. $FE00 - highest, $FE04 - next highest, etc.)
-	// In CGB Mode priorities are always assigned like this.
+	// so the sort must be stable to keep them in OAM order.
+	// In CGB Mode priorities are always assigned by table ordering.
 	// TODO sprite ordering for CGB
+	sort.SliceStable(visibleSprites, func(i, j int) bool {
+		return visibleSprites[i].X < visibleSprites[j].X
+	})
 
 	ppu.VisibleSprites = visibleSprites
 }
 
-func (ppu *PPU) renderScanline() {
-	if ppu.backgroundEnabled {
+// startPixelTransfer resets the fetcher and FIFOs at the start of mode 3
+func (ppu *PPU) startPixelTransfer() {
+	ppu.lx = 0
+	ppu.bgFifo.Clear()
+	ppu.spriteFifo.Clear()
+	ppu.fetcher.Reset()
 
-		fifo := &Fifo{}
-		spriteFifo := &Fifo{}
+	// The first SCX % 8 pixels of the line are fetched but discarded
+	ppu.scrollDiscard = int(ppu.SCX % 8)
 
-		fetcher := &Fetcher{
-			tileMapAddress:  ppu.backgroundMapLocation,
-			tileDataAddress: ppu.tileDataLocation,
-			SCY:             ppu.SCY,
-			LY:              ppu.LY,
-			memory:          ppu.mmu,
-		}
+	ppu.pendingSprites = append(ppu.pendingSprites[:0], ppu.VisibleSprites...)
+	ppu.fetchingSprite = nil
+	ppu.lastSpriteTile = -1
 
-		x := -8
-		fifo.PushDots(fetcher.FirstTileLine())
-		pushedDots := 0
-		for pushedDots <= 159 {
-			if fifo.Length() <= 8 {
-				fifo.PushDots(fetcher.NextTileLine())
-			}
+	// The window line counter and the WY condition are reset every frame
+	if ppu.LY == 0 {
+		ppu.windowLine = 0
+		ppu.wyTriggered = false
+	}
+	// Once LY has matched WY the window can be shown
+	// on every remaining line of the frame
+	if ppu.LY == ppu.WY {
+		ppu.wyTriggered = true
+	}
+	ppu.windowDrawn = false
+}
 
-			for _, sprite := range ppu.VisibleSprites {
-				if sprite != nil && int(sprite.X)+int(ppu.SCX) == x+8 {
-					spriteFifo.PushDots(fetcher.fetchSpriteLine(sprite))
-				}
-			}
+// windowTriggered returns true if the fetcher should switch to
+// the window before the next pixel is pushed to the LCD
+func (ppu *PPU) windowTriggered() bool {
+	if !ppu.windowEnabled || !ppu.wyTriggered || ppu.fetcher.window || ppu.windowDrawn {
+		return false
+	}
 
-			if ppu.windowEnabled {
-				if ppu.WY <= ppu.LY {
-					if ppu.WX == byte(x) {
-						// TODO: Should we spriteFifo.Clear() here?
-						fifo.Clear()
-						fetcher.tileMapAddress = ppu.windowMapLocation
-						fifo.PushDots(fetcher.NextTileLine())
-					}
+	// WX=166 shows the window from the first pixel of the next line
+	if ppu.windowWrap && ppu.lx == 0 {
+		return true
+	}
+
+	// With WX below 7 the window starts before any pixels are
+	// discarded for SCX, otherwise it starts when WX matches the
+	// X position of the next pixel
+	if ppu.WX < 7 {
+		return ppu.lx == 0
+	}
+	return ppu.scrollDiscard == 0 && ppu.lx+7 == int(ppu.WX)
+}
+
+// endPixelTransfer finishes mode 3 once all 160 pixels have been pushed
+func (ppu *PPU) endPixelTransfer() {
+	// The window line counter only advances on lines where the window was drawn
+	if ppu.windowDrawn {
+		ppu.windowLine++
+	}
+
+	ppu.windowWrap = ppu.windowDrawn && ppu.WX == 166
+}
+
+// tickPixelTransfer advances mode 3 by one dot
+func (ppu *PPU) tickPixelTransfer() {
+	ppu.updateFetcher()
+
+	if ppu.fetchingSprite == nil && ppu.scrollDiscard == 0 && ppu.spriteEnabled {
+		if sprite := ppu.nextSprite(); sprite != nil {
+			ppu.fetchingSprite = sprite
+			ppu.spriteFetchDots = spriteFetchDots
+
+			// The first sprite in each background tile also has to wait for the
+			// background fetcher to finish fetching that tile, which takes up to
+			// 5 more dots depending on how far through the tile the sprite is
+			tileX := ppu.lx + int(ppu.SCX)
+			if ppu.fetcher.window {
+				tileX = ppu.lx - (int(ppu.WX) - 7)
+			}
+			if tile := tileX / 8; tile != ppu.lastSpriteTile {
+				ppu.lastSpriteTile = tile
+				if wait := 5 - tileX%8; wait > 0 {
+					ppu.spriteFetchDots += wait
 				}
 			}
+		}
+	}
 
-			dot := fifo.PopDot()
-			palette := ppu.BGP
-
-			if spriteFifo.Length() > 0 {
-				spriteDot := spriteFifo.PopDot()
-
-				// If there is a sprite at this position in the scanline
-				// and the sprite priority is 0 or the background dots
-				// colorIdentifier is 0, then the sprite is rendered on top
-				// of the background, otherwise the background is rendered.
-				if spriteDot.ColorIdentifier != 0 && (spriteDot.Priority == 0 || dot.ColorIdentifier == 0) {
-					dot = spriteDot
-					if dot.Palette == 0 {
-						palette = ppu.OBP0
-					} else {
-						palette = ppu.OBP1
-					}
-				}
+	// While a sprite is being fetched the background fetcher
+	// is paused and no pixels are pushed to the LCD
+	if ppu.fetchingSprite != nil {
+		ppu.spriteFetchDots--
+		if ppu.spriteFetchDots == 0 {
+			// Sprites partly off the left edge of the screen
+			// lose the pixels that would be drawn before X=0
+			line := ppu.fetcher.fetchSpriteLine(ppu.fetchingSprite, ppu.LY)
+			offset := ppu.lx + 8 - int(ppu.fetchingSprite.X)
+			if offset < 0 {
+				offset = 0
+			} else if offset > 8 {
+				offset = 8
 			}
+			ppu.spriteFifo.MixDots(line[offset:])
+			ppu.fetchingSprite = nil
+		}
+		return
+	}
 
-			if int(ppu.SCX) <= x {
-				ppu.FrameBuffer.SetRGBA(pushedDots, int(ppu.LY), dot.ToRGBA(palette))
-				pushedDots++
+	// Disabling the window part way through a line switches the
+	// fetcher back to the background tiles at the current position
+	if ppu.fetcher.window && !ppu.windowEnabled {
+		x := ppu.lx + int(ppu.SCX) + ppu.bgFifo.Length()
+		ppu.fetcher.StopWindow(byte(x/8 - int(ppu.SCX/8)))
+		ppu.updateFetcher()
+	}
 
-				// Background wraps around the screen (i.e. when part of it goes off the screen, it appears on the opposite side.)
-				// So if pushed dots and background x start position == 256 then we reset the fetcher tileMapAddress
-				// as the background wraps round
-				if int(ppu.SCX)+pushedDots == 256 {
-					fetcher.tileMapAddress = ppu.backgroundMapLocation
-					fifo.Clear()
-				}
+	ppu.fetcher.Tick(ppu.bgFifo)
+
+	if ppu.bgFifo.Length() == 0 {
+		return
+	}
+
+	if ppu.windowTriggered() {
+		// Switching to the window clears the background FIFO
+		// and restarts the fetcher at the first window tile
+		ppu.bgFifo.Clear()
+		ppu.fetcher.StartWindow()
+		ppu.updateFetcher()
+		ppu.fetcher.Tick(ppu.bgFifo)
+		ppu.windowDrawn = true
+
+		// With WX below 7 the window starts off the left edge of the
+		// screen and its first 7-WX pixels are thrown away
+		ppu.scrollDiscard = 0
+		if ppu.WX < 7 && !ppu.windowWrap {
+			ppu.scrollDiscard = 7 - int(ppu.WX)
+		}
+		return
+	}
+
+	dot := ppu.bgFifo.PopDot()
+
+	if ppu.scrollDiscard > 0 {
+		ppu.scrollDiscard--
+		return
+	}
+
+	ppu.pushDot(dot)
+}
+
+// updateFetcher passes the current register values to the fetcher
+func (ppu *PPU) updateFetcher() {
+	f := ppu.fetcher
+	f.tileDataAddress = ppu.tileDataLocation
+	f.spriteSize = ppu.spriteSize
+
+	if f.window {
+		f.mapAddress = ppu.windowMapLocation
+		f.SCX = 0
+		f.SCY = 0
+		f.LY = ppu.windowLine
+	} else {
+		f.mapAddress = ppu.backgroundMapLocation
+		f.SCX = ppu.SCX
+		f.SCY = ppu.SCY
+		f.LY = ppu.LY
+	}
+}
+
+// nextSprite returns the next sprite to be fetched at the current X position. Sprites
+// the line has already passed, such as when sprites are enabled part way through
+// the line, are dropped without being fetched.
+func (ppu *PPU) nextSprite() *Sprite {
+	for len(ppu.pendingSprites) > 0 {
+		sprite := ppu.pendingSprites[0]
+		switch x := int(sprite.X); {
+		case x > ppu.lx+8:
+			// The sprites are sorted by X, so none of the others have been reached either
+			return nil
+		case x < ppu.lx+8 && ppu.lx > 0:
+			ppu.pendingSprites = ppu.pendingSprites[1:]
+		default:
+			// Sprites with X below 8 are partly off the left edge
+			// of the screen and are all fetched at the first pixel
+			ppu.pendingSprites = ppu.pendingSprites[1:]
+			return &sprite
+		}
+	}
+	return nil
+}
+
+// pushDot mixes a background dot with the sprite FIFO and
+// writes the result to the frame buffer
+func (ppu *PPU) pushDot(dot *Dot) {
+	register, palette := ppu.BGP, ppu.Palettes.BG
+
+	// With the background disabled it is drawn as color 0
+	if !ppu.backgroundEnabled {
+		dot = &Dot{Type: BG}
+	}
+
+	if ppu.spriteFifo.Length() > 0 {
+		spriteDot := ppu.spriteFifo.PopDot()
+
+		// If there is a sprite at this position in the scanline
+		// and the sprite priority is 0 or the background dots
+		// colorIdentifier is 0, then the sprite is rendered on top
+		// of the background, otherwise the background is rendered.
+		if spriteDot.ColorIdentifier != 0 && (spriteDot.Priority == 0 || dot.ColorIdentifier == 0) {
+			dot = spriteDot
+			if dot.Palette == 0 {
+				register, palette = ppu.OBP0, ppu.Palettes.OBP0
+			} else {
+				register, palette = ppu.OBP1, ppu.Palettes.OBP1
 			}
+		}
+	}
+
+	if !ppu.blankFrame {
+		ppu.FrameBuffer.SetRGBA(ppu.lx, int(ppu.LY), dot.ToRGBA(register, palette))
+		ppu.ColorIDs[ppu.LY][ppu.lx] = dot.ColorIdentifier
+	}
+	ppu.lx++
+}
+
+// IndexedFrame returns the current frame as an image of the 2-bit color identifier of
+// each pixel, before the palette registers are applied. Its 4 color palette is the
+// colors the identifiers are drawn in by BGP with the current background palette, so
+// sprite pixels have the right identifier but may not have the color they were drawn in.
+func (ppu *PPU) IndexedFrame() *image.Paletted {
+	palette := make(color.Palette, 4)
+	for id := range palette {
+		dot := Dot{ColorIdentifier: byte(id)}
+		palette[id] = dot.ToRGBA(ppu.BGP, ppu.Palettes.BG)
+	}
 
-			x++
+	frame := image.NewPaletted(ppu.FrameBuffer.Bounds(), palette)
+	for y, line := range ppu.ColorIDs {
+		for x, id := range line {
+			frame.SetColorIndex(x, y, id)
 		}
 	}
+	return frame
 }
@@ -0,0 +1,502 @@
+package ppu
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+// newTestPPU returns a PPU with the LCD enabled at the start of line 0
+func newTestPPU() *PPU {
+	ppu := NewPPU(mmu.NewMMU())
+	ppu.STAT.mode = MODE2
+	ppu.Cycles = 0
+	ppu.LY = 0
+	return ppu
+}
+
+// mode3Length steps the PPU through a scanline and returns the number of dots spent in mode 3
+func mode3Length(ppu *PPU) int {
+	dots := 0
+	for i := 0; i < 456; i++ {
+		ppu.Step(1)
+		if ppu.STAT.mode == MODE3 {
+			dots++
+		}
+	}
+	return dots
+}
+
+func TestMode3Length(t *testing.T) {
+	cases := []struct {
+		Name     string
+		SCX      byte
+		Sprites  []Sprite
+		WX       byte
+		Window   bool
+		Expected int
+	}{
+		{"Minimum length", 0, nil, 0, false, 172},
+		{"SCX fine scroll", 3, nil, 0, false, 175},
+		{"SCX coarse scroll has no penalty", 8, nil, 0, false, 172},
+		{"Sprite at start of tile", 0, []Sprite{{Y: 16, X: 8}}, 0, false, 183},
+		{"Sprite part way through tile", 0, []Sprite{{Y: 16, X: 12}}, 0, false, 179},
+		{"Two sprites in the same tile", 0, []Sprite{{Y: 16, X: 8}, {Y: 16, X: 8}}, 0, false, 189},
+		{"Window", 0, nil, 7, true, 178},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu := newTestPPU()
+			ppu.SCX = tt.SCX
+			ppu.WX = tt.WX
+			if tt.Window {
+				ppu.WriteByte(LCDC, ppu.LCDC|0x20)
+			}
+			for i := range tt.Sprites {
+				*ppu.OAM[i] = tt.Sprites[i]
+			}
+			ppu.WriteByte(LCDC, ppu.LCDC|0x02)
+
+			if length := mode3Length(ppu); length != tt.Expected {
+				t.Errorf("mode 3 should have lasted %v dots but lasted %v", tt.Expected, length)
+			}
+		})
+	}
+}
+
+func TestMidScanlineRegisterWrites(t *testing.T) {
+	ppu := newTestPPU()
+
+	// Tile 1 is solid color 3, tile 0 is color 0
+	for i := 0; i < 16; i++ {
+		ppu.VRAM[0x10+i] = 0xFF
+	}
+	ppu.WriteByte(LCDC, 0x91)
+	ppu.BGP = 0xE4
+
+	// Run mode 2 and the first 80 pixels of mode 3
+	for ppu.STAT.mode != MODE3 || ppu.lx < 80 {
+		ppu.Step(1)
+	}
+
+	// Changing the tile map part way through the line only affects the pixels that follow
+	for i := 0x1C00; i < 0x1C20; i++ {
+		ppu.VRAM[i] = 0x01
+	}
+	ppu.WriteByte(LCDC, 0x99)
+
+	for ppu.STAT.mode == MODE3 {
+		ppu.Step(1)
+	}
+
+	if ppu.FrameBuffer.RGBAAt(0, 0) == ppu.FrameBuffer.RGBAAt(159, 0) {
+		t.Errorf("a mid scanline LCDC write should change the pixels that are drawn after it")
+	}
+	if ppu.FrameBuffer.RGBAAt(0, 0) != ppu.FrameBuffer.RGBAAt(79, 0) {
+		t.Errorf("a mid scanline LCDC write should not change pixels already drawn")
+	}
+}
+
+const dotsPerFrame = 70224
+
+// newSTATTestPPU returns a PPU at the start of line 0 with IF mapped to RAM
+func newSTATTestPPU() (*PPU, *testRAM) {
+	m := mmu.NewMMU()
+	ram := &testRAM{}
+	m.MapMemory(ram, 0xFF0F)
+
+	ppu := NewPPU(m)
+	ppu.STAT.mode = MODE2
+	ppu.Cycles = 0
+	ppu.LY = 0
+	return ppu, ram
+}
+
+// countSTATInterrupts steps the PPU one dot at a time and returns the number
+// of times the LCDC interrupt was requested
+func countSTATInterrupts(ppu *PPU, ram *testRAM, dots int) int {
+	count := 0
+	for i := 0; i < dots; i++ {
+		ppu.Step(1)
+		if ram.data[0xFF0F]&(1<<LCDC_INTERRUPT) != 0 {
+			ram.data[0xFF0F] = 0
+			count++
+		}
+	}
+	return count
+}
+
+func TestSTATInterruptLine(t *testing.T) {
+	cases := []struct {
+		Name     string
+		STAT     byte
+		LYC      byte
+		Expected int
+	}{
+		{"LY=LYC fires once per frame", 0x40, 10, 1},
+		{"HBlank fires once per line", 0x08, 0xFF, 144},
+		{"LY=LYC blocks the following HBlank", 0x48, 10, 143},
+		{"VBlank", 0x10, 0xFF, 1},
+		{"OAM is also checked at the start of line 144", 0x20, 0xFF, 145},
+		{"VBlank blocks OAM on line 0", 0x30, 0xFF, 144},
+		{"LY=0 matches during line 153", 0x40, 0, 1},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu, ram := newSTATTestPPU()
+			ppu.STAT.setStat(tt.STAT)
+			ppu.LYC = tt.LYC
+
+			// Skip the first frame so the line starts in the state it was left by the previous frame
+			countSTATInterrupts(ppu, ram, dotsPerFrame)
+
+			if count := countSTATInterrupts(ppu, ram, dotsPerFrame); count != tt.Expected {
+				t.Errorf("STAT interrupt should have been requested %v times but was requested %v times", tt.Expected, count)
+			}
+		})
+	}
+}
+
+func TestLine153(t *testing.T) {
+	ppu, _ := newSTATTestPPU()
+
+	for !(ppu.LY == 153 && ppu.Cycles == 0) {
+		ppu.Step(1)
+	}
+
+	ppu.Step(4)
+	if ppu.LY != 0 {
+		t.Errorf("LY should have been 0 after 4 dots of line 153 but was %v", ppu.LY)
+	}
+	if ppu.STAT.mode != MODE1 {
+		t.Errorf("mode should have been %v for the rest of line 153 but was %v", MODE1, ppu.STAT.mode)
+	}
+
+	ppu.Step(452)
+	if ppu.LY != 0 || ppu.STAT.mode != MODE2 {
+		t.Errorf("the next frame should have started on line 0 in mode %v but was on line %v in mode %v", MODE2, ppu.LY, ppu.STAT.mode)
+	}
+}
+
+func TestSTATWriteBug(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Mode     byte
+		LYC      byte
+		Expected bool
+	}{
+		{"HBlank", MODE0, 0xFF, true},
+		{"VBlank", MODE1, 0xFF, true},
+		{"OAM scan", MODE2, 0xFF, false},
+		{"Pixel transfer", MODE3, 0xFF, false},
+		{"LY=LYC", MODE3, 0, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu, ram := newSTATTestPPU()
+			ppu.LYC = tt.LYC
+			for ppu.STAT.mode != tt.Mode {
+				ppu.Step(1)
+			}
+			ram.data[0xFF0F] = 0
+
+			ppu.WriteByte(STAT, 0x00)
+
+			requested := ram.data[0xFF0F]&(1<<LCDC_INTERRUPT) != 0
+			if requested != tt.Expected {
+				t.Errorf("STAT interrupt requested should have been %v but was %v", tt.Expected, requested)
+			}
+		})
+	}
+}
+
+// newWindowTestPPU returns a PPU with a blank background and a window made from a
+// tile whose left half is color 0 and right half is color 3
+func newWindowTestPPU(wx, wy byte) *PPU {
+	ppu := newTestPPU()
+	for i := 0; i < 16; i++ {
+		ppu.VRAM[0x10+i] = 0x0F
+	}
+	for i := 0x1C00; i < 0x2000; i++ {
+		ppu.VRAM[i] = 0x01
+	}
+	ppu.BGP = 0xE4
+	ppu.WX = wx
+	ppu.WY = wy
+	ppu.WriteByte(LCDC, 0xF1)
+	return ppu
+}
+
+// stepToLine steps the PPU until the start of mode 3 on line ly
+func stepToLine(ppu *PPU, ly byte) {
+	for !(ppu.LY == ly && ppu.STAT.mode == MODE3) {
+		ppu.Step(1)
+	}
+}
+
+func TestWindowPosition(t *testing.T) {
+	type pixel struct {
+		X, Y int
+		Dark bool
+	}
+	cases := []struct {
+		Name     string
+		WX       byte
+		WY       byte
+		Expected []pixel
+	}{
+		{"WX=7 starts at the left edge", 7, 0, []pixel{{0, 0, false}, {4, 0, true}, {12, 0, true}}},
+		{"WX=87 starts in the middle", 87, 0, []pixel{{76, 0, false}, {80, 0, false}, {84, 0, true}}},
+		{"WX below 7 discards the first window pixels", 3, 0, []pixel{{0, 0, true}, {3, 0, true}, {4, 0, false}}},
+		{"WY delays the window", 7, 2, []pixel{{4, 1, false}, {4, 2, true}}},
+		{"WX=166 only shows the last pixel", 166, 0, []pixel{{159, 0, false}, {4, 0, false}}},
+		{"WX=166 shows the window on the next line", 166, 0, []pixel{{0, 1, false}, {4, 1, true}}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu := newWindowTestPPU(tt.WX, tt.WY)
+			stepToLine(ppu, 4)
+
+			for _, p := range tt.Expected {
+				if dark := ppu.FrameBuffer.RGBAAt(p.X, p.Y).R < 0x80; dark != p.Dark {
+					t.Errorf("pixel (%v, %v) dark should have been %v but was %v", p.X, p.Y, p.Dark, dark)
+				}
+			}
+		})
+	}
+}
+
+func TestWindowLineCounter(t *testing.T) {
+	ppu := newWindowTestPPU(7, 0)
+
+	// Hide the window on lines 4-9
+	for ly := byte(0); ly < 10; ly++ {
+		stepToLine(ppu, ly)
+		if ly == 4 {
+			ppu.WriteByte(LCDC, 0xD1)
+		}
+	}
+	stepToLine(ppu, 10)
+	ppu.WriteByte(LCDC, 0xF1)
+	stepToLine(ppu, 11)
+
+	if ppu.windowLine != 5 {
+		t.Errorf("window line should have been 5 but was %v", ppu.windowLine)
+	}
+
+	// The counter is reset at the start of the next frame
+	for ppu.LY != 0 || ppu.STAT.mode == MODE1 {
+		ppu.Step(1)
+	}
+	stepToLine(ppu, 0)
+	if ppu.windowLine != 0 {
+		t.Errorf("window line should have been reset to 0 but was %v", ppu.windowLine)
+	}
+}
+
+func TestAccessRestrictions(t *testing.T) {
+	cases := []struct {
+		Name           string
+		Mode           byte
+		RestrictAccess bool
+		VRAM           bool
+		OAM            bool
+	}{
+		{"HBlank", MODE0, true, true, true},
+		{"VBlank", MODE1, true, true, true},
+		{"OAM search", MODE2, true, true, false},
+		{"Pixel transfer", MODE3, true, false, false},
+		{"Pixel transfer unrestricted", MODE3, false, true, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu := newTestPPU()
+			ppu.RestrictAccess = tt.RestrictAccess
+			ppu.VRAM[0] = 0x12
+			ppu.OAM[0].Y = 0x34
+			for ppu.STAT.mode != tt.Mode {
+				ppu.Step(1)
+			}
+
+			if vram := ppu.ReadByte(0x8000) == 0x12; vram != tt.VRAM {
+				t.Errorf("VRAM readable should have been %v but was %v", tt.VRAM, vram)
+			}
+			if oam := ppu.ReadByte(0xFE00) == 0x34; oam != tt.OAM {
+				t.Errorf("OAM readable should have been %v but was %v", tt.OAM, oam)
+			}
+
+			ppu.WriteByte(0x8000, 0x56)
+			ppu.WriteByte(0xFE00, 0x78)
+			if vram := ppu.VRAM[0] == 0x56; vram != tt.VRAM {
+				t.Errorf("VRAM writable should have been %v but was %v", tt.VRAM, vram)
+			}
+			if oam := ppu.OAM[0].Y == 0x78; oam != tt.OAM {
+				t.Errorf("OAM writable should have been %v but was %v", tt.OAM, oam)
+			}
+		})
+	}
+}
+
+func TestLCDDisable(t *testing.T) {
+	ppu := newTestPPU()
+	stepToLine(ppu, 10)
+
+	ppu.WriteByte(LCDC, 0x11)
+	ppu.Step(1000)
+
+	if ppu.LY != 0 || ppu.Cycles != 0 || ppu.STAT.mode != MODE0 {
+		t.Errorf("the LCD should have stopped on line 0 in mode 0 but was on line %v in mode %v after %v dots", ppu.LY, ppu.STAT.mode, ppu.Cycles)
+	}
+	if white := ppu.Palettes.BG[0]; ppu.FrameBuffer.RGBAAt(80, 72) != white {
+		t.Errorf("the screen should have been cleared to %v but was %v", white, ppu.FrameBuffer.RGBAAt(80, 72))
+	}
+}
+
+func TestLCDEnable(t *testing.T) {
+	ppu := newTestPPU()
+	ppu.BGP = 0xFF
+	ppu.WriteByte(LCDC, 0x11)
+	ppu.WriteByte(LCDC, 0x91)
+
+	// The first line stays in mode 0 instead of doing OAM search
+	ppu.Step(79)
+	if ppu.STAT.mode != MODE0 {
+		t.Errorf("mode should have been %v for the first 80 dots but was %v", MODE0, ppu.STAT.mode)
+	}
+	ppu.Step(1)
+	if ppu.STAT.mode != MODE3 {
+		t.Errorf("mode should have been %v after 80 dots but was %v", MODE3, ppu.STAT.mode)
+	}
+
+	// The first frame is not drawn
+	white := ppu.Palettes.BG[0]
+	ppu.Step(dotsPerFrame - 80)
+	if ppu.FrameBuffer.RGBAAt(80, 72) != white {
+		t.Errorf("the first frame should not have been drawn")
+	}
+
+	ppu.Step(dotsPerFrame)
+	if ppu.FrameBuffer.RGBAAt(80, 72) == white {
+		t.Errorf("the second frame should have been drawn")
+	}
+}
+
+func TestOAMSearch(t *testing.T) {
+	ppu := newTestPPU()
+
+	// 11 sprites on line 0, the first is hidden off the left of the screen
+	// and the last two share an X position
+	xs := []byte{0, 90, 80, 70, 60, 50, 40, 30, 20, 20, 10}
+	for i, x := range xs {
+		*ppu.OAM[i] = Sprite{Y: 16, X: x, TileNumber: byte(i)}
+	}
+
+	ppu.OAMSearch()
+
+	expected := []byte{0, 8, 9, 7, 6, 5, 4, 3, 2, 1}
+	if len(ppu.VisibleSprites) != len(expected) {
+		t.Fatalf("%v sprites should have been visible but %v were", len(expected), len(ppu.VisibleSprites))
+	}
+	for i, sprite := range ppu.VisibleSprites {
+		if sprite.TileNumber != expected[i] {
+			t.Errorf("visible sprite %v should have been OAM entry %v but was %v", i, expected[i], sprite.TileNumber)
+		}
+	}
+}
+
+func TestSpriteOffLeftEdge(t *testing.T) {
+	ppu := newTestPPU()
+	for i := 0; i < 16; i++ {
+		ppu.VRAM[0x10+i] = 0xFF
+	}
+	ppu.OBP0 = 0xE4
+	ppu.BGP = 0xE4
+	*ppu.OAM[0] = Sprite{Y: 16, X: 4, TileNumber: 1}
+	ppu.WriteByte(LCDC, 0x93)
+
+	stepToLine(ppu, 1)
+
+	for x := 0; x < 8; x++ {
+		if dark, expected := ppu.FrameBuffer.RGBAAt(x, 0).R < 0x80, x < 4; dark != expected {
+			t.Errorf("pixel %v dark should have been %v but was %v", x, expected, dark)
+		}
+	}
+}
+
+func TestSpriteChangesMidLine(t *testing.T) {
+	cases := []struct {
+		Name   string
+		LCDC   byte
+		X      byte
+		Change func(ppu *PPU)
+		// Dark is the range of pixels on line 0 the sprite is drawn over
+		Dark [2]int
+	}{
+		// The line has already passed a sprite at X=8 when sprites are
+		// enabled, so it isn't drawn
+		{"Sprites enabled mid-line", 0x91, 8, func(ppu *PPU) { ppu.WriteByte(LCDC, 0x93) }, [2]int{0, 0}},
+		// The sprites being drawn were copied by the OAM search, so moving
+		// the sprite in OAM, such as by DMA, doesn't move it until the next line
+		{"OAM X changed in mode 3", 0x93, 108, func(ppu *PPU) { ppu.OAM[0].X = 8 }, [2]int{100, 108}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu := newTestPPU()
+			for i := 0; i < 16; i++ {
+				ppu.VRAM[0x10+i] = 0xFF
+			}
+			ppu.OBP0 = 0xE4
+			ppu.BGP = 0xE4
+			*ppu.OAM[0] = Sprite{Y: 16, X: tt.X, TileNumber: 1}
+			ppu.WriteByte(LCDC, tt.LCDC)
+
+			for ppu.STAT.mode != MODE3 || ppu.lx < 44 {
+				ppu.Step(1)
+			}
+			tt.Change(ppu)
+			stepToLine(ppu, 1)
+
+			for x := 0; x < 160; x++ {
+				if dark, expected := ppu.FrameBuffer.RGBAAt(x, 0).R < 0x80, x >= tt.Dark[0] && x < tt.Dark[1]; dark != expected {
+					t.Errorf("pixel %v dark should have been %v but was %v", x, expected, dark)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexedFrame(t *testing.T) {
+	ppu := newTestPPU()
+	for i := 0; i < 16; i++ {
+		ppu.VRAM[0x10+i] = 0xFF
+	}
+	ppu.BGP = 0xE4
+	ppu.OBP1 = 0x1B
+	*ppu.OAM[0] = Sprite{Y: 16, X: 8, TileNumber: 1, Attributes: 0x10}
+	ppu.WriteByte(LCDC, 0x93)
+	stepToLine(ppu, 1)
+
+	frame := ppu.IndexedFrame()
+	if len(frame.Palette) != 4 {
+		t.Errorf("palette should have had 4 colors but had %v", len(frame.Palette))
+	}
+	if index := frame.ColorIndexAt(8, 0); index != 0 {
+		t.Errorf("background color ID should have been 0 but was %v", index)
+	}
+	// Color 3 is written as 3 even though OBP1 maps it to shade 0
+	if index := frame.ColorIndexAt(0, 0); index != 3 {
+		t.Errorf("sprite color ID should have been 3 but was %v", index)
+	}
+
+	// The palette is the colors of each color ID through BGP
+	ppu.BGP = 0x1B
+	ppu.Palettes.BG = HighContrastPalette
+	frame = ppu.IndexedFrame()
+	for id, expected := range []color.RGBA{HighContrastPalette[3], HighContrastPalette[2], HighContrastPalette[1], HighContrastPalette[0]} {
+		if c := frame.Palette[id]; c != expected {
+			t.Errorf("color %v should have been %v but was %v", id, expected, c)
+		}
+	}
+}
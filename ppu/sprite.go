@@ -26,8 +26,9 @@ func (s *Sprite) XFlip() bool {
 	return utils.IsBitSet(s.Attributes, 5)
 }
 
+// DMGPalette returns 0 for OBP0 and 1 for OBP1, selected by attribute bit 4
 func (s *Sprite) DMGPalette() byte {
-	return s.Attributes & 0x04
+	return (s.Attributes >> 4) & 0x01
 }
 
 func (s *Sprite) VRAMBank() bool {
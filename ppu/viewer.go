@@ -0,0 +1,167 @@
+package ppu
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Colors the viewers draw their overlays in
+var (
+	ViewportColor = color.RGBA{255, 0, 0, 0xFF}
+	WindowColor   = color.RGBA{0, 0, 255, 0xFF}
+)
+
+const (
+	// tilesPerRow is the number of tiles in each row of TileView,
+	// so each 128 tiles of tile data take up 8 rows
+	tilesPerRow = 16
+	tileCount   = 384
+	vramBank    = 0x2000
+)
+
+// TileView returns all 384 tiles in VRAM in rows of 16, in the shades of BGP. On CGB
+// the tiles in the second VRAM bank are drawn to the right of those in the first.
+func (ppu *PPU) TileView(cgb bool) *image.RGBA {
+	banks := 1
+	if cgb {
+		banks = 2
+	}
+
+	rows := tileCount / tilesPerRow
+	img := image.NewRGBA(image.Rect(0, 0, banks*tilesPerRow*8, rows*8))
+	for bank := 0; bank < banks; bank++ {
+		for tile := 0; tile < tileCount; tile++ {
+			x := (bank*tilesPerRow + tile%tilesPerRow) * 8
+			y := tile / tilesPerRow * 8
+			ppu.drawTile(img, x, y, bank*vramBank+tile*16, ppu.BGP, ppu.Palettes.BG, false, false, false)
+		}
+	}
+	return img
+}
+
+// TileMapView returns the 32x32 tile map at 0x9800, or at 0x9C00 if high is set, using
+// the tile data selected by LCDC. The part of the map shown by SCX and SCY is outlined
+// if it is the background map, and the part shown in the window if it is the window map.
+func (ppu *PPU) TileMapView(high bool) *image.RGBA {
+	mapAddress := uint16(0x9800)
+	if high {
+		mapAddress = 0x9C00
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	for i := 0; i < 32*32; i++ {
+		tile := ppu.VRAM[(mapAddress+uint16(i))&0x1FFF]
+		ppu.drawTile(img, i%32*8, i/32*8, ppu.tileDataOffset(tile), ppu.BGP, ppu.Palettes.BG, false, false, false)
+	}
+
+	if ppu.backgroundMapLocation == mapAddress {
+		// The viewport wraps around the edges of the map
+		for i := 0; i < 160; i++ {
+			img.Set((int(ppu.SCX)+i)%256, int(ppu.SCY), ViewportColor)
+			img.Set((int(ppu.SCX)+i)%256, (int(ppu.SCY)+143)%256, ViewportColor)
+		}
+		for i := 0; i < 144; i++ {
+			img.Set(int(ppu.SCX), (int(ppu.SCY)+i)%256, ViewportColor)
+			img.Set((int(ppu.SCX)+159)%256, (int(ppu.SCY)+i)%256, ViewportColor)
+		}
+	}
+
+	// The window shows its map from the top left corner, for as much of the screen as it covers
+	width, height := 160-(int(ppu.WX)-7), 144-int(ppu.WY)
+	if ppu.windowEnabled && ppu.windowMapLocation == mapAddress && width > 0 && height > 0 {
+		if width > 160 {
+			width = 160
+		}
+		for i := 0; i < width; i++ {
+			img.Set(i, 0, WindowColor)
+			img.Set(i, height-1, WindowColor)
+		}
+		for i := 0; i < height; i++ {
+			img.Set(0, i, WindowColor)
+			img.Set(width-1, i, WindowColor)
+		}
+	}
+
+	return img
+}
+
+// OAMView returns the 40 sprites in OAM in rows of 8, each drawn in a 8x16
+// cell with its palette and flips. Transparent pixels are left transparent.
+func (ppu *PPU) OAMView() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 8*8, 5*16))
+	for i, sprite := range ppu.OAM {
+		x, y := i%8*8, i/8*16
+
+		register, palette := ppu.OBP0, ppu.Palettes.OBP0
+		if sprite.DMGPalette() == 1 {
+			register, palette = ppu.OBP1, ppu.Palettes.OBP1
+		}
+
+		tiles := []byte{sprite.TileNumber}
+		if ppu.spriteSize == 16 {
+			// 8x16 sprites ignore the lowest bit of the tile number, a Y flip swaps the tiles
+			tiles = []byte{sprite.TileNumber & 0xFE, sprite.TileNumber | 0x01}
+			if sprite.YFlip() {
+				tiles[0], tiles[1] = tiles[1], tiles[0]
+			}
+		}
+
+		for j, tile := range tiles {
+			ppu.drawTile(img, x, y+j*8, int(tile)*16, register, palette, sprite.XFlip(), sprite.YFlip(), true)
+		}
+	}
+	return img
+}
+
+// DescribeOAM writes a table of the 40 OAM entries with their attributes decoded
+func (ppu *PPU) DescribeOAM(w io.Writer) {
+	fmt.Fprintln(w, " #   X   Y  Tile  Palette  X Flip  Y Flip  Behind BG  Bank  CGB Palette")
+	for i, sprite := range ppu.OAM {
+		fmt.Fprintf(w, "%2d %3d %3d   $%02X  OBP%d     %-6v  %-6v  %-9v  %d     %d\n",
+			i, sprite.X, sprite.Y, sprite.TileNumber, sprite.DMGPalette(),
+			sprite.XFlip(), sprite.YFlip(), sprite.Priority() == 1, boolToInt(sprite.VRAMBank()), sprite.GBCPalette())
+	}
+}
+
+// tileDataOffset returns the offset in VRAM of a background or window tile, using the tile data selected by LCDC
+func (ppu *PPU) tileDataOffset(tile byte) int {
+	if ppu.tileDataLocation == 0x8000 {
+		return int(tile) * 16
+	}
+	// Tiles 0-127 are at 0x9000 and 128-255 at 0x8800
+	return 0x1000 + int(int8(tile))*16
+}
+
+// drawTile draws the 8x8 tile at offset in VRAM with its top left corner at x, y,
+// flipped if set. If transparent is set, color 0 is left transparent as in sprites.
+func (ppu *PPU) drawTile(img *image.RGBA, x, y, offset int, register byte, palette Palette, xFlip, yFlip, transparent bool) {
+	for line := 0; line < 8; line++ {
+		data1 := ppu.VRAM[offset+line*2]
+		data2 := ppu.VRAM[offset+line*2+1]
+
+		for bit := 0; bit < 8; bit++ {
+			dot := Dot{ColorIdentifier: (data1>>(7-bit))&1 | (data2>>(7-bit))&1<<1}
+			if transparent && dot.ColorIdentifier == 0 {
+				continue
+			}
+
+			px, py := bit, line
+			if xFlip {
+				px = 7 - bit
+			}
+			if yFlip {
+				py = 7 - line
+			}
+			img.SetRGBA(x+px, y+py, dot.ToRGBA(register, palette))
+		}
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,180 @@
+package ppu
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// setTile fills a tile in VRAM with a single color identifier
+func setTile(ppu *PPU, offset int, colorIdentifier byte) {
+	for line := 0; line < 8; line++ {
+		ppu.VRAM[offset+line*2] = 0xFF * (colorIdentifier & 1)
+		ppu.VRAM[offset+line*2+1] = 0xFF * (colorIdentifier >> 1)
+	}
+}
+
+func TestTileView(t *testing.T) {
+	cases := []struct {
+		Name   string
+		CGB    bool
+		Width  int
+		Offset int
+		X, Y   int
+	}{
+		{"First tile", false, 128, 0x0000, 0, 0},
+		{"End of first row", false, 128, 15 * 16, 120, 0},
+		{"Last tile", false, 128, 383 * 16, 120, 184},
+		{"Second bank", true, 256, vramBank + 17*16, 136, 8},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu := newTestPPU()
+			ppu.BGP = 0xE4
+			setTile(ppu, tt.Offset, 3)
+
+			img := ppu.TileView(tt.CGB)
+			if img.Bounds().Dx() != tt.Width || img.Bounds().Dy() != 192 {
+				t.Errorf("TileView() should have been %vx192 but was %v", tt.Width, img.Bounds().Size())
+			}
+
+			black := ppu.Palettes.BG[3]
+			if c := img.RGBAAt(tt.X+7, tt.Y+7); c != black {
+				t.Errorf("Pixel should have been %v but was %v", black, c)
+			}
+			if c := img.RGBAAt(tt.X+8, tt.Y+8); c == black {
+				t.Errorf("Pixel after the tile should not have been %v", black)
+			}
+		})
+	}
+}
+
+func TestTileMapView(t *testing.T) {
+	cases := []struct {
+		Name   string
+		LCDC   byte
+		Tile   byte
+		Offset int
+	}{
+		{"Unsigned tile data", 0x91, 0x80, 0x0800},
+		{"Signed tile data", 0x81, 0x80, 0x0800},
+		{"Signed tile 0", 0x81, 0x00, 0x1000},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu := newTestPPU()
+			ppu.BGP = 0xE4
+			ppu.setLCDCFields(tt.LCDC)
+			// The last tile of the map, away from the viewport
+			ppu.VRAM[0x1BFF] = tt.Tile
+			setTile(ppu, tt.Offset, 3)
+
+			img := ppu.TileMapView(false)
+			if c := img.RGBAAt(252, 252); c != ppu.Palettes.BG[3] {
+				t.Errorf("Tile pixel should have been %v but was %v", ppu.Palettes.BG[3], c)
+			}
+		})
+	}
+}
+
+func TestTileMapViewOverlays(t *testing.T) {
+	cases := []struct {
+		Name     string
+		LCDC     byte
+		High     bool
+		X, Y     int
+		Expected color.RGBA
+	}{
+		{"Viewport corner", 0x81, false, 250, 10, ViewportColor},
+		{"Viewport wraps", 0x81, false, 153, 10, ViewportColor},
+		{"Viewport on the other map", 0x81, true, 250, 10, color.RGBA{}},
+		{"Window corner", 0xE1, true, 0, 0, WindowColor},
+		{"Window right edge", 0xE1, true, 139, 20, WindowColor},
+		{"Window bottom edge", 0xE1, true, 50, 43, WindowColor},
+		{"Window disabled", 0xC1, true, 0, 0, color.RGBA{}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu := newTestPPU()
+			ppu.setLCDCFields(tt.LCDC)
+			ppu.SCX, ppu.SCY = 250, 10
+			ppu.WX, ppu.WY = 27, 100
+
+			c := ppu.TileMapView(tt.High).RGBAAt(tt.X, tt.Y)
+			overlay := c == ViewportColor || c == WindowColor
+			if tt.Expected == (color.RGBA{}) {
+				if overlay {
+					t.Errorf("Pixel should not have been overlaid but was %v", c)
+				}
+			} else if c != tt.Expected {
+				t.Errorf("Pixel should have been %v but was %v", tt.Expected, c)
+			}
+		})
+	}
+}
+
+func TestOAMView(t *testing.T) {
+	cases := []struct {
+		Name       string
+		LCDC       byte
+		Attributes byte
+		X, Y       int
+		Expected   bool
+	}{
+		{"Top left", 0x81, 0x00, 0, 0, true},
+		{"Transparent", 0x81, 0x00, 1, 0, false},
+		{"X flip", 0x81, 0x20, 7, 0, true},
+		{"Y flip", 0x81, 0x40, 0, 7, true},
+		{"8x16 second tile", 0x85, 0x00, 0, 8, true},
+		{"8x16 Y flip", 0x85, 0x40, 0, 7, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			ppu := newTestPPU()
+			ppu.OBP0 = 0xE4
+			ppu.setLCDCFields(tt.LCDC)
+			// Only the top left pixel of tile 2 is set
+			ppu.VRAM[2*16] = 0x80
+			ppu.VRAM[2*16+1] = 0x80
+			if tt.LCDC&0x04 != 0 {
+				// In 8x16 mode the top left pixel of the second tile is set instead
+				ppu.VRAM[2*16], ppu.VRAM[2*16+1] = 0, 0
+				ppu.VRAM[3*16] = 0x80
+				ppu.VRAM[3*16+1] = 0x80
+			}
+			ppu.OAM[9] = &Sprite{TileNumber: 2, Attributes: tt.Attributes}
+
+			img := ppu.OAMView()
+			c := img.RGBAAt(8+tt.X, 16+tt.Y)
+			if set := c == ppu.Palettes.OBP0[3]; set != tt.Expected {
+				t.Errorf("Pixel should have been set %v but was %v", tt.Expected, c)
+			}
+			if c := img.RGBAAt(0, 0); c.A != 0 {
+				t.Errorf("Empty sprite should have been transparent but was %v", c)
+			}
+		})
+	}
+}
+
+func TestDescribeOAM(t *testing.T) {
+	ppu := newTestPPU()
+	ppu.OAM[3] = &Sprite{Y: 16, X: 8, TileNumber: 0x2A, Attributes: 0xF9}
+
+	var b bytes.Buffer
+	ppu.DescribeOAM(&b)
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 41 {
+		t.Fatalf("DescribeOAM() should have written 41 lines but wrote %v", len(lines))
+	}
+
+	expected := " 3   8  16   $2A  OBP1     true    true    true       1     1"
+	if lines[4] != expected {
+		t.Errorf("Line should have been %q but was %q", expected, lines[4])
+	}
+}
@@ -0,0 +1,137 @@
+package ramsearch
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxListed is the most candidates listed at once
+const maxListed = 20
+
+// ops are the names of the comparisons in commands
+var ops = map[string]Op{
+	"eq": Equal,
+	"ne": NotEqual,
+	"gt": Greater,
+	"lt": Less,
+	"ge": GreaterOrEqual,
+	"le": LessOrEqual,
+}
+
+// Usage describes the commands run by Exec
+const Usage = `search reset [8|16] [signed]    start a new search of 8 or 16 bit values
+search eq|ne|gt|lt|ge|le [N]     keep values compared with N, or with their previous value
+search same|changed              keep values equal or not equal to their previous value
+search inc|dec                   keep values greater or less than their previous value
+search by N                      keep values that changed by N since the previous search
+search list                      list the candidates left
+search promote ADDR VALUE [NAME] add GameShark codes holding VALUE at ADDR`
+
+// Exec runs a search command, such as from the console, with the
+// arguments after "search" and writing its output to w
+func (s *Search) Exec(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(w, Usage)
+		return nil
+	}
+
+	switch args[0] {
+	case "reset":
+		s.Size = 1
+		s.Signed = false
+		for _, arg := range args[1:] {
+			switch arg {
+			case "8":
+				s.Size = 1
+			case "16":
+				s.Size = 2
+			case "signed":
+				s.Signed = true
+			default:
+				return fmt.Errorf("unknown search option %q", arg)
+			}
+		}
+		s.Reset()
+		fmt.Fprintf(w, "%v candidates\n", len(s.candidates))
+		return nil
+
+	case "list":
+		candidates := s.Candidates()
+		for i, c := range candidates {
+			if i == maxListed {
+				fmt.Fprintf(w, "... and %v more\n", len(candidates)-maxListed)
+				break
+			}
+			fmt.Fprintf(w, "%04X: %v (was %v)\n", c.Addr, c.Value, c.Previous)
+		}
+		return nil
+
+	case "promote":
+		if len(args) < 3 {
+			return fmt.Errorf("promote needs an address and a value")
+		}
+		addr, err := strconv.ParseUint(strings.TrimPrefix(args[1], "0x"), 16, 16)
+		if err != nil {
+			return fmt.Errorf("invalid address %q", args[1])
+		}
+		value, err := strconv.ParseInt(args[2], 0, 32)
+		if err != nil {
+			return fmt.Errorf("invalid value %q", args[2])
+		}
+
+		added, err := s.Promote(uint16(addr), int(value), strings.Join(args[3:], " "))
+		for _, cheat := range added {
+			fmt.Fprintf(w, "Added %v\n", cheat.Code)
+		}
+		return err
+	}
+
+	c, err := parseComparison(args)
+	if err != nil {
+		return err
+	}
+	n, err := s.Filter(c)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%v candidates\n", n)
+	return nil
+}
+
+// parseComparison parses a comparison command
+func parseComparison(args []string) (Comparison, error) {
+	var c Comparison
+	switch args[0] {
+	case "same":
+		c.Op = Equal
+	case "changed":
+		c.Op = NotEqual
+	case "inc":
+		c.Op = Greater
+	case "dec":
+		c.Op = Less
+	case "by":
+		c.Op = DifferentBy
+		if len(args) < 2 {
+			return c, fmt.Errorf("by needs the difference")
+		}
+	default:
+		op, ok := ops[args[0]]
+		if !ok {
+			return c, fmt.Errorf("unknown search command %q", args[0])
+		}
+		c.Op = op
+		c.Constant = len(args) > 1
+	}
+
+	if len(args) > 1 {
+		value, err := strconv.ParseInt(args[1], 0, 32)
+		if err != nil {
+			return c, fmt.Errorf("invalid value %q", args[1])
+		}
+		c.Value = int(value)
+	}
+	return c, nil
+}
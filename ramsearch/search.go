@@ -0,0 +1,201 @@
+package ramsearch
+
+import (
+	"fmt"
+
+	"github.com/kevinbrolly/GopherBoy/cheats"
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+// Region is a range of RAM that is searched
+type Region struct {
+	Name       string
+	Start, End uint16
+}
+
+// Regions are the RAM a game keeps its state in: the current bank of cartridge RAM, work RAM and HRAM
+var Regions = []Region{
+	{"Cart RAM", 0xA000, 0xBFFF},
+	{"WRAM", 0xC000, 0xDFFF},
+	{"HRAM", 0xFF80, 0xFFFE},
+}
+
+// Op is how a value is compared
+type Op int
+
+const (
+	Equal Op = iota
+	NotEqual
+	Greater
+	Less
+	GreaterOrEqual
+	LessOrEqual
+	// DifferentBy keeps values that have changed by exactly the comparison's value since the previous search
+	DifferentBy
+)
+
+// Comparison is what a search keeps candidates by
+type Comparison struct {
+	Op Op
+	// Constant compares against Value instead of each candidate's previous value
+	Constant bool
+	// Value is the constant, or the difference for DifferentBy
+	Value int
+}
+
+// Candidate is an address that still matches every search, with its
+// value now and at the previous search
+type Candidate struct {
+	Addr     uint16
+	Value    int
+	Previous int
+}
+
+// Search finds the addresses of values in RAM by narrowing down the candidates with a
+// comparison every few frames, such as keeping the values that decreased when a life was
+// lost. Memory is read through the bus, so it sees what the CPU would. Values are 8 or 16
+// bits, little endian like the CPU, and signed or unsigned.
+type Search struct {
+	// Size is the size of the values in bytes, 1 or 2
+	Size   int
+	Signed bool
+
+	// Cheats are the cheats found addresses are promoted to
+	Cheats *cheats.Engine
+
+	mmu        *mmu.MMU
+	previous   [0x10000]byte
+	candidates []uint16
+}
+
+func NewSearch(m *mmu.MMU) *Search {
+	s := &Search{
+		Size: 1,
+		mmu:  m,
+	}
+	s.Reset()
+	return s
+}
+
+// Reset starts a new search with every address as a candidate, using the current Size
+func (s *Search) Reset() {
+	s.candidates = s.candidates[:0]
+	for _, region := range Regions {
+		for addr := int(region.Start); addr+s.Size-1 <= int(region.End); addr++ {
+			s.candidates = append(s.candidates, uint16(addr))
+		}
+	}
+	s.snapshot()
+}
+
+// Filter keeps the candidates that match the comparison, returning how many are left.
+// The values are then remembered as the previous values for the next search.
+func (s *Search) Filter(c Comparison) (int, error) {
+	if c.Op == DifferentBy && c.Constant {
+		return 0, fmt.Errorf("a difference can only be compared with the previous value")
+	}
+
+	kept := s.candidates[:0]
+	for _, addr := range s.candidates {
+		value := s.read(addr)
+		against := s.value(s.previous[addr:])
+		if c.Constant {
+			against = c.Value
+		}
+
+		if compare(c, value, against) {
+			kept = append(kept, addr)
+		}
+	}
+	s.candidates = kept
+
+	s.snapshot()
+	return len(s.candidates), nil
+}
+
+// Candidates returns the addresses left, with their values
+func (s *Search) Candidates() []Candidate {
+	candidates := make([]Candidate, len(s.candidates))
+	for i, addr := range s.candidates {
+		candidates[i] = Candidate{
+			Addr:     addr,
+			Value:    s.read(addr),
+			Previous: s.value(s.previous[addr:]),
+		}
+	}
+	return candidates
+}
+
+// Promote adds GameShark codes that hold the value at addr, one per byte of the value
+func (s *Search) Promote(addr uint16, value int, name string) ([]*cheats.Cheat, error) {
+	if s.Cheats == nil {
+		return nil, fmt.Errorf("there is no cheat engine to promote to")
+	}
+
+	var added []*cheats.Cheat
+	for i := 0; i < s.Size; i++ {
+		a := addr + uint16(i)
+		code := fmt.Sprintf("01%02X%02X%02X", byte(value>>(8*i)), byte(a), byte(a>>8))
+
+		cheat, err := s.Cheats.Add(code, name)
+		if err != nil {
+			return added, err
+		}
+		added = append(added, cheat)
+	}
+	return added, nil
+}
+
+func compare(c Comparison, value, against int) bool {
+	switch c.Op {
+	case Equal:
+		return value == against
+	case NotEqual:
+		return value != against
+	case Greater:
+		return value > against
+	case Less:
+		return value < against
+	case GreaterOrEqual:
+		return value >= against
+	case LessOrEqual:
+		return value <= against
+	case DifferentBy:
+		return value-against == c.Value
+	}
+	return false
+}
+
+// snapshot remembers the current values of every region
+func (s *Search) snapshot() {
+	for _, region := range Regions {
+		for addr := int(region.Start); addr <= int(region.End); addr++ {
+			s.previous[addr] = s.mmu.Peek(uint16(addr))
+		}
+	}
+}
+
+// read reads the value at addr now
+func (s *Search) read(addr uint16) int {
+	b := []byte{s.mmu.Peek(addr), 0}
+	if s.Size == 2 {
+		b[1] = s.mmu.Peek(addr + 1)
+	}
+	return s.value(b)
+}
+
+// value returns the value starting at the first byte of b
+func (s *Search) value(b []byte) int {
+	if s.Size == 2 {
+		v := uint16(b[0]) | uint16(b[1])<<8
+		if s.Signed {
+			return int(int16(v))
+		}
+		return int(v)
+	}
+
+	if s.Signed {
+		return int(int8(b[0]))
+	}
+	return int(b[0])
+}
@@ -0,0 +1,156 @@
+package ramsearch
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/cheats"
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+type ram struct {
+	data [0x10000]byte
+}
+
+func (r *ram) ReadByte(addr uint16) byte {
+	return r.data[addr]
+}
+
+func (r *ram) WriteByte(addr uint16, value byte) {
+	r.data[addr] = value
+}
+
+func newTestSearch() (*Search, *ram) {
+	m := mmu.NewMMU()
+	r := &ram{}
+	m.MapMemoryRange(r, 0xA000, 0xBFFF)
+	m.MapMemoryRange(r, 0xC000, 0xDFFF)
+	m.MapMemoryRange(r, 0xFF80, 0xFFFE)
+	return NewSearch(m), r
+}
+
+func TestFilter(t *testing.T) {
+	s, r := newTestSearch()
+
+	// Lives at 0xC100 go from 3 to 2, a timer at 0xFF90 counts up
+	r.data[0xC100] = 3
+	s.Reset()
+
+	r.data[0xC100] = 2
+	r.data[0xFF90] = 1
+	r.data[0xA000] = 0xFF
+
+	cases := []struct {
+		comparison Comparison
+		expected   int
+	}{
+		{Comparison{Op: NotEqual}, 3},
+		{Comparison{Op: Less, Constant: true, Value: 10}, 2},
+		{Comparison{Op: Equal}, 2},
+	}
+
+	for _, c := range cases {
+		n, err := s.Filter(c.comparison)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != c.expected {
+			t.Errorf("%+v should have left %v candidates but left %v", c.comparison, c.expected, n)
+		}
+	}
+
+	r.data[0xC100] = 1
+	r.data[0xFF90] = 2
+	if n, _ := s.Filter(Comparison{Op: DifferentBy, Value: -1}); n != 1 {
+		t.Fatalf("only the lives should have decreased by 1 but %v candidates were left", n)
+	}
+
+	candidates := s.Candidates()
+	if candidates[0] != (Candidate{Addr: 0xC100, Value: 1, Previous: 1}) {
+		t.Errorf("the candidate should have been the lives at 0xC100 but was %+v", candidates[0])
+	}
+
+	if _, err := s.Filter(Comparison{Op: DifferentBy, Constant: true}); err == nil {
+		t.Errorf("a difference from a constant should have been an error")
+	}
+}
+
+func TestFilterSigned(t *testing.T) {
+	cases := []struct {
+		size     int
+		signed   bool
+		expected int
+	}{
+		// 0xC000 is 0xFF, 0xC001 is 0x80
+		{1, false, 2},
+		{1, true, 0},
+		// 0xC000-0xC001 reads 0x80FF, 0xC001-0xC002 reads 0x0080, values don't span regions
+		{2, false, 2},
+		{2, true, 1},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			s, r := newTestSearch()
+			r.data[0xC000] = 0xFF
+			r.data[0xC001] = 0x80
+
+			s.Size = c.size
+			s.Signed = c.signed
+			s.Reset()
+
+			if n, _ := s.Filter(Comparison{Op: Greater, Constant: true, Value: 0x7F}); n != c.expected {
+				t.Errorf("%v candidates should have been greater than 0x7F but %v were", c.expected, n)
+			}
+		})
+	}
+}
+
+func TestPromote(t *testing.T) {
+	s, _ := newTestSearch()
+	s.Size = 2
+	s.Cheats = cheats.NewEngine(mmu.NewMMU())
+
+	added, err := s.Promote(0xC1FF, 0x1234, "Money")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 2 || added[0].Code != "0134FFC1" || added[1].Code != "011200C2" {
+		t.Errorf("the codes should have been 0134FFC1 and 011200C2 but were %+v", added)
+	}
+}
+
+func TestExec(t *testing.T) {
+	s, r := newTestSearch()
+
+	var out bytes.Buffer
+	run := func(command string) {
+		out.Reset()
+		if err := s.Exec(strings.Fields(command), &out); err != nil {
+			t.Fatalf("%q failed: %v", command, err)
+		}
+	}
+
+	run("reset 16 signed")
+	if s.Size != 2 || !s.Signed {
+		t.Errorf("reset should have started a signed 16 bit search")
+	}
+
+	run("reset")
+	r.data[0xC123] = 5
+	run("inc")
+	run("eq 5")
+	if out.String() != "1 candidates\n" {
+		t.Errorf("inc then eq 5 should have left 1 candidate but printed %q", out.String())
+	}
+
+	run("list")
+	if out.String() != "C123: 5 (was 5)\n" {
+		t.Errorf("list should have printed C123 but printed %q", out.String())
+	}
+
+	if err := s.Exec([]string{"jump"}, &out); err == nil {
+		t.Errorf("an unknown command should have been an error")
+	}
+}
@@ -0,0 +1,171 @@
+package record
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"io"
+)
+
+// APNGWriter writes frames as a lossless animated PNG. The first frame is also
+// the default image, so viewers without APNG support show the first frame.
+type APNGWriter struct {
+	w        io.WriteSeeker
+	frames   uint32
+	sequence uint32
+	// actlOffset is the position of the acTL chunk, which is rewritten by
+	// Close once the number of frames is known
+	actlOffset int64
+	err        error
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// NewAPNGWriter returns an APNGWriter that writes to w
+func NewAPNGWriter(w io.WriteSeeker) *APNGWriter {
+	return &APNGWriter{w: w}
+}
+
+// WriteFrame writes a frame of video
+func (a *APNGWriter) WriteFrame(frame *image.RGBA) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	bounds := frame.Bounds()
+
+	if a.frames == 0 {
+		a.writeHeader(bounds)
+	}
+
+	// Frame delays are rounded to milliseconds, carrying the
+	// rounding forward so the video doesn't drift from the audio
+	fctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(fctl[0:], a.nextSequence())
+	binary.BigEndian.PutUint32(fctl[4:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(bounds.Dy()))
+	binary.BigEndian.PutUint16(fctl[20:], uint16(frameDelay(int(a.frames), 1000)))
+	binary.BigEndian.PutUint16(fctl[22:], 1000)
+	a.writeChunk("fcTL", fctl)
+
+	data, err := compressFrame(frame)
+	if err != nil {
+		a.err = err
+		return err
+	}
+
+	if a.frames == 0 {
+		a.writeChunk("IDAT", data)
+	} else {
+		seq := make([]byte, 4)
+		binary.BigEndian.PutUint32(seq, a.nextSequence())
+		a.writeChunk("fdAT", append(seq, data...))
+	}
+
+	a.frames++
+	return a.err
+}
+
+func (a *APNGWriter) writeHeader(bounds image.Rectangle) {
+	a.write(pngSignature)
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(bounds.Dy()))
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 2 // truecolor
+	a.writeChunk("IHDR", ihdr)
+
+	if a.err == nil {
+		a.actlOffset, a.err = a.w.Seek(0, io.SeekCurrent)
+	}
+	a.writeChunk("acTL", a.actl())
+}
+
+// actl returns the animation control chunk data, with 0 plays meaning loop forever
+func (a *APNGWriter) actl() []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data[0:], a.frames)
+	return data
+}
+
+func (a *APNGWriter) nextSequence() uint32 {
+	a.sequence++
+	return a.sequence - 1
+}
+
+// compressFrame returns the zlib compressed RGB scanlines of frame
+func compressFrame(frame *image.RGBA) ([]byte, error) {
+	bounds := frame.Bounds()
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	line := make([]byte, 1+bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		// Each scanline starts with the filter type, 0 is no filtering
+		line[0] = 0
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := frame.RGBAAt(x, y)
+			i := 1 + (x-bounds.Min.X)*3
+			line[i], line[i+1], line[i+2] = c.R, c.G, c.B
+		}
+		if _, err := zw.Write(line); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (a *APNGWriter) writeChunk(chunkType string, data []byte) {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:], uint32(len(data)))
+	copy(header[4:], chunkType)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	a.write(header)
+	a.write(data)
+	a.write(footer)
+}
+
+func (a *APNGWriter) write(data []byte) {
+	if a.err == nil {
+		_, a.err = a.w.Write(data)
+	}
+}
+
+// Close writes the end of the PNG and fills in the number of frames,
+// it does not close the underlying writer
+func (a *APNGWriter) Close() error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.frames == 0 {
+		return nil
+	}
+
+	a.writeChunk("IEND", nil)
+
+	end, err := a.w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := a.w.Seek(a.actlOffset, io.SeekStart); err != nil {
+		return err
+	}
+	a.writeChunk("acTL", a.actl())
+	if a.err != nil {
+		return a.err
+	}
+	_, err = a.w.Seek(end, io.SeekStart)
+	return err
+}
@@ -0,0 +1,105 @@
+package record
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StemNames are the suffixes of the per channel WAV files, in APU channel order
+var StemNames = [4]string{"square1", "square2", "wave", "noise"}
+
+// AudioRecorder records the stereo mix to a WAV file, and optionally the output of each
+// channel before it is mixed by NR50 and NR51 to a separate mono WAV file, or stem
+type AudioRecorder struct {
+	mix   *WAVWriter
+	stems [4]*WAVWriter
+	files []*os.File
+
+	// err is the first error from writing samples, which is returned by Close
+	err error
+}
+
+// CreateAudio starts recording the stereo mix at sampleRate to filename. With stems,
+// each channel is also recorded to a file named after filename with the channel name
+// added, e.g. session-square1.wav.
+func CreateAudio(filename string, sampleRate int, stems bool) (*AudioRecorder, error) {
+	r := &AudioRecorder{}
+
+	var err error
+	if r.mix, err = r.createWAV(filename, sampleRate, 2); err != nil {
+		r.closeFiles()
+		return nil, err
+	}
+
+	if stems {
+		base := strings.TrimSuffix(filename, filepath.Ext(filename))
+		for i, name := range StemNames {
+			if r.stems[i], err = r.createWAV(base+"-"+name+".wav", sampleRate, 1); err != nil {
+				r.closeFiles()
+				return nil, err
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func (r *AudioRecorder) createWAV(filename string, sampleRate, channels int) (*WAVWriter, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	r.files = append(r.files, f)
+
+	return NewWAVWriter(f, sampleRate, channels)
+}
+
+// Stems returns true if each channel is being recorded to its own file
+func (r *AudioRecorder) Stems() bool {
+	return r.stems[0] != nil
+}
+
+// WriteSamples records interleaved stereo samples, so the AudioRecorder can be added as an APU sink
+func (r *AudioRecorder) WriteSamples(samples []int16) {
+	r.setErr(r.mix.WriteSamples(samples))
+}
+
+// WriteChannelSamples records the samples of each channel to the stems,
+// so the AudioRecorder can be added as an APU channel sink
+func (r *AudioRecorder) WriteChannelSamples(channels [4][]int16) {
+	if !r.Stems() {
+		return
+	}
+	for i, samples := range channels {
+		r.setErr(r.stems[i].WriteSamples(samples))
+	}
+}
+
+func (r *AudioRecorder) setErr(err error) {
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// Close finishes the WAV files
+func (r *AudioRecorder) Close() error {
+	r.setErr(r.mix.Close())
+	if r.Stems() {
+		for _, stem := range r.stems {
+			r.setErr(stem.Close())
+		}
+	}
+	r.setErr(r.closeFiles())
+	return r.err
+}
+
+func (r *AudioRecorder) closeFiles() error {
+	var err error
+	for _, f := range r.files {
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
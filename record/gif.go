@@ -0,0 +1,174 @@
+package record
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// GIFWriter streams frames as an animated GIF. The DMG only draws a handful of
+// colors, so each frame gets a local color table of exactly the colors it uses
+// and no colors are lost to quantization.
+type GIFWriter struct {
+	w      *bufio.Writer
+	frames int
+	err    error
+}
+
+// NewGIFWriter returns a GIFWriter that writes to w
+func NewGIFWriter(w io.Writer) *GIFWriter {
+	return &GIFWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteFrame writes a frame of video
+func (g *GIFWriter) WriteFrame(frame *image.RGBA) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	bounds := frame.Bounds()
+	if g.frames == 0 {
+		g.writeHeader(bounds)
+	}
+
+	// Build the color table and the index of each pixel
+	var palette []color.RGBA
+	indices := make(map[color.RGBA]byte)
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := frame.RGBAAt(x, y)
+			c.A = 0xFF
+			index, ok := indices[c]
+			if !ok {
+				if len(palette) == 256 {
+					g.err = fmt.Errorf("frame %v has more than 256 colors", g.frames)
+					return g.err
+				}
+				index = byte(len(palette))
+				indices[c] = index
+				palette = append(palette, c)
+			}
+			pixels = append(pixels, index)
+		}
+	}
+
+	// The color table size is 2^(n+1), with at least 2 bits per pixel for LZW
+	bits := 2
+	for 1<<bits < len(palette) {
+		bits++
+	}
+
+	// Graphic Control Extension with the frame delay in hundredths of a second,
+	// carrying the rounding forward so the video doesn't drift from the audio.
+	// Some viewers slow down delays of 1, use APNG or Y4M for exact timing.
+	delay := uint16(frameDelay(g.frames, 100))
+	g.write([]byte{0x21, 0xF9, 0x04, 0x00, byte(delay), byte(delay >> 8), 0x00, 0x00})
+
+	// Image Descriptor with a local color table
+	descriptor := make([]byte, 10)
+	descriptor[0] = 0x2C
+	binary.LittleEndian.PutUint16(descriptor[5:], uint16(bounds.Dx()))
+	binary.LittleEndian.PutUint16(descriptor[7:], uint16(bounds.Dy()))
+	descriptor[9] = 0x80 | byte(bits-1)
+	g.write(descriptor)
+
+	table := make([]byte, 3<<bits)
+	for i, c := range palette {
+		table[i*3], table[i*3+1], table[i*3+2] = c.R, c.G, c.B
+	}
+	g.write(table)
+
+	// LZW compressed pixels, split into blocks of up to 255 bytes
+	g.write([]byte{byte(bits)})
+	blocks := &gifBlockWriter{w: g.w}
+	lw := lzw.NewWriter(blocks, lzw.LSB, bits)
+	if _, err := lw.Write(pixels); err != nil {
+		g.err = err
+		return err
+	}
+	if err := lw.Close(); err != nil {
+		g.err = err
+		return err
+	}
+	if err := blocks.Close(); err != nil {
+		g.err = err
+		return err
+	}
+
+	g.frames++
+	return g.err
+}
+
+func (g *GIFWriter) writeHeader(bounds image.Rectangle) {
+	// Header and Logical Screen Descriptor without a global color table
+	header := make([]byte, 13)
+	copy(header, "GIF89a")
+	binary.LittleEndian.PutUint16(header[6:], uint16(bounds.Dx()))
+	binary.LittleEndian.PutUint16(header[8:], uint16(bounds.Dy()))
+	g.write(header)
+
+	// NETSCAPE2.0 Application Extension to loop forever
+	g.write([]byte{0x21, 0xFF, 0x0B})
+	g.write([]byte("NETSCAPE2.0"))
+	g.write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+}
+
+func (g *GIFWriter) write(data []byte) {
+	if g.err == nil {
+		_, g.err = g.w.Write(data)
+	}
+}
+
+// Close writes the GIF trailer, it does not close the underlying writer
+func (g *GIFWriter) Close() error {
+	if g.frames > 0 {
+		g.write([]byte{0x3B})
+	}
+	if g.err != nil {
+		return g.err
+	}
+	return g.w.Flush()
+}
+
+// gifBlockWriter splits image data into sub-blocks of up to 255 bytes
+type gifBlockWriter struct {
+	w     io.Writer
+	block []byte
+}
+
+func (b *gifBlockWriter) Write(data []byte) (int, error) {
+	for _, d := range data {
+		b.block = append(b.block, d)
+		if len(b.block) == 255 {
+			if err := b.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(data), nil
+}
+
+func (b *gifBlockWriter) flush() error {
+	if len(b.block) == 0 {
+		return nil
+	}
+	if _, err := b.w.Write(append([]byte{byte(len(b.block))}, b.block...)); err != nil {
+		return err
+	}
+	b.block = b.block[:0]
+	return nil
+}
+
+// Close writes the last sub-block followed by the block terminator
+func (b *gifBlockWriter) Close() error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	_, err := b.w.Write([]byte{0x00})
+	return err
+}
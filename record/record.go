@@ -0,0 +1,148 @@
+// Package record records gameplay to video and audio files
+package record
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kevinbrolly/GopherBoy/cpu"
+)
+
+// frameDelay returns the length of the nth frame in units of 1/unitsPerSecond.
+// Each delay is rounded from the total time so far, so the video stays
+// in sync with the audio even though frames are not a whole number of units.
+func frameDelay(n int, unitsPerSecond int) int {
+	end := (int64(n+1)*cpu.CyclesPerFrame*int64(unitsPerSecond) + cpu.ClockSpeed/2) / cpu.ClockSpeed
+	start := (int64(n)*cpu.CyclesPerFrame*int64(unitsPerSecond) + cpu.ClockSpeed/2) / cpu.ClockSpeed
+	return int(end - start)
+}
+
+// VideoWriter writes frames of video
+type VideoWriter interface {
+	WriteFrame(frame *image.RGBA) error
+	Close() error
+}
+
+// Format is a video format to record in
+type Format int
+
+const (
+	Y4M Format = iota
+	APNG
+	GIF
+)
+
+// Extension returns the file extension for the format
+func (f Format) Extension() string {
+	switch f {
+	case APNG:
+		return ".png"
+	case GIF:
+		return ".gif"
+	}
+	return ".y4m"
+}
+
+// FormatFromFilename returns the format for the file extension of filename
+func FormatFromFilename(filename string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".y4m":
+		return Y4M, nil
+	case ".png", ".apng":
+		return APNG, nil
+	case ".gif":
+		return GIF, nil
+	}
+	return 0, fmt.Errorf("unknown video format %q, use .y4m, .png or .gif", filepath.Ext(filename))
+}
+
+// Recorder records video to a file and the audio to a WAV file alongside it
+type Recorder struct {
+	video VideoWriter
+	audio *WAVWriter
+	files []*os.File
+
+	// err is the first error from writing samples, which is returned by Close
+	err error
+}
+
+// Create starts recording video to filename, in the format given by its extension,
+// and stereo audio at sampleRate to a WAV file with the same name
+func Create(filename string, sampleRate int) (*Recorder, error) {
+	format, err := FormatFromFilename(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{}
+
+	videoFile, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	r.files = append(r.files, videoFile)
+
+	switch format {
+	case Y4M:
+		r.video = NewY4MWriter(videoFile)
+	case APNG:
+		r.video = NewAPNGWriter(videoFile)
+	case GIF:
+		r.video = NewGIFWriter(videoFile)
+	}
+
+	audioFile, err := os.Create(strings.TrimSuffix(filename, filepath.Ext(filename)) + ".wav")
+	if err != nil {
+		r.closeFiles()
+		return nil, err
+	}
+	r.files = append(r.files, audioFile)
+
+	r.audio, err = NewWAVWriter(audioFile, sampleRate, 2)
+	if err != nil {
+		r.closeFiles()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// WriteFrame records a frame of video
+func (r *Recorder) WriteFrame(frame *image.RGBA) error {
+	return r.video.WriteFrame(frame)
+}
+
+// WriteSamples records interleaved stereo samples, so the Recorder can be added as an APU sink
+func (r *Recorder) WriteSamples(samples []int16) {
+	if err := r.audio.WriteSamples(samples); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// Close finishes the video and audio files
+func (r *Recorder) Close() error {
+	err := r.err
+	for _, closer := range []io.Closer{r.video, r.audio} {
+		if e := closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if e := r.closeFiles(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+func (r *Recorder) closeFiles() error {
+	var err error
+	for _, f := range r.files {
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,264 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testColors = []color.RGBA{
+	{224, 248, 208, 0xFF},
+	{136, 192, 112, 0xFF},
+	{52, 104, 86, 0xFF},
+	{8, 24, 32, 0xFF},
+}
+
+// testFrame returns a 160x144 frame with vertical stripes of the test colors, offset by n
+func testFrame(n int) *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, 160, 144))
+	for y := 0; y < 144; y++ {
+		for x := 0; x < 160; x++ {
+			frame.SetRGBA(x, y, testColors[(x/8+n)%len(testColors)])
+		}
+	}
+	return frame
+}
+
+func TestFrameDelay(t *testing.T) {
+	cases := []struct {
+		UnitsPerSecond int
+		Frames         int
+		Expected       int
+	}{
+		{1000, 1, 17},
+		{1000, 60, 1005},
+		{1000, 3600, 60274},
+		{100, 60, 100},
+		{100, 3600, 6027},
+	}
+	for _, tt := range cases {
+		total := 0
+		for n := 0; n < tt.Frames; n++ {
+			total += frameDelay(n, tt.UnitsPerSecond)
+		}
+		if total != tt.Expected {
+			t.Errorf("%v frames should have lasted %v units of 1/%v second but lasted %v", tt.Frames, tt.Expected, tt.UnitsPerSecond, total)
+		}
+	}
+}
+
+func TestWAVWriter(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "test.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	wav, err := NewWAVWriter(f, 44100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wav.WriteSamples([]int16{1, -1, 2, -2})
+	wav.WriteSamples([]int16{3, -3})
+	if err := wav.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != wavHeaderSize+12 {
+		t.Fatalf("file should have been %v bytes but was %v", wavHeaderSize+12, len(data))
+	}
+	if size := binary.LittleEndian.Uint32(data[4:]); size != 36+12 {
+		t.Errorf("RIFF size should have been %v but was %v", 36+12, size)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); size != 12 {
+		t.Errorf("data size should have been 12 but was %v", size)
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:]); rate != 44100 {
+		t.Errorf("sample rate should have been 44100 but was %v", rate)
+	}
+	if sample := int16(binary.LittleEndian.Uint16(data[wavHeaderSize+10:])); sample != -3 {
+		t.Errorf("last sample should have been -3 but was %v", sample)
+	}
+}
+
+func TestY4MWriter(t *testing.T) {
+	var buf bytes.Buffer
+	y4m := NewY4MWriter(&buf)
+	y4m.WriteFrame(testFrame(0))
+	y4m.WriteFrame(testFrame(1))
+	if err := y4m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	header := "YUV4MPEG2 W160 H144 F4194304:70224 Ip A1:1 C444 XCOLORRANGE=FULL\n"
+	if !strings.HasPrefix(buf.String(), header) {
+		t.Errorf("output should have started with header %q", header)
+	}
+
+	frameSize := len("FRAME\n") + 160*144*3
+	if buf.Len() != len(header)+2*frameSize {
+		t.Errorf("output should have been %v bytes but was %v", len(header)+2*frameSize, buf.Len())
+	}
+}
+
+func TestAPNGWriter(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "test.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	apng := NewAPNGWriter(f)
+	for n := 0; n < 3; n++ {
+		if err := apng.WriteFrame(testFrame(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := apng.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoders without APNG support see the first frame
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() returned error: %v", err)
+	}
+	if c := color.RGBAModel.Convert(img.At(8, 0)); c != testColors[1] {
+		t.Errorf("pixel should have been %v but was %v", testColors[1], c)
+	}
+
+	actl := bytes.Index(data, []byte("acTL"))
+	if actl == -1 {
+		t.Fatalf("acTL chunk not found")
+	}
+	if frames := binary.BigEndian.Uint32(data[actl+4:]); frames != 3 {
+		t.Errorf("acTL should have had 3 frames but had %v", frames)
+	}
+	if fdat := bytes.Count(data, []byte("fdAT")); fdat != 2 {
+		t.Errorf("there should have been 2 fdAT chunks but there were %v", fdat)
+	}
+}
+
+func TestGIFWriter(t *testing.T) {
+	var buf bytes.Buffer
+	g := NewGIFWriter(&buf)
+	for n := 0; n < 3; n++ {
+		if err := g.WriteFrame(testFrame(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll() returned error: %v", err)
+	}
+	if len(decoded.Image) != 3 {
+		t.Fatalf("there should have been 3 frames but there were %v", len(decoded.Image))
+	}
+
+	for n, frame := range decoded.Image {
+		expected := testFrame(n)
+		for y := 0; y < 144; y++ {
+			for x := 0; x < 160; x++ {
+				if c := color.RGBAModel.Convert(frame.At(x, y)); c != expected.RGBAAt(x, y) {
+					t.Fatalf("frame %v pixel (%v, %v) should have been %v but was %v", n, x, y, expected.RGBAAt(x, y), c)
+				}
+			}
+		}
+	}
+
+	if delays := decoded.Delay; delays[0]+delays[1]+delays[2] != 5 {
+		t.Errorf("3 frames should have lasted 5 hundredths of a second but lasted %v", delays)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "session.gif")
+
+	r, err := Create(filename, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.WriteFrame(testFrame(0))
+	r.WriteSamples([]int16{0, 0})
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"session.gif", "session.wav"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%v should have been created: %v", name, err)
+		}
+	}
+
+	if _, err := Create(filepath.Join(dir, "session.mp4"), 44100); err == nil {
+		t.Errorf("Create() should have returned an error for an unknown format")
+	}
+}
+
+func TestCreateAudio(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "session.wav")
+
+	r, err := CreateAudio(filename, 44100, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.WriteSamples([]int16{1, 2, 3, 4})
+	r.WriteChannelSamples([4][]int16{{1, 1}, {2, 2}, {3, 3}, {4, 4}})
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Name     string
+		Channels uint16
+		Sample   int16
+	}{
+		{"session.wav", 2, 1},
+		{"session-square1.wav", 1, 1},
+		{"session-square2.wav", 1, 2},
+		{"session-wave.wav", 1, 3},
+		{"session-noise.wav", 1, 4},
+	}
+	// Both files have 2 sample periods of 16 bit samples
+	const frames = 2
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join(dir, tt.Name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := wavHeaderSize + frames*2*int(tt.Channels); len(data) != size {
+				t.Fatalf("file should have been %v bytes but was %v", size, len(data))
+			}
+			if channels := binary.LittleEndian.Uint16(data[22:]); channels != tt.Channels {
+				t.Errorf("channels should have been %v but was %v", tt.Channels, channels)
+			}
+			if sample := int16(binary.LittleEndian.Uint16(data[wavHeaderSize:])); sample != tt.Sample {
+				t.Errorf("first sample should have been %v but was %v", tt.Sample, sample)
+			}
+		})
+	}
+}
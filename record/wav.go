@@ -0,0 +1,88 @@
+package record
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// WAVWriter writes 16 bit PCM samples to a WAV file. The sizes in the header
+// are not known until recording stops, so they are filled in by Close.
+type WAVWriter struct {
+	w          io.WriteSeeker
+	sampleRate int
+	channels   int
+	dataSize   uint32
+	err        error
+}
+
+const wavHeaderSize = 44
+
+// NewWAVWriter writes the WAV header to w and returns a WAVWriter
+// for samples with the given sample rate and number of channels
+func NewWAVWriter(w io.WriteSeeker, sampleRate, channels int) (*WAVWriter, error) {
+	wav := &WAVWriter{
+		w:          w,
+		sampleRate: sampleRate,
+		channels:   channels,
+	}
+	if err := wav.writeHeader(); err != nil {
+		return nil, err
+	}
+	return wav, nil
+}
+
+func (wav *WAVWriter) writeHeader() error {
+	blockAlign := wav.channels * 2
+
+	header := make([]byte, wavHeaderSize)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], 36+wav.dataSize)
+	copy(header[8:], "WAVE")
+
+	copy(header[12:], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(header[22:], uint16(wav.channels))
+	binary.LittleEndian.PutUint32(header[24:], uint32(wav.sampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(wav.sampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(header[32:], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(header[34:], 16) // bits per sample
+
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], wav.dataSize)
+
+	_, err := wav.w.Write(header)
+	return err
+}
+
+// WriteSamples writes interleaved samples, one per channel for each sample period
+func (wav *WAVWriter) WriteSamples(samples []int16) error {
+	if wav.err != nil {
+		return wav.err
+	}
+
+	data := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(sample))
+	}
+
+	_, wav.err = wav.w.Write(data)
+	wav.dataSize += uint32(len(data))
+	return wav.err
+}
+
+// Close fills in the sizes in the WAV header, it does not close the underlying writer
+func (wav *WAVWriter) Close() error {
+	if wav.err != nil {
+		return wav.err
+	}
+
+	if _, err := wav.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := wav.writeHeader(); err != nil {
+		return err
+	}
+	_, err := wav.w.Seek(0, io.SeekEnd)
+	return err
+}
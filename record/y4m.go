@@ -0,0 +1,67 @@
+package record
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/kevinbrolly/GopherBoy/cpu"
+)
+
+// Y4MWriter writes frames as uncompressed YUV4MPEG2 video with 4:4:4
+// full range chroma, so no color information is lost to subsampling
+type Y4MWriter struct {
+	w             *bufio.Writer
+	headerWritten bool
+}
+
+// NewY4MWriter returns a Y4MWriter that writes to w
+func NewY4MWriter(w io.Writer) *Y4MWriter {
+	return &Y4MWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteFrame writes a frame of video, the header is written
+// before the first frame using that frame's size
+func (y *Y4MWriter) WriteFrame(frame *image.RGBA) error {
+	bounds := frame.Bounds()
+
+	if !y.headerWritten {
+		y.headerWritten = true
+		_, err := fmt.Fprintf(y.w, "YUV4MPEG2 W%d H%d F%d:%d Ip A1:1 C444 XCOLORRANGE=FULL\n",
+			bounds.Dx(), bounds.Dy(), cpu.ClockSpeed, cpu.CyclesPerFrame)
+		if err != nil {
+			return err
+		}
+	}
+
+	planes := make([][]byte, 3)
+	for i := range planes {
+		planes[i] = make([]byte, 0, bounds.Dx()*bounds.Dy())
+	}
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			c := frame.RGBAAt(px, py)
+			Y, Cb, Cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			planes[0] = append(planes[0], Y)
+			planes[1] = append(planes[1], Cb)
+			planes[2] = append(planes[2], Cr)
+		}
+	}
+
+	if _, err := io.WriteString(y.w, "FRAME\n"); err != nil {
+		return err
+	}
+	for _, plane := range planes {
+		if _, err := y.w.Write(plane); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes any buffered frames, it does not close the underlying writer
+func (y *Y4MWriter) Close() error {
+	return y.w.Flush()
+}
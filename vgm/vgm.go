@@ -0,0 +1,194 @@
+package vgm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/kevinbrolly/GopherBoy/apu"
+	"github.com/kevinbrolly/GopherBoy/cpu"
+)
+
+const (
+	// SampleRate is the rate VGM waits are counted at
+	SampleRate = 44100
+
+	// Version 1.71, in BCD
+	version = 0x171
+
+	// headerSize is the size of a version 1.71 header, the data starts straight after it
+	headerSize = 0x100
+)
+
+// Commands
+const (
+	cmdGameBoyWrite = 0xB3 // aa dd: register aa of the Game Boy DMG is written with dd
+	cmdWait         = 0x61 // nnnn: wait n samples
+	cmdWait60th     = 0x62 // wait 735 samples, 1/60th of a second
+	cmdWait50th     = 0x63 // wait 882 samples, 1/50th of a second
+	cmdWaitShort    = 0x70 // 0x7n: wait n+1 samples
+	cmdEnd          = 0x66
+)
+
+// Recorder logs the writes to the APU's registers and wave RAM to a VGM file, with
+// each write timed to the nearest sample. The log starts with the writes needed to put
+// the APU into its state when recording started, so it can be started at any time.
+type Recorder struct {
+	apu  *apu.APU
+	file *os.File
+	ws   io.WriteSeeker
+	w    *bufio.Writer
+
+	start   uint64
+	samples uint64
+	size    int64
+
+	// err is the first error from writing, which is returned by Close
+	err error
+}
+
+// Create starts logging the APU's writes to filename
+func Create(filename string, a *apu.APU) (*Recorder, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewRecorder(f, a)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.file = f
+	return r, nil
+}
+
+// NewRecorder starts logging the APU's writes to w. The sizes in the header are
+// not known until recording stops, so they are filled in by Close.
+func NewRecorder(w io.WriteSeeker, a *apu.APU) (*Recorder, error) {
+	r := &Recorder{
+		apu:   a,
+		ws:    w,
+		w:     bufio.NewWriter(w),
+		start: a.Cycles(),
+	}
+
+	if err := r.writeHeader(); err != nil {
+		return nil, err
+	}
+
+	for _, write := range a.StateWrites() {
+		r.writeRegister(write.Addr, write.Value)
+	}
+
+	a.AddWriteListener(r)
+	return r, nil
+}
+
+// writeHeader writes the VGM header, with only the fields used set
+//
+// Offset Description
+// ------ -----------
+// 00     "Vgm " ident
+// 04     EOF offset, relative to 0x04
+// 08     Version number
+// 18     Total number of samples
+// 34     VGM data offset, relative to 0x34
+// 80     Game Boy DMG clock
+func (r *Recorder) writeHeader() error {
+	header := make([]byte, headerSize)
+	copy(header[0x00:], "Vgm ")
+	binary.LittleEndian.PutUint32(header[0x04:], uint32(headerSize+r.size+1-0x04))
+	binary.LittleEndian.PutUint32(header[0x08:], version)
+	binary.LittleEndian.PutUint32(header[0x18:], uint32(r.samples))
+	binary.LittleEndian.PutUint32(header[0x34:], headerSize-0x34)
+	// The DMG sound chip is clocked by the CPU clock
+	binary.LittleEndian.PutUint32(header[0x80:], cpu.ClockSpeed)
+
+	_, err := r.w.Write(header)
+	return err
+}
+
+// RegisterWritten logs a write to the APU, after waiting until the time it was written
+func (r *Recorder) RegisterWritten(cycle uint64, addr uint16, value byte) {
+	r.waitUntil(cycle)
+	r.writeRegister(addr, value)
+}
+
+// writeRegister logs a write to the sound registers, which are numbered from 0 for NR10
+func (r *Recorder) writeRegister(addr uint16, value byte) {
+	r.write(cmdGameBoyWrite, byte(addr-apu.NR10), value)
+}
+
+// waitUntil waits until the sample cycle is in
+func (r *Recorder) waitUntil(cycle uint64) {
+	sample := (cycle - r.start) * SampleRate / cpu.ClockSpeed
+	if sample <= r.samples {
+		return
+	}
+
+	r.wait(sample - r.samples)
+	r.samples = sample
+}
+
+// wait writes wait commands for n samples, using the shortest commands possible
+func (r *Recorder) wait(n uint64) {
+	for n > 0 {
+		switch {
+		case n <= 16:
+			r.write(cmdWaitShort + byte(n-1))
+			n = 0
+		case n == 735:
+			r.write(cmdWait60th)
+			n = 0
+		case n == 882:
+			r.write(cmdWait50th)
+			n = 0
+		default:
+			wait := n
+			if wait > 0xFFFF {
+				wait = 0xFFFF
+			}
+			r.write(cmdWait, byte(wait), byte(wait>>8))
+			n -= wait
+		}
+	}
+}
+
+func (r *Recorder) write(data ...byte) {
+	if r.err != nil {
+		return
+	}
+	_, r.err = r.w.Write(data)
+	r.size += int64(len(data))
+}
+
+// Close stops logging, waits until the current time and fills in the sizes in the header
+func (r *Recorder) Close() error {
+	r.apu.RemoveWriteListener(r)
+
+	r.waitUntil(r.apu.Cycles())
+	r.w.WriteByte(cmdEnd)
+
+	err := r.err
+	if err == nil {
+		err = r.w.Flush()
+	}
+	if err == nil {
+		_, err = r.ws.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = r.writeHeader()
+	}
+	if err == nil {
+		err = r.w.Flush()
+	}
+
+	if r.file != nil {
+		if closeErr := r.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package vgm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kevinbrolly/GopherBoy/apu"
+	"github.com/kevinbrolly/GopherBoy/cpu"
+	"github.com/kevinbrolly/GopherBoy/mmu"
+)
+
+// sampleCycles returns the number of cycles until the start of sample n
+func sampleCycles(n int) int {
+	return (n*cpu.ClockSpeed + SampleRate - 1) / SampleRate
+}
+
+func TestRecorder(t *testing.T) {
+	a := apu.NewAPU(mmu.NewMMU())
+	a.WriteByte(apu.NR52, 0x80)
+	a.WriteByte(apu.NR12, 0xF3)
+	a.Tick(1000)
+
+	filename := filepath.Join(t.TempDir(), "test.vgm")
+	r, err := Create(filename, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Written before any time has passed
+	a.WriteByte(apu.NR13, 0x12)
+	// 5 samples later
+	a.Tick(sampleCycles(5))
+	a.WriteByte(0xFF30, 0xAB)
+	// 1/60th of a second later
+	a.Tick(sampleCycles(740) - sampleCycles(5))
+	a.WriteByte(apu.NR14, 0x87)
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Writes after closing aren't logged
+	a.WriteByte(apu.NR14, 0x87)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := []struct {
+		name     string
+		offset   int
+		expected uint32
+	}{
+		{"EOF offset", 0x04, uint32(len(data) - 4)},
+		{"Version", 0x08, 0x171},
+		{"Total samples", 0x18, 740},
+		{"Data offset", 0x34, 0xCC},
+		{"DMG clock", 0x80, cpu.ClockSpeed},
+	}
+
+	for _, c := range header {
+		t.Run(c.name, func(t *testing.T) {
+			if value := binary.LittleEndian.Uint32(data[c.offset:]); value != c.expected {
+				t.Errorf("%v should have been %#x but was %#x", c.name, c.expected, value)
+			}
+		})
+	}
+
+	if string(data[:4]) != "Vgm " {
+		t.Errorf("ident should have been \"Vgm \" but was %q", data[:4])
+	}
+
+	// The state of the APU when recording started is written first
+	commands := data[headerSize:]
+	if !bytes.HasPrefix(commands, []byte{0xB3, 0x16, 0x80, 0xB3, 0x0A, 0x00}) {
+		t.Errorf("commands should have started by powering on and turning off the wave DAC but were % x", commands[:6])
+	}
+	if !bytes.Contains(commands, []byte{0xB3, 0x02, 0xF3}) {
+		t.Errorf("state should have included the NR12 write")
+	}
+
+	expected := []byte{
+		0xB3, 0x03, 0x12,
+		0x74,
+		0xB3, 0x20, 0xAB,
+		0x62,
+		0xB3, 0x04, 0x87,
+		0x66,
+	}
+	if !bytes.HasSuffix(commands, expected) {
+		t.Errorf("commands should have ended with % x but ended with % x", expected, commands[len(commands)-len(expected):])
+	}
+}
+
+func TestWait(t *testing.T) {
+	cases := []struct {
+		samples  uint64
+		expected []byte
+	}{
+		{1, []byte{0x70}},
+		{16, []byte{0x7F}},
+		{17, []byte{0x61, 0x11, 0x00}},
+		{735, []byte{0x62}},
+		{882, []byte{0x63}},
+		{0x10000, []byte{0x61, 0xFF, 0xFF, 0x70}},
+	}
+
+	for _, c := range cases {
+		t.Run("", func(t *testing.T) {
+			var buf bytes.Buffer
+			r := &Recorder{}
+			r.w = bufio.NewWriter(&buf)
+			r.wait(c.samples)
+			r.w.Flush()
+
+			if !bytes.Equal(buf.Bytes(), c.expected) {
+				t.Errorf("wait should have been % x but was % x", c.expected, buf.Bytes())
+			}
+		})
+	}
+}